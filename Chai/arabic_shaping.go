@@ -393,6 +393,27 @@ func ShapeArabic(input string) string {
 	return strings.Join(shapedSentence, " ")
 }
 
+// shapeArabicWords joins the letters of every arabic word like ShapeArabic but leaves the text in reading
+// order, for layouts that wrap it before laying each line out from right to left
+func shapeArabicWords(input string) string {
+	var shaped strings.Builder
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if !IsArabicLetter(runes[i]) {
+			shaped.WriteRune(runes[i])
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && IsArabicLetter(runes[end]) {
+			end++
+		}
+		shaped.WriteString(reverse(shapeWord(string(runes[i:end]))))
+		i = end
+	}
+	return shaped.String()
+}
+
 // shapeWord will reconstruct an arabic word to be connected correctly
 func shapeWord(input string) string {
 	if !IsArabic(input) {
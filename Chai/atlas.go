@@ -0,0 +1,357 @@
+package chai
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/draw"
+	"image/png"
+	"sort"
+)
+
+/* ####### Texture Region ####### */
+
+// A part of a texture, accepted by the sprite batch wherever a whole texture is
+type TextureRegion struct {
+	Texture *Texture2D
+	// Pixels occupied inside the texture, from its top-left
+	X, Y, Width, Height int
+	// Stored turned 90 degrees clockwise, Width and Height are then those of the turned pixels
+	Rotated bool
+	// Transparent borders removed when packing: the stored pixels start at TrimOffset inside an image of OriginalSize
+	TrimOffset   Vector2i
+	OriginalSize Vector2i
+}
+
+func NewTextureRegion(_texture *Texture2D, _x, _y, _width, _height int) TextureRegion {
+	return TextureRegion{
+		Texture:      _texture,
+		X:            _x,
+		Y:            _y,
+		Width:        _width,
+		Height:       _height,
+		OriginalSize: NewVector2i(_width, _height),
+	}
+}
+
+func NewTextureRegionFromTexture(_texture *Texture2D) TextureRegion {
+	return NewTextureRegion(_texture, 0, 0, _texture.Width, _texture.Height)
+}
+
+// Size of the image before trimming, in pixels
+func (r *TextureRegion) GetSize() Vector2i {
+	return r.OriginalSize
+}
+
+// Top-left and bottom-right uvs, only meaningful for regions that aren't rotated
+func (r *TextureRegion) GetUV() (Vector2f, Vector2f) {
+	width, height := float32(r.Texture.Width), float32(r.Texture.Height)
+	return NewVector2f(float32(r.X)/width, float32(r.Y)/height), NewVector2f(float32(r.X+r.Width)/width, float32(r.Y+r.Height)/height)
+}
+
+// Size of the stored pixels once turned back upright
+func (r *TextureRegion) trimmedSize() Vector2i {
+	if r.Rotated {
+		return NewVector2i(r.Height, r.Width)
+	}
+	return NewVector2i(r.Width, r.Height)
+}
+
+// Uvs of the bottom-left, top-left, top-right and bottom-right corners of the upright image
+func (r *TextureRegion) cornerUVs() [4]Vector2f {
+	uv1, uv2 := r.GetUV()
+	topLeft, topRight := uv1, NewVector2f(uv2.X, uv1.Y)
+	bottomLeft, bottomRight := NewVector2f(uv1.X, uv2.Y), uv2
+	if r.Rotated {
+		// Turning clockwise moved the top-left corner of the image to the top-right of the stored pixels
+		return [4]Vector2f{topLeft, topRight, bottomRight, bottomLeft}
+	}
+	return [4]Vector2f{bottomLeft, topLeft, topRight, bottomRight}
+}
+
+// Uv of a point of the upright trimmed image, in pixels from its top-left
+func (r *TextureRegion) pixelUV(_x, _y float32) Vector2f {
+	width, height := float32(r.Texture.Width), float32(r.Texture.Height)
+	if r.Rotated {
+		// The left column of the upright image is the top row of the stored pixels
+		return NewVector2f((float32(r.X+r.Width)-_y)/width, (float32(r.Y)+_x)/height)
+	}
+	return NewVector2f((float32(r.X)+_x)/width, (float32(r.Y)+_y)/height)
+}
+
+// Corners of the quad of a region drawn with _dimensions (for the untrimmed image), relative to its centre
+func (r *TextureRegion) quadCorners(_dimensions Vector2f) [4]Vector2f {
+	original := r.OriginalSize
+	if original.X <= 0 || original.Y <= 0 {
+		original = r.trimmedSize()
+	}
+	trimmed := r.trimmedSize()
+	scaleX, scaleY := _dimensions.X/float32(original.X), _dimensions.Y/float32(original.Y)
+
+	left := -_dimensions.X/2.0 + float32(r.TrimOffset.X)*scaleX
+	right := left + float32(trimmed.X)*scaleX
+	top := _dimensions.Y/2.0 - float32(r.TrimOffset.Y)*scaleY
+	bottom := top - float32(trimmed.Y)*scaleY
+	return [4]Vector2f{NewVector2f(left, bottom), NewVector2f(left, top), NewVector2f(right, top), NewVector2f(right, bottom)}
+}
+
+/* ####### Texture Atlas ####### */
+
+// Many images in one texture, looked up by name
+type TextureAtlas struct {
+	Texture Texture2D
+	regions map[string]*TextureRegion
+	names   []string
+}
+
+func newTextureAtlas() *TextureAtlas {
+	return &TextureAtlas{regions: make(map[string]*TextureRegion), names: make([]string, 0)}
+}
+
+func (a *TextureAtlas) addRegion(_name string, _region TextureRegion) {
+	_region.Texture = &a.Texture
+	if _, ok := a.regions[_name]; !ok {
+		a.names = append(a.names, _name)
+	}
+	a.regions[_name] = &_region
+}
+
+// Returns nil when the atlas has no region called _name
+func (a *TextureAtlas) GetRegion(_name string) *TextureRegion {
+	region, ok := a.regions[_name]
+	if !ok {
+		WarningF("[ATLAS]: no region named %v", _name)
+		return nil
+	}
+	return region
+}
+
+func (a *TextureAtlas) HasRegion(_name string) bool {
+	_, ok := a.regions[_name]
+	return ok
+}
+
+// Names of the regions in the order they were added
+func (a *TextureAtlas) GetRegionNames() []string {
+	return a.names
+}
+
+/* ####### Runtime Packing ####### */
+
+// Combines images loaded at runtime into a single atlas texture
+type AtlasBuilder struct {
+	// Largest side of the atlas texture in pixels
+	MaxSize int
+	Padding int
+	// Removes the fully transparent borders of the images before packing them
+	Trim bool
+	// Filtering and alpha storage of the atlas texture, DefaultTextureOptions like LoadPng
+	TextureOptions TextureOptions
+	images         []atlasImage
+}
+
+type atlasImage struct {
+	name string
+	img  image.Image
+	// Part of img that is packed
+	bounds image.Rectangle
+}
+
+func NewAtlasBuilder(_maxSize, _padding int) *AtlasBuilder {
+	return &AtlasBuilder{MaxSize: _maxSize, Padding: _padding, TextureOptions: DefaultTextureOptions(), images: make([]atlasImage, 0)}
+}
+
+func (b *AtlasBuilder) Add(_name string, _img image.Image) {
+	b.images = append(b.images, atlasImage{name: _name, img: _img})
+}
+
+// Fetches a png the same way LoadPng does, the region is named after _filePath
+func (b *AtlasBuilder) AddPng(_filePath string) {
+	data, err := fetchAsset(_filePath)
+	if err != nil {
+		LogF("[ATLAS]: %v", err.Error())
+		return
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		LogF("[ATLAS]: %v: %v", _filePath, err.Error())
+		return
+	}
+	b.Add(_filePath, img)
+}
+
+// Packs every added image into a new texture, the smallest power of two size that fits is used.
+// Images that don't fit in MaxSize x MaxSize are left out with a warning
+func (b *AtlasBuilder) Build() *TextureAtlas {
+	area := 0
+	for i := range b.images {
+		img := &b.images[i]
+		img.bounds = img.img.Bounds()
+		if b.Trim {
+			img.bounds = opaqueBounds(img.img)
+		}
+		area += (img.bounds.Dx() + b.Padding) * (img.bounds.Dy() + b.Padding)
+	}
+
+	// Tallest first packs the skyline the tightest
+	order := make([]*atlasImage, len(b.images))
+	for i := range b.images {
+		order[i] = &b.images[i]
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].bounds.Dy() > order[j].bounds.Dy()
+	})
+
+	width, height := 1, 1
+	for width*height < area && (width < b.MaxSize || height < b.MaxSize) {
+		if width <= height {
+			width = MinInt(width*2, b.MaxSize)
+		} else {
+			height = MinInt(height*2, b.MaxSize)
+		}
+	}
+
+	var positions []Vector2i
+	var packed []bool
+	for {
+		positions, packed = b.pack(order, width, height)
+		done := true
+		for _, ok := range packed {
+			done = done && ok
+		}
+		if done || (width >= b.MaxSize && height >= b.MaxSize) {
+			break
+		}
+		if width <= height {
+			width = MinInt(width*2, b.MaxSize)
+		} else {
+			height = MinInt(height*2, b.MaxSize)
+		}
+	}
+
+	// image.RGBA stores premultiplied colours, TextureOptions decides how they are uploaded
+	pixels := image.NewRGBA(image.Rect(0, 0, width, height))
+	atlas := newTextureAtlas()
+	for i, img := range order {
+		if !packed[i] {
+			WarningF("[ATLAS]: %v doesn't fit in a %vx%v atlas", img.name, b.MaxSize, b.MaxSize)
+			continue
+		}
+		size := img.bounds.Size()
+		target := image.Rect(positions[i].X, positions[i].Y, positions[i].X+size.X, positions[i].Y+size.Y)
+		draw.Draw(pixels, target, img.img, img.bounds.Min, draw.Src)
+
+		region := NewTextureRegion(nil, target.Min.X, target.Min.Y, size.X, size.Y)
+		region.TrimOffset = NewVector2i(img.bounds.Min.X-img.img.Bounds().Min.X, img.bounds.Min.Y-img.img.Bounds().Min.Y)
+		region.OriginalSize = NewVector2i(img.img.Bounds().Dx(), img.img.Bounds().Dy())
+		atlas.addRegion(img.name, region)
+	}
+	atlas.Texture = LoadTextureFromImgWithOptions(pixels, b.TextureOptions)
+	return atlas
+}
+
+func (b *AtlasBuilder) pack(_images []*atlasImage, _width, _height int) ([]Vector2i, []bool) {
+	packer := NewRectPacker(_width, _height, b.Padding)
+	positions := make([]Vector2i, len(_images))
+	packed := make([]bool, len(_images))
+	for i, img := range _images {
+		positions[i], packed[i] = packer.Pack(img.bounds.Dx(), img.bounds.Dy())
+	}
+	return positions, packed
+}
+
+// Smallest rectangle holding every pixel that isn't fully transparent
+func opaqueBounds(_img image.Image) image.Rectangle {
+	bounds := _img.Bounds()
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X, bounds.Min.Y
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := _img.At(x, y).RGBA(); a == 0 {
+				continue
+			}
+			minX, minY = MinInt(minX, x), MinInt(minY, y)
+			maxX, maxY = MaxInt(maxX, x+1), MaxInt(maxY, y+1)
+		}
+	}
+	if minX >= maxX || minY >= maxY {
+		// Keep a single pixel of fully transparent images so they still get a region
+		return image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+1, bounds.Min.Y+1)
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+/* ####### TexturePacker ####### */
+
+type texturePackerRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type texturePackerFrame struct {
+	Filename         string            `json:"filename"`
+	Frame            texturePackerRect `json:"frame"`
+	Rotated          bool              `json:"rotated"`
+	Trimmed          bool              `json:"trimmed"`
+	SpriteSourceSize texturePackerRect `json:"spriteSourceSize"`
+	SourceSize       texturePackerRect `json:"sourceSize"`
+}
+
+type texturePackerFile struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image string `json:"image"`
+	} `json:"meta"`
+}
+
+// Loads an atlas exported as TexturePacker JSON, both the hash and the array flavours.
+// The image is looked up next to the json file. Returns nil on failure
+func LoadTextureAtlas(_filePath string) *TextureAtlas {
+	data, err := fetchAsset(_filePath)
+	if err != nil {
+		LogF("[ATLAS]: %v", err.Error())
+		return nil
+	}
+	var file texturePackerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		LogF("[ATLAS]: %v: %v", _filePath, err.Error())
+		return nil
+	}
+
+	frames := make([]texturePackerFrame, 0)
+	if trimmed := bytes.TrimSpace(file.Frames); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(file.Frames, &frames)
+	} else {
+		hash := make(map[string]texturePackerFrame)
+		err = json.Unmarshal(file.Frames, &hash)
+		for name, frame := range hash {
+			frame.Filename = name
+			frames = append(frames, frame)
+		}
+		// Map iteration order is random, keep the names stable
+		sort.Slice(frames, func(i, j int) bool { return frames[i].Filename < frames[j].Filename })
+	}
+	if err != nil {
+		LogF("[ATLAS]: %v: %v", _filePath, err.Error())
+		return nil
+	}
+
+	atlas := newTextureAtlas()
+	atlas.Texture = LoadPng(resolveRelativePath(_filePath, file.Meta.Image))
+	for _, frame := range frames {
+		// The frame size is the upright one, rotated frames occupy it turned
+		region := NewTextureRegion(nil, frame.Frame.X, frame.Frame.Y, frame.Frame.W, frame.Frame.H)
+		if frame.Rotated {
+			region.Rotated = true
+			region.Width, region.Height = frame.Frame.H, frame.Frame.W
+		}
+		region.OriginalSize = NewVector2i(frame.Frame.W, frame.Frame.H)
+		if frame.Trimmed {
+			region.TrimOffset = NewVector2i(frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y)
+			region.OriginalSize = NewVector2i(frame.SourceSize.W, frame.SourceSize.H)
+		}
+		atlas.addRegion(frame.Filename, region)
+	}
+	return atlas
+}
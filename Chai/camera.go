@@ -1,12 +1,32 @@
 package chai
 
 import (
+	"math"
+
 	"github.com/udhos/goglmath"
 )
 
 type Camera2D struct {
-	position      Vector2f
-	scale         float32
+	position Vector2f
+	scale    float32
+	// Degrees, counter-clockwise like EcsEntity.Rot
+	rotation float32
+	// Area of the canvas in pixels the camera projects onto
+	viewportOffset Vector2f
+	viewportSize   Vector2f
+	// Canvas pixels per virtual pixel, set by the resize mode and the device pixel ratio
+	pixelScale float32
+
+	// Screen shake, see AddCameraTrauma
+	ShakeMaxOffset Vector2f
+	ShakeMaxAngle  float32
+	ShakeFrequency float32
+	TraumaDecay    float32
+	trauma         float32
+	shakeTime      float32
+	shakeOffset    Vector2f
+	shakeAngle     float32
+
 	projectMatrix goglmath.Matrix4
 	viewMatrix    goglmath.Matrix4
 	mustUpdate    bool
@@ -15,6 +35,13 @@ type Camera2D struct {
 func (cam *Camera2D) Init(_app App) {
 	cam.position = Vector2fZero
 	cam.scale = 1.0
+	cam.pixelScale = 1.0
+	cam.rotation = 0.0
+	cam.viewportSize = NewVector2f(float32(_app.Width), float32(_app.Height))
+	cam.ShakeMaxOffset = NewVector2f(16.0, 16.0)
+	cam.ShakeMaxAngle = 5.0
+	cam.ShakeFrequency = 25.0
+	cam.TraumaDecay = 1.0
 	cam.projectMatrix = goglmath.Matrix4{}
 	cam.projectMatrix = Ortho(0, float32(_app.Width), 0, float32(_app.Height), -5.0, 5.0)
 
@@ -24,15 +51,104 @@ func (cam *Camera2D) Init(_app App) {
 
 }
 
+func (cam *Camera2D) setViewport(_x, _y, _width, _height int) {
+	cam.viewportOffset = NewVector2f(float32(_x), float32(_y))
+	cam.viewportSize = NewVector2f(float32(_width), float32(_height))
+	cam.projectMatrix = Ortho(0, float32(_width), 0, float32(_height), -5.0, 5.0)
+	cam.mustUpdate = true
+}
+
 func (cam *Camera2D) Update(_app App) {
 	if !cam.mustUpdate {
 		return
 	}
 
-	cam.viewMatrix = Translate(cam.projectMatrix, -cam.position.X+float32(_app.Width)/2.0, -cam.position.Y+float32(_app.Height)/2.0, 0.0, 1.0, cam.scale)
+	// screen = viewportSize/2 + scale * pixelScale * R(-rotation) * (world - position)
+	position, rotation := cam.effectivePosition(), cam.effectiveRotation()
+	cosR := float32(math.Cos(float64(-rotation * PI / 180.0)))
+	sinR := float32(math.Sin(float64(-rotation * PI / 180.0)))
+	scale := cam.effectiveScale()
+	a, b := scale*cosR, scale*sinR
+	c, d := -scale*sinR, scale*cosR
+	half := cam.viewportSize.Scale(0.5)
+	tx := half.X - (a*position.X + c*position.Y)
+	ty := half.Y - (b*position.X + d*position.Y)
+
+	affine := affineMatrix2D(a, b, c, d, tx, ty)
+	cam.viewMatrix = cam.projectMatrix
+	cam.viewMatrix.Multiply(&affine)
 	cam.mustUpdate = false
 }
 
+// Position and rotation including the screen shake
+func (cam *Camera2D) effectivePosition() Vector2f {
+	return cam.position.Add(cam.shakeOffset)
+}
+
+func (cam *Camera2D) effectiveRotation() float32 {
+	return cam.rotation + cam.shakeAngle
+}
+
+// Canvas pixels per world unit, cameras that were never laid out on the canvas count one pixel per virtual pixel
+func (cam *Camera2D) effectiveScale() float32 {
+	if cam.pixelScale == 0.0 {
+		return cam.scale
+	}
+	return cam.scale * cam.pixelScale
+}
+
+func (cam *Camera2D) GetPosition() Vector2f {
+	return cam.position
+}
+
+func (cam *Camera2D) GetScale() float32 {
+	return cam.scale
+}
+
+func (cam *Camera2D) GetRotation() float32 {
+	return cam.rotation
+}
+
+// Converts a point in canvas pixels (origin at the bottom-left) to world coordinates
+func (cam *Camera2D) ScreenToWorld(_screenPoint Vector2f) Vector2f {
+	local := _screenPoint.Subtract(cam.viewportOffset).Subtract(cam.viewportSize.Scale(0.5)).Scale(1 / cam.effectiveScale())
+	return local.RotateCenter(cam.effectiveRotation()).Add(cam.effectivePosition())
+}
+
+// Converts a point in world coordinates to canvas pixels (origin at the bottom-left)
+func (cam *Camera2D) WorldToScreen(_worldPoint Vector2f) Vector2f {
+	local := _worldPoint.Subtract(cam.effectivePosition()).RotateCenter(-cam.effectiveRotation())
+	return local.Scale(cam.effectiveScale()).Add(cam.viewportSize.Scale(0.5)).Add(cam.viewportOffset)
+}
+
+// World space bounding box of what the camera shows, including rotation
+func (cam *Camera2D) GetViewBounds() Rect {
+	corners := [4]Vector2f{
+		cam.ScreenToWorld(cam.viewportOffset),
+		cam.ScreenToWorld(cam.viewportOffset.AddXY(cam.viewportSize.X, 0.0)),
+		cam.ScreenToWorld(cam.viewportOffset.AddXY(0.0, cam.viewportSize.Y)),
+		cam.ScreenToWorld(cam.viewportOffset.Add(cam.viewportSize)),
+	}
+	min, max := corners[0], corners[0]
+	for _, corner := range corners[1:] {
+		min = NewVector2f(MinFloat32(min.X, corner.X), MinFloat32(min.Y, corner.Y))
+		max = NewVector2f(MaxFloat32(max.X, corner.X), MaxFloat32(max.Y, corner.Y))
+	}
+	return Rect{Position: min, Size: max.Subtract(min)}
+}
+
+// Builds the column-major matrix of the 2D affine transform | a c tx |
+//
+//	| b d ty |
+func affineMatrix2D(a, b, c, d, tx, ty float32) goglmath.Matrix4 {
+	matrix := goglmath.NewMatrix4Identity()
+	data := matrix.Data()
+	data[0], data[1] = a, b
+	data[4], data[5] = c, d
+	data[12], data[13] = tx, ty
+	return matrix
+}
+
 func Ortho(left, right, bottom, top, near, far float32) goglmath.Matrix4 {
 
 	matrix := goglmath.Matrix4{}
@@ -96,10 +212,59 @@ func RegulateScale() {
 	Cam.mustUpdate = true
 }
 
+// Sets the scale while keeping the world point under _screenPoint in place, e.g. zooming towards the mouse
+func ScaleViewAt(_screenPoint Vector2f, newScale float32) {
+	anchor := Cam.ScreenToWorld(_screenPoint)
+	ScaleView(newScale)
+	ScrollView(anchor.Subtract(Cam.ScreenToWorld(_screenPoint)))
+}
+
+// Same as IncreaseScaleU, anchored at _screenPoint
+func IncreaseScaleAt(_screenPoint Vector2f, increment float32) {
+	ScaleViewAt(_screenPoint, Cam.scale+(scalePercentage*Cam.scale)*increment)
+}
+
+// Rotates the view to an absolute angle in degrees (counter-clockwise)
+func RotateViewTo(_degrees float32) {
+	Cam.rotation = float32(math.Mod(float64(_degrees), 360.0))
+	Cam.mustUpdate = true
+}
+
+func RotateView(_degrees float32) {
+	RotateViewTo(Cam.rotation + _degrees)
+}
+
+func ScreenToWorld(_screenPoint Vector2f) Vector2f {
+	return Cam.ScreenToWorld(_screenPoint)
+}
+
+func WorldToScreen(_worldPoint Vector2f) Vector2f {
+	return Cam.WorldToScreen(_worldPoint)
+}
+
+// Converts browser client coordinates (e.g. MouseEvent.clientX/Y) to canvas pixels with the origin at the bottom-left,
+// taking into account the position of the canvas on the page and its CSS size
+func ClientToScreen(_clientX, _clientY float32) Vector2f {
+	rect := canvas.Call("getBoundingClientRect")
+	canvasWidth := float32(canvas.Get("width").Int())
+	canvasHeight := float32(canvas.Get("height").Int())
+
+	x := (_clientX - float32(rect.Get("left").Float())) / float32(rect.Get("width").Float()) * canvasWidth
+	y := canvasHeight - (_clientY-float32(rect.Get("top").Float()))/float32(rect.Get("height").Float())*canvasHeight
+	return NewVector2f(x, y)
+}
+
+// The inverse of ClientToScreen
+func ScreenToClient(_screenPoint Vector2f) Vector2f {
+	rect := canvas.Call("getBoundingClientRect")
+	canvasWidth := float32(canvas.Get("width").Int())
+	canvasHeight := float32(canvas.Get("height").Int())
+
+	x := _screenPoint.X/canvasWidth*float32(rect.Get("width").Float()) + float32(rect.Get("left").Float())
+	y := (canvasHeight-_screenPoint.Y)/canvasHeight*float32(rect.Get("height").Float()) + float32(rect.Get("top").Float())
+	return NewVector2f(x, y)
+}
+
 func GetMouseWorldPosition() Vector2f {
-	screenPoint := MouseCanvasPos
-	screenPoint = screenPoint.Subtract(NewVector2f(float32(GetCanvasWidth())/2.0, float32(GetCanvasHeigth())/2.0))
-	screenPoint = screenPoint.Scale(1 / Cam.scale)
-	screenPoint = screenPoint.Add(Cam.position)
-	return screenPoint
+	return Cam.ScreenToWorld(MouseCanvasPos)
 }
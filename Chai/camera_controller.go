@@ -0,0 +1,175 @@
+package chai
+
+import "math"
+
+/* ####### Camera Follow ####### */
+
+// Attach to the entity the global Cam should follow, and add a CameraControllerSystem as an update system
+type CameraFollowComponent struct {
+	Component
+	Offset Vector2f
+	// Time in seconds the camera takes to catch up with the target, zero snaps to it
+	Smoothing float32
+	// Half-size in world units of the box around the view centre in which the target can move without moving the camera
+	DeadZone Vector2f
+	// Seconds of target velocity the camera leads by
+	LookAhead          float32
+	LookAheadSmoothing float32
+	// When enabled, the visible area never goes past BoundsMin/BoundsMax
+	ClampToBounds bool
+	BoundsMin     Vector2f
+	BoundsMax     Vector2f
+	// Target scale of the camera, zero leaves the scale untouched
+	Zoom          float32
+	ZoomSmoothing float32
+
+	lastTargetPos Vector2f
+	lookAhead     Vector2f
+	initialized   bool
+}
+
+func (t *CameraFollowComponent) ComponentSet(val interface{}) { *t = val.(CameraFollowComponent) }
+
+func NewCameraFollowComponent(_smoothing float32) CameraFollowComponent {
+	return CameraFollowComponent{
+		Smoothing:          _smoothing,
+		LookAheadSmoothing: 0.5,
+		ZoomSmoothing:      0.25,
+	}
+}
+
+func (t *CameraFollowComponent) SetBounds(_min, _max Vector2f) {
+	t.ClampToBounds = true
+	t.BoundsMin = _min
+	t.BoundsMax = _max
+}
+
+type CameraControllerSystem struct {
+	EcsSystemImpl
+}
+
+func (_sys *CameraControllerSystem) Update(dt float32) {
+	if dt <= 0.0 {
+		return
+	}
+	EachEntity(CameraFollowComponent{}, func(entity *EcsEntity, a interface{}) {
+		follow := a.(CameraFollowComponent)
+		if !follow.initialized {
+			follow.lastTargetPos = entity.Pos
+			follow.initialized = true
+			ScrollTo(entity.Pos.Add(follow.Offset))
+		}
+
+		velocity := entity.Pos.Subtract(follow.lastTargetPos).Scale(1.0 / dt)
+		var body DynamicBodyComponent
+		if ReadComponent(_sys.GetEcsEngine(), entity, &body) && body.phy_body != nil {
+			velocity = body.GetLinearVelocity()
+		}
+		follow.lastTargetPos = entity.Pos
+		follow.lookAhead = smoothDampVector2f(follow.lookAhead, velocity.Scale(follow.LookAhead), follow.LookAheadSmoothing, dt)
+
+		if follow.Zoom > 0.0 {
+			ScaleView(smoothDampFloat32(Cam.scale, follow.Zoom, follow.ZoomSmoothing, dt))
+		}
+
+		goal := Cam.position
+		target := entity.Pos.Add(follow.Offset).Add(follow.lookAhead)
+		goal.X = approachDeadZone(goal.X, target.X, follow.DeadZone.X)
+		goal.Y = approachDeadZone(goal.Y, target.Y, follow.DeadZone.Y)
+
+		newPosition := smoothDampVector2f(Cam.position, goal, follow.Smoothing, dt)
+		if follow.ClampToBounds {
+			newPosition = clampViewToBounds(newPosition, follow.BoundsMin, follow.BoundsMax)
+		}
+		ScrollTo(newPosition)
+
+		WriteComponent(_sys.GetEcsEngine(), entity, follow)
+	})
+}
+
+// Moves _current only as far as needed for _target to be within _halfSize of it
+func approachDeadZone(_current, _target, _halfSize float32) float32 {
+	if _target > _current+_halfSize {
+		return _target - _halfSize
+	}
+	if _target < _current-_halfSize {
+		return _target + _halfSize
+	}
+	return _current
+}
+
+// Frame-rate independent exponential smoothing, _smoothing is roughly the time to cover 63% of the distance
+func smoothDampFloat32(_current, _target, _smoothing, _dt float32) float32 {
+	if _smoothing <= 0.0 {
+		return _target
+	}
+	return LerpFloat32(_current, _target, 1.0-float32(math.Exp(float64(-_dt/_smoothing))))
+}
+
+func smoothDampVector2f(_current, _target Vector2f, _smoothing, _dt float32) Vector2f {
+	return NewVector2f(smoothDampFloat32(_current.X, _target.X, _smoothing, _dt), smoothDampFloat32(_current.Y, _target.Y, _smoothing, _dt))
+}
+
+func clampViewToBounds(_center, _min, _max Vector2f) Vector2f {
+	halfView := Cam.viewportSize.Scale(0.5 / Cam.effectiveScale())
+	if Cam.rotation != 0.0 {
+		// Extent of the rotated view rectangle along the world axes
+		cosR := AbsFloat32(float32(math.Cos(float64(Cam.rotation * PI / 180.0))))
+		sinR := AbsFloat32(float32(math.Sin(float64(Cam.rotation * PI / 180.0))))
+		halfView = NewVector2f(halfView.X*cosR+halfView.Y*sinR, halfView.X*sinR+halfView.Y*cosR)
+	}
+	return NewVector2f(clampAxisToBounds(_center.X, halfView.X, _min.X, _max.X), clampAxisToBounds(_center.Y, halfView.Y, _min.Y, _max.Y))
+}
+
+func clampAxisToBounds(_center, _halfView, _min, _max float32) float32 {
+	// Centre the view when the bounds are smaller than what it shows
+	if _max-_min <= 2*_halfView {
+		return (_min + _max) / 2.0
+	}
+	return ClampFloat32(_center, _min+_halfView, _max-_halfView)
+}
+
+/* ####### Screen Shake ####### */
+
+// Adds trauma in the range [0, 1] to the global Cam, the shake intensity is trauma squared
+func AddCameraTrauma(_amount float32) {
+	Cam.trauma = ClampFloat32(Cam.trauma+_amount, 0.0, 1.0)
+}
+
+func GetCameraTrauma() float32 {
+	return Cam.trauma
+}
+
+// Maximum offset in world units and rotation in degrees applied at full trauma
+func SetCameraShake(_maxOffset Vector2f, _maxAngle, _frequency, _traumaDecay float32) {
+	Cam.ShakeMaxOffset = _maxOffset
+	Cam.ShakeMaxAngle = _maxAngle
+	Cam.ShakeFrequency = _frequency
+	Cam.TraumaDecay = _traumaDecay
+}
+
+func (cam *Camera2D) updateShake(_dt float32) {
+	if cam.trauma <= 0.0 {
+		if cam.shakeOffset != Vector2fZero || cam.shakeAngle != 0.0 {
+			cam.shakeOffset = Vector2fZero
+			cam.shakeAngle = 0.0
+			cam.mustUpdate = true
+		}
+		return
+	}
+
+	cam.shakeTime += _dt
+	shake := cam.trauma * cam.trauma
+	t := cam.shakeTime * cam.ShakeFrequency
+	cam.shakeOffset = NewVector2f(cam.ShakeMaxOffset.X*shake*shakeNoise(t, 0.0), cam.ShakeMaxOffset.Y*shake*shakeNoise(t, 17.0))
+	cam.shakeAngle = cam.ShakeMaxAngle * shake * shakeNoise(t, 43.0)
+	cam.trauma = ClampFloat32(cam.trauma-cam.TraumaDecay*_dt, 0.0, 1.0)
+	cam.mustUpdate = true
+}
+
+// Smooth pseudo-random signal in [-1, 1]
+func shakeNoise(_t, _seed float32) float32 {
+	t := float64(_t)
+	s := float64(_seed)
+	return float32(0.5*math.Sin(t+s) + 0.3*math.Sin(2.31*t+1.7*s) + 0.2*math.Sin(4.73*t+3.1*s))
+}
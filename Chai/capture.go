@@ -0,0 +1,297 @@
+package chai
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"syscall/js"
+)
+
+// Reads _width x _height pixels from the bottom-left of the framebuffer bound for reading.
+// GL rows go bottom-up, they are flipped so the image is upright
+func readFramebufferPixels(_width, _height int) *image.RGBA {
+	rowSize := _width * 4
+	jsPixels := js.Global().Get("Uint8Array").New(rowSize * _height)
+	canvasContext.Call("pixelStorei", canvasContext.Get("PACK_ALIGNMENT"), 1)
+	canvasContext.Call("readPixels", 0, 0, _width, _height, canvasContext.Get("RGBA"), canvasContext.Get("UNSIGNED_BYTE"), jsPixels)
+
+	pixels := make([]byte, rowSize*_height)
+	js.CopyBytesToGo(pixels, jsPixels)
+
+	img := image.NewRGBA(image.Rect(0, 0, _width, _height))
+	for y := 0; y < _height; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+rowSize], pixels[(_height-1-y)*rowSize:(_height-y)*rowSize])
+	}
+	return img
+}
+
+// The canvas is shown opaque whatever alpha the draws left in it
+func makeOpaque(_img *image.RGBA) {
+	for i := 3; i < len(_img.Pix); i += 4 {
+		_img.Pix[i] = 255
+	}
+}
+
+// Waiting for the canvas of the frame being drawn, or of the next one
+var pendingCaptures []func(*image.RGBA)
+
+// Calls _onCaptured with the canvas, post-processing included, once the frame is drawn. Nothing is drawn
+// again, so it can be called from OnDraw and keeps what was already batched for the frame
+func CaptureFrame(_onCaptured func(*image.RGBA)) {
+	pendingCaptures = append(pendingCaptures, _onCaptured)
+}
+
+// Resolves the multisampled canvas into _target, which is the size of the canvas
+func blitCanvasTo(_target *RenderTarget) {
+	_target.ensureContext()
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("READ_FRAMEBUFFER"), js.Null())
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("DRAW_FRAMEBUFFER"), _target.gl.framebuffer)
+	canvasContext.Call("blitFramebuffer", 0, 0, currentWidth, currentHeight, 0, 0, currentWidth, currentHeight, canvasContext.Get("COLOR_BUFFER_BIT"), canvasContext.Get("NEAREST"))
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("DRAW_FRAMEBUFFER"), js.Null())
+}
+
+// Reads the canvas right after drawing, before the browser presents and clears it
+func capturePendingFrames() {
+	if len(pendingCaptures) == 0 {
+		return
+	}
+	// Captures requested by the callbacks wait for the next frame
+	callbacks := pendingCaptures
+	pendingCaptures = nil
+
+	target := NewRenderTarget(currentWidth, currentHeight, false)
+	blitCanvasTo(&target)
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("READ_FRAMEBUFFER"), target.gl.framebuffer)
+	img := readFramebufferPixels(currentWidth, currentHeight)
+	rebindCurrentFramebuffer()
+	target.Delete()
+	makeOpaque(img)
+
+	for i, callback := range callbacks {
+		if i > 0 {
+			// Every callback gets its own copy to change
+			img = &image.RGBA{Pix: append([]byte(nil), img.Pix...), Stride: img.Stride, Rect: img.Rect}
+		}
+		callback(img)
+	}
+}
+
+// Reads the content of the render target back, with the alpha it holds
+func (rt *RenderTarget) Capture() *image.RGBA {
+	rt.Bind()
+	img := readFramebufferPixels(rt.Width, rt.Height)
+	rt.Unbind()
+	return img
+}
+
+// Has the browser download the frame as a PNG file once it is drawn, see CaptureFrame
+func SaveScreenshot(_fileName string) {
+	CaptureFrame(func(_img *image.RGBA) {
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, _img); err != nil {
+			WarningF("[CAPTURE]: %v", err.Error())
+			return
+		}
+		DownloadBytes(_fileName, "image/png", buffer.Bytes())
+	})
+}
+
+// Hands _data to the browser as a file download
+func DownloadBytes(_fileName, _mimeType string, _data []byte) {
+	jsData := js.Global().Get("Uint8Array").New(len(_data))
+	js.CopyBytesToJS(jsData, _data)
+	blob := js.Global().Get("Blob").New([]interface{}{jsData}, map[string]interface{}{"type": _mimeType})
+
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	link := js.Global().Get("document").Call("createElement", "a")
+	link.Set("href", url)
+	link.Set("download", _fileName)
+	link.Call("click")
+
+	// Revoking right after the click can cancel the download in some browsers
+	var revoke js.Func
+	revoke = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		js.Global().Get("URL").Call("revokeObjectURL", url)
+		revoke.Release()
+		return nil
+	})
+	js.Global().Call("setTimeout", revoke, 1000)
+}
+
+/* ####### Frame Recorder ####### */
+
+// Keeps the last seconds of the canvas as a ring of small paletted frames that can be saved as a GIF
+type FrameRecorder struct {
+	// Frames captured per second
+	FrameRate int
+	// Width of the recorded frames in pixels, the height follows the aspect ratio of the canvas
+	Width int
+	// Error diffusion when reducing the frames to the GIF palette, smoother gradients at a higher cost
+	Dither bool
+
+	frames      []*image.Paletted
+	first       int
+	count       int
+	recording   bool
+	lastCapture float32
+	// The canvas is multisampled, it is resolved at full size before being scaled down
+	resolveTarget RenderTarget
+	smallTarget   RenderTarget
+	hasTargets    bool
+}
+
+var frameRecorders []*FrameRecorder
+
+// A recorder that keeps the last _seconds at _frameRate frames per second, scaled down to _width pixels wide
+func NewFrameRecorder(_seconds float32, _frameRate int, _width int) *FrameRecorder {
+	if _frameRate <= 0 {
+		WarningF("[CAPTURE]: frame rate must be positive, got %v", _frameRate)
+		_frameRate = 10
+	}
+	capacity := MaxInt(int(_seconds*float32(_frameRate)), 1)
+	return &FrameRecorder{
+		FrameRate: _frameRate,
+		Width:     _width,
+		Dither:    true,
+		frames:    make([]*image.Paletted, capacity),
+	}
+}
+
+func (fr *FrameRecorder) Start() {
+	if fr.recording {
+		return
+	}
+	fr.recording = true
+	fr.lastCapture = ElapsedTime - 1.0/float32(fr.FrameRate)
+	frameRecorders = append(frameRecorders, fr)
+}
+
+// Stops capturing, the recorded frames are kept until Clear
+func (fr *FrameRecorder) Stop() {
+	if !fr.recording {
+		return
+	}
+	fr.recording = false
+	for i, recorder := range frameRecorders {
+		if recorder == fr {
+			frameRecorders = append(frameRecorders[:i], frameRecorders[i+1:]...)
+			break
+		}
+	}
+}
+
+func (fr *FrameRecorder) IsRecording() bool {
+	return fr.recording
+}
+
+func (fr *FrameRecorder) GetFrameCount() int {
+	return fr.count
+}
+
+func (fr *FrameRecorder) Clear() {
+	for i := range fr.frames {
+		fr.frames[i] = nil
+	}
+	fr.first, fr.count = 0, 0
+}
+
+// Frees the render targets, the recorder can be started again afterwards
+func (fr *FrameRecorder) Delete() {
+	fr.Stop()
+	if fr.hasTargets {
+		fr.resolveTarget.Delete()
+		fr.smallTarget.Delete()
+		fr.hasTargets = false
+	}
+}
+
+func captureFrameRecorders() {
+	for _, recorder := range frameRecorders {
+		if ElapsedTime-recorder.lastCapture >= 1.0/float32(recorder.FrameRate) {
+			recorder.lastCapture = ElapsedTime
+			recorder.capture()
+		}
+	}
+}
+
+func (fr *FrameRecorder) capture() {
+	width := MinInt(MaxInt(fr.Width, 1), currentWidth)
+	if fr.Width <= 0 {
+		width = currentWidth
+	}
+	height := MaxInt(width*currentHeight/currentWidth, 1)
+
+	if !fr.hasTargets {
+		fr.resolveTarget = NewRenderTarget(currentWidth, currentHeight, false)
+		fr.smallTarget = NewRenderTarget(width, height, false)
+		fr.hasTargets = true
+	}
+	if fr.resolveTarget.Width != currentWidth || fr.resolveTarget.Height != currentHeight {
+		fr.resolveTarget.Resize(currentWidth, currentHeight)
+	}
+	if fr.smallTarget.Width != width || fr.smallTarget.Height != height {
+		// A GIF has a single size, the frames recorded before the resize are dropped
+		fr.smallTarget.Resize(width, height)
+		fr.Clear()
+	}
+	fr.smallTarget.ensureContext()
+
+	blitCanvasTo(&fr.resolveTarget)
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("READ_FRAMEBUFFER"), fr.resolveTarget.gl.framebuffer)
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("DRAW_FRAMEBUFFER"), fr.smallTarget.gl.framebuffer)
+	canvasContext.Call("blitFramebuffer", 0, 0, currentWidth, currentHeight, 0, 0, width, height, canvasContext.Get("COLOR_BUFFER_BIT"), canvasContext.Get("LINEAR"))
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("DRAW_FRAMEBUFFER"), js.Null())
+
+	img := readFramebufferPixels(width, height)
+	rebindCurrentFramebuffer()
+	makeOpaque(img)
+
+	frame := image.NewPaletted(img.Bounds(), palette.Plan9)
+	if fr.Dither {
+		draw.FloydSteinberg.Draw(frame, frame.Bounds(), img, image.Point{})
+	} else {
+		draw.Draw(frame, frame.Bounds(), img, image.Point{}, draw.Src)
+	}
+
+	if fr.count < len(fr.frames) {
+		fr.frames[(fr.first+fr.count)%len(fr.frames)] = frame
+		fr.count++
+	} else {
+		fr.frames[fr.first] = frame
+		fr.first = (fr.first + 1) % len(fr.frames)
+	}
+}
+
+// Encodes the recorded frames, oldest first, as a looping GIF. Nil when nothing was recorded
+func (fr *FrameRecorder) EncodeGif() []byte {
+	if fr.count == 0 {
+		WarningF("[CAPTURE]: the recorder has no frames")
+		return nil
+	}
+	animation := gif.GIF{}
+	// GIF delays are in hundredths of a second
+	delay := MaxInt(100/fr.FrameRate, 1)
+	for i := 0; i < fr.count; i++ {
+		animation.Image = append(animation.Image, fr.frames[(fr.first+i)%len(fr.frames)])
+		animation.Delay = append(animation.Delay, delay)
+	}
+
+	var buffer bytes.Buffer
+	if err := gif.EncodeAll(&buffer, &animation); err != nil {
+		WarningF("[CAPTURE]: %v", err.Error())
+		return nil
+	}
+	return buffer.Bytes()
+}
+
+// Has the browser download the recorded frames as a GIF
+func (fr *FrameRecorder) SaveGif(_fileName string) {
+	data := fr.EncodeGif()
+	if data == nil {
+		return
+	}
+	DownloadBytes(_fileName, "image/gif", data)
+}
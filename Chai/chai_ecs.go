@@ -8,6 +8,10 @@ type Id uint32
 
 type BasicStorage struct {
 	list map[*EcsEntity]interface{}
+	// Built on the first culled iteration, see EachVisibleEntity
+	spatial      *SpatialHash
+	spatialFrame uint64
+	unbounded    []*EcsEntity
 }
 
 // Copy Paste for new types
@@ -37,6 +41,9 @@ func (s *BasicStorage) write(entity *EcsEntity, val interface{}) {
 
 func (s *BasicStorage) delete(entity *EcsEntity, val interface{}) {
 	delete(s.list, entity)
+	if s.spatial != nil {
+		s.spatial.Remove(entity)
+	}
 }
 
 type EcsEngine struct {
@@ -131,6 +138,8 @@ type EcsEntity struct {
 	Pos        Vector2f
 	Rot        float32
 	Dimensions Vector2f
+	// Cameras only draw the entities whose layer is in their LayerMask
+	RenderLayer uint8
 }
 
 type EcsSystem interface {
@@ -147,12 +156,19 @@ func (sys *EcsSystemImpl) GetEcsEngine() *EcsEngine {
 }
 
 type Scene struct {
-	Background     RGBA8
-	Ecs_engine     EcsEngine
-	entities       []EcsEntity
-	update_systems []EcsSystem
-	render_systems []EcsSystem
-	OnSceneStart   func()
+	Background  RGBA8
+	PostProcess PostProcessStack
+	Lighting    SceneLighting
+	// Makes the built-in render systems submit every entity instead of only the ones in the camera view
+	DisableCulling bool
+	// World size of the cells entities are culled by, about the size of a typical entity works best.
+	// SPATIAL_HASH_DEFAULT_CELL_SIZE when 0
+	CullingCellSize float32
+	Ecs_engine      EcsEngine
+	entities        []EcsEntity
+	update_systems  []EcsSystem
+	render_systems  []EcsSystem
+	OnSceneStart    func()
 }
 
 func (scene *Scene) GetNumberOfEntities() int {
@@ -230,7 +246,22 @@ func GetCurrentScene() *Scene {
 type SpriteComponent struct {
 	Component
 	Texture Texture2D
-	Tint    RGBA8
+	// Drawn instead of Texture when set, e.g. a region of a TextureAtlas
+	Region *TextureRegion
+	// Pixels of Texture to draw, the whole texture when its size is zero
+	SourceRect Rect
+	// Relative to the centre and normalized by the size, see SpriteDrawParams
+	Pivot Vector2f
+	// Multiplies the scale of the render system, one when zero
+	Scale        Vector2f
+	FlipX, FlipY bool
+	Tint         RGBA8
+	// Bottom-left, top-left, top-right and bottom-right colours, ignored when all zero
+	CornerColors [4]RGBA8
+	Material     *Material
+	BlendMode    BlendMode
+	// Lights the sprite with per-pixel normals when the scene lighting is enabled, laid out like Texture or Region
+	NormalMap *Texture2D
 }
 
 func (t *SpriteComponent) ComponentSet(val interface{}) { *t = val.(SpriteComponent) }
@@ -242,11 +273,62 @@ type SpriteRenderOriginSystem struct {
 	Scale   float32
 }
 
+// The draw params of a sprite entity, also what it is culled with
+func (_render *SpriteRenderOriginSystem) drawParams(entity *EcsEntity, sprite *SpriteComponent) SpriteDrawParams {
+	params := SpriteDrawParams{
+		Texture:      &sprite.Texture,
+		Region:       sprite.Region,
+		SourceRect:   sprite.SourceRect,
+		Pivot:        sprite.Pivot,
+		Scale:        sprite.Scale,
+		Rotation:     entity.Rot,
+		FlipX:        sprite.FlipX,
+		FlipY:        sprite.FlipY,
+		Tint:         sprite.Tint,
+		CornerColors: sprite.CornerColors,
+		Material:     sprite.Material,
+		BlendMode:    sprite.BlendMode,
+	}
+	if params.Scale == Vector2fZero {
+		params.Scale = Vector2fOne
+	}
+	params.Scale = params.Scale.Scale(_render.Scale)
+
+	width, height := float32(sprite.Texture.Width), float32(sprite.Texture.Height)
+	if sprite.Region != nil {
+		size := sprite.Region.GetSize()
+		width, height = float32(size.X), float32(size.Y)
+	} else if sprite.SourceRect.Size.X > 0.0 && sprite.SourceRect.Size.Y > 0.0 {
+		width, height = sprite.SourceRect.Size.X, sprite.SourceRect.Size.Y
+	}
+	params.Position = entity.Pos.AddXY(_render.Offset.X*width/2.0, _render.Offset.Y*height/2.0)
+	return params
+}
+
+func (_render *SpriteRenderOriginSystem) spriteBounds(entity *EcsEntity, a interface{}) Rect {
+	sprite := a.(SpriteComponent)
+	params := _render.drawParams(entity, &sprite)
+	return params.Bounds()
+}
+
 func (_render *SpriteRenderOriginSystem) Update(dt float32) {
-	EachEntity(SpriteComponent{}, func(entity *EcsEntity, a interface{}) {
+	EachVisibleEntityBounds(SpriteComponent{}, _render.spriteBounds, func(entity *EcsEntity, a interface{}) {
 		sprite := a.(SpriteComponent)
-		halfDim := NewVector2f(_render.Offset.X*float32(sprite.Texture.Width)/2.0, _render.Offset.Y*float32(sprite.Texture.Height)/2.0)
-		_render.Sprites.DrawSpriteOriginScaledRotated(entity.Pos.Add(halfDim), Vector2fZero, Vector2fOne, _render.Scale, &sprite.Texture, sprite.Tint, entity.Rot)
+		params := _render.drawParams(entity, &sprite)
+		_render.Sprites.Draw(params)
+
+		if sprite.NormalMap != nil && current_scene.Lighting.Enabled {
+			params.Texture = sprite.NormalMap
+			if sprite.Region != nil {
+				normalRegion := *sprite.Region
+				normalRegion.Texture = sprite.NormalMap
+				params.Region = &normalRegion
+			}
+			params.Tint, params.CornerColors = WHITE, [4]RGBA8{}
+			params.Material, params.BlendMode = nil, BLEND_DEFAULT
+			NormalMaps.SetLayer(_render.Sprites.GetLayer())
+			NormalMaps.Draw(params)
+		}
 	})
 }
 
@@ -258,13 +340,21 @@ type LineRenderComponent struct {
 
 func (t *LineRenderComponent) ComponentSet(val interface{}) { *t = val.(LineRenderComponent) }
 
+// The points are in world space, so the bounds ignore the entity transform
+func lineRenderBounds(entity *EcsEntity, a interface{}) Rect {
+	line := a.(LineRenderComponent)
+	min := NewVector2f(MinFloat32(line.FromPoint.X, line.ToPoint.X), MinFloat32(line.FromPoint.Y, line.ToPoint.Y))
+	max := NewVector2f(MaxFloat32(line.FromPoint.X, line.ToPoint.X), MaxFloat32(line.FromPoint.Y, line.ToPoint.Y))
+	return Rect{Position: min, Size: max.Subtract(min)}
+}
+
 type LineRenderSystem struct {
 	EcsSystemImpl
 	Shapes *ShapeBatch
 }
 
 func (_render *LineRenderSystem) Update(dt float32) {
-	EachEntity(LineRenderComponent{}, func(entity *EcsEntity, a interface{}) {
+	EachVisibleEntityBounds(LineRenderComponent{}, lineRenderBounds, func(entity *EcsEntity, a interface{}) {
 		lineComp := a.(LineRenderComponent)
 		_render.Shapes.DrawLine(lineComp.FromPoint, lineComp.ToPoint, WHITE)
 	})
@@ -277,13 +367,20 @@ type TriangleRenderComponent struct {
 
 func (t *TriangleRenderComponent) ComponentSet(val interface{}) { *t = val.(TriangleRenderComponent) }
 
+// The triangle is inscribed in an ellipse with the radii of the component Dimensions
+func triangleRenderBounds(entity *EcsEntity, a interface{}) Rect {
+	dimensions := a.(TriangleRenderComponent).Dimensions
+	radius := MaxFloat32(AbsFloat32(dimensions.X), AbsFloat32(dimensions.Y))
+	return NewRect(entity.Pos.X-radius, entity.Pos.Y-radius, radius*2.0, radius*2.0)
+}
+
 type TriangleRenderSystem struct {
 	EcsSystemImpl
 	Shapes *ShapeBatch
 }
 
 func (_render *TriangleRenderSystem) Update(dt float32) {
-	EachEntity(TriangleRenderComponent{}, func(entity *EcsEntity, a interface{}) {
+	EachVisibleEntityBounds(TriangleRenderComponent{}, triangleRenderBounds, func(entity *EcsEntity, a interface{}) {
 		lineComp := a.(TriangleRenderComponent)
 		_render.Shapes.DrawTriangleRotated(entity.Pos, lineComp.Dimensions, WHITE, float32(entity.Rot))
 	})
@@ -302,7 +399,7 @@ type RectRenderSystem struct {
 }
 
 func (_render *RectRenderSystem) Update(dt float32) {
-	EachEntity(RectRenderComponent{}, func(entity *EcsEntity, a interface{}) {
+	EachVisibleEntity(RectRenderComponent{}, func(entity *EcsEntity, a interface{}) {
 		rectComp := a.(RectRenderComponent)
 		_render.Shapes.DrawRectRotated(entity.Pos, entity.Dimensions, rectComp.Tint, entity.Rot)
 	})
@@ -321,7 +418,7 @@ type FillRectRenderSystem struct {
 }
 
 func (_render *FillRectRenderSystem) Update(dt float32) {
-	EachEntity(FillRectRenderComponent{}, func(entity *EcsEntity, a interface{}) {
+	EachVisibleEntity(FillRectRenderComponent{}, func(entity *EcsEntity, a interface{}) {
 		rectComp := a.(FillRectRenderComponent)
 		_render.Shapes.DrawFillRectRotated(entity.Pos, entity.Dimensions, rectComp.Tint, entity.Rot)
 	})
@@ -333,13 +430,19 @@ type CircleRenderComponent struct {
 
 func (t *CircleRenderComponent) ComponentSet(val interface{}) { *t = val.(CircleRenderComponent) }
 
+// The circle radius is Dimensions.X
+func circleRenderBounds(entity *EcsEntity, a interface{}) Rect {
+	radius := AbsFloat32(entity.Dimensions.X)
+	return NewRect(entity.Pos.X-radius, entity.Pos.Y-radius, radius*2.0, radius*2.0)
+}
+
 type CircleRenderSystem struct {
 	EcsSystemImpl
 	Shapes *ShapeBatch
 }
 
 func (_render *CircleRenderSystem) Update(dt float32) {
-	EachEntity(CircleRenderComponent{}, func(entity *EcsEntity, a interface{}) {
+	EachVisibleEntityBounds(CircleRenderComponent{}, circleRenderBounds, func(entity *EcsEntity, a interface{}) {
 		_render.Shapes.DrawCircle(entity.Pos, entity.Dimensions.X, WHITE)
 	})
 }
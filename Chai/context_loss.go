@@ -0,0 +1,73 @@
+package chai
+
+import "syscall/js"
+
+/*
+When the browser drops the WebGL context every GL handle becomes invalid. Nothing is recreated eagerly:
+textures, render targets, shaders, batches and tilemaps remember the generation their handles were created
+in and rebuild them from their CPU-side data the next time they are used after contextGeneration changes.
+*/
+
+var contextLost bool
+
+// Incremented on every restore
+var contextGeneration uint32
+
+var loseContextExtension js.Value
+
+func initContextLoss() {
+	canvas.Call("addEventListener", "webglcontextlost", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		// Without preventDefault the browser never restores the context
+		args[0].Call("preventDefault")
+		contextLost = true
+		WarningF("[WEBGL]: context lost")
+		appRef.OnContextLost()
+		return nil
+	}))
+	canvas.Call("addEventListener", "webglcontextrestored", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		restoreContext()
+		return nil
+	}))
+}
+
+// The GL state that is set once at startup instead of by the draws
+func initGlState() {
+	canvasContext.Call("blendFunc", canvasContext.Get("SRC_ALPHA"), canvasContext.Get("ONE_MINUS_SRC_ALPHA"), canvasContext.Get("ONE"), canvasContext.Get("ONE"))
+	canvasContext.Call("enable", canvasContext.Get("BLEND"))
+	canvasContext.Call("viewport", 0, 0, currentWidth, currentHeight)
+}
+
+func restoreContext() {
+	contextGeneration++
+	contextLost = false
+	initGlState()
+	fullscreenQuadVao = js.Null()
+	renderTargetStack = renderTargetStack[:0]
+	LogF("[WEBGL]: context restored")
+	appRef.OnContextRestored()
+}
+
+// True between the loss of the WebGL context and its restoration, nothing is drawn meanwhile
+func IsContextLost() bool {
+	return contextLost
+}
+
+// Drops the context through the WEBGL_lose_context extension, to test how the game recovers
+func SimulateContextLoss() {
+	if loseContextExtension.IsUndefined() {
+		loseContextExtension = canvasContext.Call("getExtension", "WEBGL_lose_context")
+	}
+	if loseContextExtension.IsNull() {
+		WarningF("[WEBGL]: WEBGL_lose_context is not supported")
+		return
+	}
+	loseContextExtension.Call("loseContext")
+}
+
+// Restores a context dropped by SimulateContextLoss
+func SimulateContextRestore() {
+	if loseContextExtension.IsUndefined() || loseContextExtension.IsNull() {
+		return
+	}
+	loseContextExtension.Call("restoreContext")
+}
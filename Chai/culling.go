@@ -0,0 +1,259 @@
+package chai
+
+import "math"
+
+// Cell size, in world units, of the spatial indices the render systems cull with. World units are pixels at
+// the default zoom, so bounds of a few hundred pixels still fit in SPATIAL_HASH_MAX_CELLS
+const SPATIAL_HASH_DEFAULT_CELL_SIZE float32 = 64.0
+
+// Bounds covering more cells than this are kept in a single list that every query visits
+const SPATIAL_HASH_MAX_CELLS int = 64
+
+// Incremented once per scene draw, the spatial indices of the storages refresh their bounds when it changes
+var cullingFrame uint64
+
+// World space bounding box of the entity, its Dimensions rotated by Rot around Pos
+func EntityBounds(_entity *EcsEntity) Rect {
+	return RotatedBounds(_entity.Pos, _entity.Dimensions, _entity.Rot)
+}
+
+// Bounding box of a _dimensions sized rectangle centred at _center and rotated by _rotation degrees
+func RotatedBounds(_center, _dimensions Vector2f, _rotation float32) Rect {
+	halfSize := _dimensions.Scale(0.5)
+	if _rotation != 0.0 {
+		angle := float64(Deg2Rad(_rotation))
+		cos, sin := AbsFloat32(float32(math.Cos(angle))), AbsFloat32(float32(math.Sin(angle)))
+		halfSize = NewVector2f(halfSize.X*cos+halfSize.Y*sin, halfSize.X*sin+halfSize.Y*cos)
+	}
+	return Rect{Position: _center.Subtract(halfSize), Size: halfSize.Scale(2.0)}
+}
+
+/* ####### Spatial Hash ####### */
+
+type spatialCell struct {
+	x, y int32
+}
+
+type spatialEntry struct {
+	bounds     Rect
+	min, max   spatialCell
+	oversized  bool
+	queryStamp uint32
+}
+
+// Buckets entities by the grid cells their bounds overlap, so area queries only visit the nearby ones
+type SpatialHash struct {
+	CellSize  float32
+	cells     map[spatialCell][]*EcsEntity
+	entries   map[*EcsEntity]*spatialEntry
+	oversized []*EcsEntity
+	stamp     uint32
+}
+
+func NewSpatialHash(_cellSize float32) *SpatialHash {
+	if _cellSize <= 0.0 {
+		WarningF("SPATIAL HASH: Cell size must be positive, got %v", _cellSize)
+		_cellSize = SPATIAL_HASH_DEFAULT_CELL_SIZE
+	}
+	return &SpatialHash{
+		CellSize: _cellSize,
+		cells:    make(map[spatialCell][]*EcsEntity),
+		entries:  make(map[*EcsEntity]*spatialEntry),
+	}
+}
+
+func (sh *SpatialHash) cellOf(_point Vector2f) spatialCell {
+	return spatialCell{
+		x: int32(math.Floor(float64(_point.X / sh.CellSize))),
+		y: int32(math.Floor(float64(_point.Y / sh.CellSize))),
+	}
+}
+
+// Adds the entity, or moves it when it is already in the hash. Only re-buckets when the covered cells change
+func (sh *SpatialHash) Insert(_entity *EcsEntity, _bounds Rect) {
+	min, max := sh.cellOf(_bounds.Min()), sh.cellOf(_bounds.Max())
+	entry, ok := sh.entries[_entity]
+	if ok {
+		entry.bounds = _bounds
+		if entry.min == min && entry.max == max {
+			return
+		}
+		sh.unlink(_entity, entry)
+	} else {
+		entry = &spatialEntry{}
+		sh.entries[_entity] = entry
+	}
+	entry.bounds, entry.min, entry.max = _bounds, min, max
+
+	numOfCells := (int(max.x-min.x) + 1) * (int(max.y-min.y) + 1)
+	entry.oversized = numOfCells > SPATIAL_HASH_MAX_CELLS
+	if entry.oversized {
+		sh.oversized = append(sh.oversized, _entity)
+		return
+	}
+	for y := min.y; y <= max.y; y++ {
+		for x := min.x; x <= max.x; x++ {
+			cell := spatialCell{x, y}
+			sh.cells[cell] = append(sh.cells[cell], _entity)
+		}
+	}
+}
+
+func (sh *SpatialHash) Remove(_entity *EcsEntity) {
+	entry, ok := sh.entries[_entity]
+	if !ok {
+		return
+	}
+	sh.unlink(_entity, entry)
+	delete(sh.entries, _entity)
+}
+
+func (sh *SpatialHash) unlink(_entity *EcsEntity, _entry *spatialEntry) {
+	if _entry.oversized {
+		sh.oversized = removeSpatialEntity(sh.oversized, _entity)
+		return
+	}
+	for y := _entry.min.y; y <= _entry.max.y; y++ {
+		for x := _entry.min.x; x <= _entry.max.x; x++ {
+			cell := spatialCell{x, y}
+			bucket := removeSpatialEntity(sh.cells[cell], _entity)
+			if len(bucket) == 0 {
+				delete(sh.cells, cell)
+			} else {
+				sh.cells[cell] = bucket
+			}
+		}
+	}
+}
+
+func removeSpatialEntity(_list []*EcsEntity, _entity *EcsEntity) []*EcsEntity {
+	for i, e := range _list {
+		if e == _entity {
+			last := len(_list) - 1
+			_list[i] = _list[last]
+			return _list[:last]
+		}
+	}
+	return _list
+}
+
+func (sh *SpatialHash) Clear() {
+	sh.cells = make(map[spatialCell][]*EcsEntity)
+	sh.entries = make(map[*EcsEntity]*spatialEntry)
+	sh.oversized = sh.oversized[:0]
+}
+
+func (sh *SpatialHash) Len() int {
+	return len(sh.entries)
+}
+
+// Calls f once for every entity whose bounds overlap _area
+func (sh *SpatialHash) Query(_area Rect, f func(entity *EcsEntity)) {
+	sh.stamp++
+	visit := func(_entity *EcsEntity) {
+		entry := sh.entries[_entity]
+		if entry.queryStamp == sh.stamp || !entry.bounds.Overlaps(_area) {
+			return
+		}
+		entry.queryStamp = sh.stamp
+		f(_entity)
+	}
+
+	for _, entity := range sh.oversized {
+		visit(entity)
+	}
+	min, max := sh.cellOf(_area.Min()), sh.cellOf(_area.Max())
+	if (int64(max.x-min.x)+1)*(int64(max.y-min.y)+1) > int64(len(sh.cells)) {
+		// The area covers more cells than are occupied, walking the occupied ones is cheaper
+		for cell, bucket := range sh.cells {
+			if cell.x < min.x || cell.x > max.x || cell.y < min.y || cell.y > max.y {
+				continue
+			}
+			for _, entity := range bucket {
+				visit(entity)
+			}
+		}
+		return
+	}
+	for y := min.y; y <= max.y; y++ {
+		for x := min.x; x <= max.x; x++ {
+			for _, entity := range sh.cells[spatialCell{x, y}] {
+				visit(entity)
+			}
+		}
+	}
+}
+
+/* ####### Culled Iteration ####### */
+
+// Like EachEntity, but only visits the entities in the view of the render camera and its layer mask.
+// The bounds come from the entity Dimensions and rotation, entities without Dimensions are never culled
+func EachVisibleEntity(val interface{}, f func(entity *EcsEntity, a interface{})) {
+	EachVisibleEntityBounds(val, nil, f)
+}
+
+// EachVisibleEntity with the bounds computed by _bounds, for components that are not sized by the entity Dimensions
+func EachVisibleEntityBounds(val interface{}, _bounds func(entity *EcsEntity, a interface{}) Rect, f func(entity *EcsEntity, a interface{})) {
+	storage := GetStorage(&current_scene.Ecs_engine, val)
+	if current_scene.DisableCulling {
+		for entity, a := range storage.list {
+			if !IsRenderLayerVisible(entity.RenderLayer) {
+				renderStats.EntitiesCulled++
+				continue
+			}
+			renderStats.EntitiesDrawn++
+			f(entity, a)
+		}
+		return
+	}
+
+	storage.refreshSpatialIndex(current_scene.cullingCellSize(), _bounds)
+	visited := 0
+	visit := func(entity *EcsEntity) {
+		if !IsRenderLayerVisible(entity.RenderLayer) {
+			return
+		}
+		visited++
+		f(entity, storage.list[entity])
+	}
+	for _, entity := range storage.unbounded {
+		visit(entity)
+	}
+	storage.spatial.Query(GetRenderCamera().GetViewBounds(), visit)
+
+	renderStats.EntitiesDrawn += visited
+	renderStats.EntitiesCulled += len(storage.list) - visited
+}
+
+func (scene *Scene) cullingCellSize() float32 {
+	if scene.CullingCellSize <= 0.0 {
+		return SPATIAL_HASH_DEFAULT_CELL_SIZE
+	}
+	return scene.CullingCellSize
+}
+
+// Brings the bounds of the storage index up to date, at most once per scene draw
+func (s *BasicStorage) refreshSpatialIndex(_cellSize float32, _bounds func(entity *EcsEntity, a interface{}) Rect) {
+	if s.spatial == nil || s.spatial.CellSize != _cellSize {
+		s.spatial = NewSpatialHash(_cellSize)
+	} else if s.spatialFrame == cullingFrame {
+		return
+	}
+	s.spatialFrame = cullingFrame
+
+	s.unbounded = s.unbounded[:0]
+	for entity, a := range s.list {
+		var bounds Rect
+		if _bounds != nil {
+			bounds = _bounds(entity, a)
+		} else {
+			bounds = EntityBounds(entity)
+		}
+		if bounds.Size == Vector2fZero {
+			s.spatial.Remove(entity)
+			s.unbounded = append(s.unbounded, entity)
+			continue
+		}
+		s.spatial.Insert(entity, bounds)
+	}
+}
@@ -54,3 +54,16 @@ func pixelBufferToJsPixelBubffer(_buffer []Pixel) js.Value {
 	js.CopyBytesToJS(jsPixels, pixelsBytes)
 	return jsPixels
 }
+
+func spriteVertexSliceAsBytes(_verts []spriteVertex) []byte {
+	if len(_verts) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&_verts[0])), len(_verts)*spriteVertexSize)
+}
+
+func float32BufferToJsFloat32Buffer(_buffer []float32) js.Value {
+	jsBytes := js.Global().Get("Uint8Array").New(len(_buffer) * 4)
+	js.CopyBytesToJS(jsBytes, unsafe.Slice((*byte)(unsafe.Pointer(&_buffer[0])), len(_buffer)*4))
+	return js.Global().Get("Float32Array").New(jsBytes.Get("buffer"), 0, len(_buffer))
+}
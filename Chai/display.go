@@ -0,0 +1,215 @@
+package chai
+
+import (
+	"fmt"
+	"math"
+	"syscall/js"
+)
+
+// How the canvas reacts to the size of its parent element, App.Width and App.Height are the virtual resolution
+type ResizeMode int
+
+const (
+	// The canvas keeps App.Width x App.Height and the page decides how it is displayed
+	RESIZE_FIXED ResizeMode = iota
+	// The canvas fills its parent and the App.Width x App.Height image is stretched to it, ignoring the aspect ratio
+	RESIZE_STRETCH
+	// The canvas fills its parent and App.Width x App.Height is scaled uniformly to fit, the rest is filled with App.LetterboxColor
+	RESIZE_FIT
+	// The canvas fills its parent and the camera shows more of the world instead of scaling it
+	RESIZE_EXPAND
+)
+
+type ResizeEvent struct {
+	// Size of the canvas backbuffer in pixels
+	CanvasWidth, CanvasHeight int
+	// Area of the canvas in pixels the scene is drawn to, smaller than the canvas when letterboxed
+	Viewport Rect
+	// Canvas pixels per virtual pixel
+	PixelScale       float32
+	DevicePixelRatio float32
+}
+
+type displayLayout struct {
+	canvasWidth, canvasHeight int
+	// Size of the canvas element on the page, zero when the page styles it
+	cssWidth, cssHeight  float32
+	viewportX, viewportY int
+	viewportWidth        int
+	viewportHeight       int
+	pixelScale           float32
+	devicePixelRatio     float32
+}
+
+var display displayLayout
+var displayDirty bool
+
+func computeDisplayLayout(_app *App, _containerWidth, _containerHeight, _devicePixelRatio float32) displayLayout {
+	ratio := float32(1.0)
+	if _app.HighDPI && _devicePixelRatio > 0.0 {
+		ratio = _devicePixelRatio
+	}
+	layout := displayLayout{pixelScale: ratio, devicePixelRatio: _devicePixelRatio}
+	virtualWidth, virtualHeight := float32(_app.Width), float32(_app.Height)
+
+	switch _app.ResizeMode {
+	case RESIZE_STRETCH:
+		layout.cssWidth, layout.cssHeight = _containerWidth, _containerHeight
+		layout.canvasWidth, layout.canvasHeight = roundToInt(virtualWidth*ratio), roundToInt(virtualHeight*ratio)
+	case RESIZE_FIT, RESIZE_EXPAND:
+		layout.cssWidth, layout.cssHeight = _containerWidth, _containerHeight
+		layout.canvasWidth, layout.canvasHeight = roundToInt(_containerWidth*ratio), roundToInt(_containerHeight*ratio)
+	default:
+		if _app.HighDPI {
+			layout.cssWidth, layout.cssHeight = virtualWidth, virtualHeight
+		}
+		layout.canvasWidth, layout.canvasHeight = roundToInt(virtualWidth*ratio), roundToInt(virtualHeight*ratio)
+	}
+	layout.canvasWidth, layout.canvasHeight = MaxInt(layout.canvasWidth, 1), MaxInt(layout.canvasHeight, 1)
+	layout.viewportWidth, layout.viewportHeight = layout.canvasWidth, layout.canvasHeight
+
+	if _app.ResizeMode == RESIZE_FIT && virtualWidth > 0.0 && virtualHeight > 0.0 {
+		layout.pixelScale = MinFloat32(float32(layout.canvasWidth)/virtualWidth, float32(layout.canvasHeight)/virtualHeight)
+		layout.viewportWidth = MaxInt(roundToInt(virtualWidth*layout.pixelScale), 1)
+		layout.viewportHeight = MaxInt(roundToInt(virtualHeight*layout.pixelScale), 1)
+		layout.viewportX = (layout.canvasWidth - layout.viewportWidth) / 2
+		layout.viewportY = (layout.canvasHeight - layout.viewportHeight) / 2
+	}
+	return layout
+}
+
+func roundToInt(_v float32) int {
+	return int(math.Round(float64(_v)))
+}
+
+// Size of the element the canvas fills, in CSS pixels
+func canvasContainerSize() (float32, float32) {
+	parent := canvas.Get("parentElement")
+	if parent.IsNull() || parent.IsUndefined() {
+		return float32(js.Global().Get("innerWidth").Float()), float32(js.Global().Get("innerHeight").Float())
+	}
+	return float32(parent.Get("clientWidth").Float()), float32(parent.Get("clientHeight").Float())
+}
+
+func initDisplay() {
+	markDirty := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		displayDirty = true
+		return nil
+	})
+	js.Global().Call("addEventListener", "resize", markDirty)
+	js.Global().Get("document").Call("addEventListener", "fullscreenchange", markDirty)
+
+	parent := canvas.Get("parentElement")
+	resizeObserver := js.Global().Get("ResizeObserver")
+	if !resizeObserver.IsUndefined() && !parent.IsNull() {
+		resizeObserver.New(markDirty).Call("observe", parent)
+	}
+
+	applyDisplayLayout()
+}
+
+// Resizes the canvas to the current layout and lays the global Cam out on it
+func applyDisplayLayout() {
+	displayDirty = false
+	containerWidth, containerHeight := canvasContainerSize()
+	layout := computeDisplayLayout(appRef, containerWidth, containerHeight, float32(js.Global().Get("devicePixelRatio").Float()))
+	if layout == display {
+		return
+	}
+	display = layout
+
+	style := canvas.Get("style")
+	if layout.cssWidth > 0.0 && layout.cssHeight > 0.0 {
+		// Block level, an inline canvas adds a baseline gap that grows the parent on every resize
+		style.Set("display", "block")
+		style.Set("width", fmt.Sprintf("%vpx", layout.cssWidth))
+		style.Set("height", fmt.Sprintf("%vpx", layout.cssHeight))
+	}
+	if canvas.Get("width").Int() != layout.canvasWidth || canvas.Get("height").Int() != layout.canvasHeight {
+		canvas.Set("width", layout.canvasWidth)
+		canvas.Set("height", layout.canvasHeight)
+	}
+	currentWidth, currentHeight = layout.canvasWidth, layout.canvasHeight
+
+	Cam.setViewport(layout.viewportX, layout.viewportY, layout.viewportWidth, layout.viewportHeight)
+	Cam.pixelScale = layout.pixelScale
+	Cam.Update(*appRef)
+
+	if started {
+		appRef.OnResize(&ResizeEvent{
+			CanvasWidth:      layout.canvasWidth,
+			CanvasHeight:     layout.canvasHeight,
+			Viewport:         GetViewportRect(),
+			PixelScale:       layout.pixelScale,
+			DevicePixelRatio: layout.devicePixelRatio,
+		})
+	}
+}
+
+// Area of the canvas in pixels the scene is drawn to, the whole canvas unless the resize mode letterboxes it
+func GetViewportRect() Rect {
+	return NewRect(float32(display.viewportX), float32(display.viewportY), float32(display.viewportWidth), float32(display.viewportHeight))
+}
+
+// Canvas pixels per virtual pixel
+func GetPixelScale() float32 {
+	return display.pixelScale
+}
+
+func GetDevicePixelRatio() float32 {
+	return display.devicePixelRatio
+}
+
+/* ####### Fullscreen ####### */
+
+// Makes the parent of the canvas fullscreen, browsers only allow it from an input event handler such as a click or key press
+func EnterFullscreen() {
+	target := canvas.Get("parentElement")
+	if target.IsNull() {
+		target = canvas
+	}
+	if target.Get("requestFullscreen").IsUndefined() {
+		WarningF("FULLSCREEN: Not supported by the browser")
+		return
+	}
+	catchFullscreenError(target.Call("requestFullscreen"))
+}
+
+func ExitFullscreen() {
+	if !IsFullscreen() {
+		return
+	}
+	catchFullscreenError(js.Global().Get("document").Call("exitFullscreen"))
+}
+
+func IsFullscreen() bool {
+	element := js.Global().Get("document").Get("fullscreenElement")
+	return !element.IsNull() && !element.IsUndefined()
+}
+
+func ToggleFullscreen() {
+	if IsFullscreen() {
+		ExitFullscreen()
+	} else {
+		EnterFullscreen()
+	}
+}
+
+func catchFullscreenError(_promise js.Value) {
+	if _promise.IsUndefined() {
+		return
+	}
+	var onDone, onError js.Func
+	onDone = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		onDone.Release()
+		onError.Release()
+		return nil
+	})
+	onError = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		WarningF("FULLSCREEN: %v", args[0].Get("message").String())
+		onDone.Release()
+		onError.Release()
+		return nil
+	})
+	_promise.Call("then", onDone, onError)
+}
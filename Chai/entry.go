@@ -8,13 +8,26 @@ import (
 var app_url string
 
 type App struct {
-	Width    int
-	Height   int
-	Title    string
-	OnStart  func()
-	OnUpdate func(float32)
+	// Virtual resolution, the canvas size in RESIZE_FIXED mode
+	Width      int
+	Height     int
+	Title      string
+	ResizeMode ResizeMode
+	// Sizes the canvas in device pixels so it stays sharp on high-DPI screens
+	HighDPI bool
+	// Fills the bars around the scene in RESIZE_FIT mode
+	LetterboxColor RGBA8
+	OnStart        func()
+	OnUpdate       func(float32)
+	// Called once per frame, in the pass of the first camera of the scene. What it draws with Sprites, Particles,
+	// Shapes and NormalMaps is drawn by every camera
 	OnDraw   func()
 	OnEvent  func(*AppEvent)
+	OnResize func(*ResizeEvent)
+	// Called when the browser drops the WebGL context and once it is back. The engine recreates its
+	// resources by itself, render targets come back cleared
+	OnContextLost     func()
+	OnContextRestored func()
 }
 
 // Used to make the update function only available in the local App struct, to the whole file
@@ -50,12 +63,14 @@ var Cam Camera2D
 var Shapes ShapeBatch
 var Sprites SpriteBatch
 
+// Drawn after Sprites with additive blending, see ParticleRenderSystem
+var Particles SpriteBatch
+
 var started bool = false
 
 var physics_world PhysicsWorld
 
 var MouseCanvasPos Vector2f
-var canvasBoundingClientRect js.Value
 
 var mousePressed MouseButton
 var numOfFingersTouching uint8
@@ -84,6 +99,21 @@ func (_app *App) fillDefaults() {
 	if _app.OnEvent == nil {
 		_app.OnEvent = func(ae *AppEvent) {
 
+		}
+	}
+	if _app.OnResize == nil {
+		_app.OnResize = func(re *ResizeEvent) {
+
+		}
+	}
+	if _app.OnContextLost == nil {
+		_app.OnContextLost = func() {
+
+		}
+	}
+	if _app.OnContextRestored == nil {
+		_app.OnContextRestored = func() {
+
 		}
 	}
 }
@@ -111,11 +141,8 @@ func Run(_app *App) {
 	canvasContext = canvas.Call("getContext", "webgl2")
 	Assert(!canvasContext.IsNull(), "CANVAS: Failed to Get Context")
 
-	canvas.Set("width", _app.Width)
-	canvas.Set("height", _app.Height)
-
-	canvasContext.Call("blendFunc", canvasContext.Get("SRC_ALPHA"), canvasContext.Get("ONE_MINUS_SRC_ALPHA"), canvasContext.Get("ONE"), canvasContext.Get("ONE"))
-	canvasContext.Call("enable", canvasContext.Get("BLEND"))
+	initGlState()
+	initContextLoss()
 
 	tempStart = _app.OnStart
 	tempUpdate = _app.OnUpdate
@@ -138,13 +165,17 @@ func Run(_app *App) {
 
 	// if I put it above the "js_start" then it would take a lot of time to run
 	Cam.Init(*_app)
+	initDisplay()
 	Cam.Update(*_app)
 
 	Shapes.Init()
 	Assert(Shapes.Initialized, "Shapes Rendering was not initialized successfully")
 
 	Sprites.Init("")
-	canvasContext.Call("viewport", 0, 0, appRef.Width, appRef.Height)
+	Particles.Init("")
+	Particles.BlendMode = BLEND_ADDITIVE
+	NormalMaps.Init("")
+	canvasContext.Call("viewport", 0, 0, currentWidth, currentHeight)
 
 	mousePressed = MouseButtonNull
 	LeftMouseJustPressed.init()
@@ -168,20 +199,19 @@ func Run(_app *App) {
 		_app.OnEvent(ae)
 	})
 	addEventListenerWindow(JS_MOUSEMOVED, func(ae *AppEvent) {
-		canvasBoundingClientRect = canvas.Call("getBoundingClientRect")
+		MouseCanvasPos = ClientToScreen(float32(ae.GetJsEvent().Get("clientX").Float()), float32(ae.GetJsEvent().Get("clientY").Float()))
+		_app.OnEvent(ae)
+	})
 
-		MouseCanvasPos.X = (float32(ae.GetJsEvent().Get("clientX").Int()) - float32(canvasBoundingClientRect.Get("left").Int())) / float32(canvasBoundingClientRect.Get("width").Int()) * float32(canvas.Get("width").Int())
-		MouseCanvasPos.Y = float32(canvas.Get("height").Int()) - (float32(ae.GetJsEvent().Get("clientY").Int())-float32(canvasBoundingClientRect.Get("top").Int()))/float32(canvasBoundingClientRect.Get("height").Int())*float32(canvas.Get("height").Int())
+	addEventListenerWindow(JS_WHEEL, func(ae *AppEvent) {
 		_app.OnEvent(ae)
 	})
 
 	addEventListenerWindow(JS_TOUCHSTART, func(ae *AppEvent) {
 		numOfFingersTouching = ae.NUM_FINGERS
 
-		canvasBoundingClientRect = canvas.Call("getBoundingClientRect")
-
-		MouseCanvasPos.X = (float32(ae.GetJsEvent().Get("touches").Index(0).Get("clientX").Int()) - float32(canvasBoundingClientRect.Get("left").Int())) / float32(canvasBoundingClientRect.Get("width").Int()) * float32(canvas.Get("width").Int())
-		MouseCanvasPos.Y = float32(canvas.Get("height").Int()) - (float32(ae.GetJsEvent().Get("touches").Index(0).Get("clientY").Int())-float32(canvasBoundingClientRect.Get("top").Int()))/float32(canvasBoundingClientRect.Get("height").Int())*float32(canvas.Get("height").Int())
+		touch := ae.GetJsEvent().Get("touches").Index(0)
+		MouseCanvasPos = ClientToScreen(float32(touch.Get("clientX").Float()), float32(touch.Get("clientY").Float()))
 
 		_app.OnEvent(ae)
 	})
@@ -191,10 +221,8 @@ func Run(_app *App) {
 	})
 
 	addEventListenerWindow(JS_TOUCHMOVED, func(ae *AppEvent) {
-		canvasBoundingClientRect = canvas.Call("getBoundingClientRect")
-
-		MouseCanvasPos.X = (float32(ae.GetJsEvent().Get("touches").Index(0).Get("clientX").Int()) - float32(canvasBoundingClientRect.Get("left").Int())) / float32(canvasBoundingClientRect.Get("width").Int()) * float32(canvas.Get("width").Int())
-		MouseCanvasPos.Y = float32(canvas.Get("height").Int()) - (float32(ae.GetJsEvent().Get("touches").Index(0).Get("clientY").Int())-float32(canvasBoundingClientRect.Get("top").Int()))/float32(canvasBoundingClientRect.Get("height").Int())*float32(canvas.Get("height").Int())
+		touch := ae.GetJsEvent().Get("touches").Index(0)
+		MouseCanvasPos = ClientToScreen(float32(touch.Get("clientX").Float()), float32(touch.Get("clientY").Float()))
 		_app.OnEvent(ae)
 	})
 
@@ -242,11 +270,13 @@ func JSUpdate(this js.Value, inputs []js.Value) interface{} {
 	if deltaTime > CAP_DELTA_TIME {
 		deltaTime = CAP_DELTA_TIME
 	}
-	currentWidth = canvas.Get("width").Int()
-	currentHeight = canvas.Get("height").Int()
+	if displayDirty {
+		applyDisplayLayout()
+	}
 	tempUpdate(deltaTime)
 	current_scene.OnUpdate(deltaTime)
 	updateInput()
+	Cam.updateShake(deltaTime)
 	Cam.Update(*appRef)
 	ElapsedTime += deltaTime
 	physics_world.box2dWorld.Step(float64(deltaTime), 6, 12)
@@ -254,19 +284,32 @@ func JSUpdate(this js.Value, inputs []js.Value) interface{} {
 }
 
 func JSDraw(this js.Value, inputs []js.Value) interface{} {
-	if !started {
+	if !started || contextLost {
 		return nil
 	}
+	resetRenderStats()
+	drawFrame()
+	// The canvas is only readable until the browser presents it, so the recorders capture right after drawing
+	captureFrameRecorders()
+	capturePendingFrames()
+	return nil
+}
+
+// Draws the current scene with its post-processing into the bound framebuffer, the size of the canvas
+func drawFrame() {
 	canvasContext.Call("viewport", 0, 0, currentWidth, currentHeight)
-	setBackgroundColor(current_scene.Background)
-	canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+
+	postProcess := &current_scene.PostProcess
+	if postProcess.IsActive() {
+		postProcess.Begin(currentWidth, currentHeight)
+	}
 
 	//Shapes.DrawLine(NewVector2f(0.0, 0.0), NewVector2f(2.5, 0.5), RGBA8{255, 255, 0, 255})
-	tempDraw()
-	current_scene.OnDraw()
-	Sprites.Render(&Cam)
-	Shapes.Render(&Cam)
-	return nil
+	drawSceneCameras(currentWidth, currentHeight)
+
+	if postProcess.IsActive() {
+		postProcess.End()
+	}
 }
 
 func setBackgroundColor(_color RGBA8) {
@@ -71,6 +71,7 @@ const JS_MOUSEMOVED JsEventType = "mousemove"
 const JS_TOUCHMOVED JsEventType = "touchmove"
 const JS_TOUCHSTART JsEventType = "touchstart"
 const JS_TOUCHEND JsEventType = "touchend"
+const JS_WHEEL JsEventType = "wheel"
 
 // ---------------------------------------------
 
@@ -148,6 +149,19 @@ func parseJSEvent(event jsEvent) *AppEvent {
 			OffsetY: 0,
 			Button:  event.Get("button").Int(),
 		}
+	case JS_WHEEL:
+		return &AppEvent{
+			event:   event,
+			Type:    eventType,
+			Code:    CodeNull,
+			Key:     KeyNull,
+			OffsetX: event.Get("offsetX").Int(),
+			OffsetY: event.Get("offsetY").Int(),
+			Button:  MouseButtonNull,
+			DeltaX:  event.Get("deltaX").Float(),
+			DeltaY:  event.Get("deltaY").Float(),
+			DeltaZ:  event.Get("deltaZ").Float(),
+		}
 	case JS_TOUCHSTART:
 		return &AppEvent{
 			event:       event,
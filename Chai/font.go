@@ -2,13 +2,10 @@ package chai
 
 import (
 	"image"
-	"image/color"
 	"image/draw"
-	"io"
-	"net/http"
+	"sort"
 
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/opentype"
 
 	"golang.org/x/image/math/fixed"
 )
@@ -284,332 +281,341 @@ type FontBatchAtlas struct {
 	textureAtlas Texture2D
 	sPatch       SpriteBatch
 	fontSettings FontBatchSettings
+	// Rasterizes the runes missing from charAtlasSet when they are first drawn
+	cache *glyphCache
 }
 
 type CharAtlasGlyph struct {
 	uv1           Vector2f
 	uv2           Vector2f
 	size, bearing Vector2f
-	advance       float32
+	// Bottom-left of the glyph pixels from the pen position on the baseline
+	offset  Vector2f
+	advance float32
+	// Index of the glyph page holding the pixels, -1 for blank glyphs
+	page int
 }
 
 type FontBatchSettings struct {
 	FontSize, DPI, CharDistance, LineHeight float32
 	Arabic                                  bool
+	// Empty pixels around every glyph in the atlas pages, keeps neighbours from bleeding in with linear
+	// filtering or mipmaps. GLYPH_ATLAS_GAP when 0
+	GlyphPadding int
 }
 
 func (self *FontBatchAtlas) Init() {
 	self.charAtlasSet = make(map[rune]CharAtlasGlyph)
+	self.cache = newGlyphCache()
 	self.sPatch.Init("font.shader")
 
 }
 
+// Pixels left empty around every glyph of the atlas pages when FontBatchSettings.GlyphPadding is 0
 const GLYPH_ATLAS_GAP = 5
 
+// Largest side of a baked atlas page, lowered to the MAX_TEXTURE_SIZE of the GPU when that is smaller
+const GLYPH_ATLAS_MAX_SIZE = 2048
+
 func LoadFontToAtlas(_fontPath string, _fontSettings *FontBatchSettings) FontBatchAtlas {
 	var tempFont FontBatchAtlas
 	tempFont.Init()
 	tempFont.fontSettings = *_fontSettings
 
-	resp, err := http.Get(app_url + "/" + _fontPath)
-	if err != nil {
-		LogF(err.Error())
+	loadedFace, ok := loadFontFace(_fontPath, _fontSettings)
+	if !ok {
+		return tempFont
 	}
-	defer resp.Body.Close()
+	tempFont.cache.faces = append(tempFont.cache.faces, loadedFace)
+	tempFont.cache.padding = tempFont.fontSettings.glyphPadding()
+	tempFont.bakeGlyphs(loadedFace.face)
+	return tempFont
+}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		LogF(err.Error())
+func (self *FontBatchSettings) glyphPadding() int {
+	if self.GlyphPadding <= 0 {
+		return GLYPH_ATLAS_GAP
 	}
+	return self.GlyphPadding
+}
 
-	f, err := opentype.Parse(data)
-
-	face, err := opentype.NewFace(f, &opentype.FaceOptions{
-		Size:    float64(_fontSettings.FontSize),
-		DPI:     float64(_fontSettings.DPI),
-		Hinting: font.HintingFull,
-	})
-	if err != nil {
-		LogF(err.Error())
+// The runes rasterized when the font is loaded, the others are rasterized the first time they are drawn
+func bakedRunes(_arabic bool) []rune {
+	runes := make([]rune, 0, 127-32+len(ARABIC_UNICODE))
+	for i := 32; i < 127; i++ {
+		runes = append(runes, rune(i))
 	}
+	if _arabic {
+		runes = append(runes, ARABIC_UNICODE...)
+	}
+	return runes
+}
 
-	max_width, max_height := int(0), int(0)
-
-	dot := fixed.Point26_6{X: fixed.Int26_6(face.Metrics().Ascent), Y: 0}
-	for i := 33; i < 127; i++ {
-		char := rune(i)
+type bakedGlyph struct {
+	char   rune
+	pixels *image.RGBA
+	glyph  CharAtlasGlyph
+}
 
-		_, img, _, _, ok := face.Glyph(dot, char)
+// Rasterizes the baked runes and uploads the pages they are packed into
+func (self *FontBatchAtlas) bakeGlyphs(_face font.Face) {
+	glyphs, pageImages := bakeGlyphPages(_face, bakedRunes(self.fontSettings.Arabic), self.fontSettings.glyphPadding(), MinInt(GLYPH_ATLAS_MAX_SIZE, GetMaxTextureSize()))
+	firstPage := len(self.cache.pages)
+	for char, glyph := range glyphs {
+		if glyph.page >= 0 {
+			glyph.page += firstPage
+		}
+		self.charAtlasSet[char] = glyph
+	}
+	for _, img := range pageImages {
+		self.cache.pages = append(self.cache.pages, &glyphPage{texture: LoadTextureFromImg(img)})
+	}
+	if len(pageImages) > 0 {
+		self.textureAtlas = self.cache.pages[firstPage].texture
+	}
+}
 
+// Rasterizes _runes and packs them into as many pages of at most _maxSize as they need. The page of every
+// glyph indexes the returned images, blank glyphs have none
+func bakeGlyphPages(_face font.Face, _runes []rune, _padding, _maxSize int) (map[rune]CharAtlasGlyph, []*image.RGBA) {
+	charAtlasSet := make(map[rune]CharAtlasGlyph)
+	var glyphs []bakedGlyph
+	var sizes []Vector2i
+	for _, char := range _runes {
+		if _, ok := charAtlasSet[char]; ok {
+			continue
+		}
+		dr, img, maskp, ad, ok := _face.Glyph(fixed.Point26_6{}, char)
 		if !ok {
-			//LogF("Failed to load rune: %v", char)
+			continue
 		}
-
-		max_width += img.Bounds().Dx() + GLYPH_ATLAS_GAP
-		if max_height < img.Bounds().Dy() {
-			max_height = img.Bounds().Dy()
+		if char == ' ' {
+			charAtlasSet[char] = CharAtlasGlyph{uv1: Vector2fZero, uv2: Vector2fZero, size: Vector2fZero, bearing: Vector2fOne, advance: float32(ad) / float32(1<<6), page: -1}
+			continue
 		}
-	}
-
-	for _, c := range ARABIC_UNICODE {
-		_, img, _, _, ok := face.Glyph(dot, c)
-
+		bounds, _, ok := _face.GlyphBounds(char)
 		if !ok {
-			//LogF("Failed to load rune: %v", c)
+			continue
 		}
 
-		max_width += img.Bounds().Dx() + GLYPH_ATLAS_GAP
-		if max_height < img.Bounds().Dy() {
-			max_height = img.Bounds().Dy()
+		// The Arabic glyphs are placed from the right, they keep their full width as bearing
+		bearingX := float32(bounds.Max.X) / 64.0
+		if char >= 127 {
+			bearingX -= float32(bounds.Min.X) / 64.0
+		}
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		glyph := CharAtlasGlyph{
+			size:    NewVector2f(float32(width), float32(height)),
+			bearing: NewVector2f(bearingX, float32(-bounds.Max.Y)/64.0),
+			offset:  NewVector2f(float32(dr.Min.X), float32(-dr.Max.Y)),
+			advance: float32(ad) / 64.0,
+			page:    -1,
+		}
+		if width == 0 || height == 0 {
+			charAtlasSet[char] = glyph
+			continue
 		}
+		// The mask is reused by the next call to Glyph
+		pixels := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(pixels, pixels.Bounds(), img, maskp, draw.Src)
+		glyphs = append(glyphs, bakedGlyph{char: char, pixels: pixels, glyph: glyph})
+		sizes = append(sizes, NewVector2i(width, height))
 	}
-	atlas_img := image.NewRGBA(image.Rect(0, 0, max_width, max_height))
-	draw.Draw(atlas_img, atlas_img.Bounds(), &image.Uniform{color.RGBA{0, 0, 0, 0}}, image.ZP, draw.Src)
 
-	x_offset := 0
-	y_offset := 0
+	pages, positions, pageSizes := packGlyphs(sizes, _maxSize, _padding)
 
-	for i := 32; i < 127; i++ {
-		char := rune(i)
-		_, img, _, ad, ok := face.Glyph(dot, char)
-		if !ok {
-			//LogF("Failed To Draw Letter: %v", char)
+	pageImages := make([]*image.RGBA, len(pageSizes))
+	for i, size := range pageSizes {
+		pageImages[i] = image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	}
+	for i := range glyphs {
+		baked := &glyphs[i]
+		if pages[i] < 0 {
+			WarningF("[FONT]: the glyph of %q (%vx%v) doesn't fit in a %vx%v atlas", baked.char, sizes[i].X, sizes[i].Y, _maxSize, _maxSize)
+			continue
 		}
-		y_offset = img.Bounds().Dy()
+		position, pageSize := positions[i], pageSizes[pages[i]]
+		target := image.Rect(position.X, position.Y, position.X+sizes[i].X, position.Y+sizes[i].Y)
+		draw.Draw(pageImages[pages[i]], target, baked.pixels, image.Point{}, draw.Src)
+
+		baked.glyph.page = pages[i]
+		baked.glyph.uv1 = NewVector2f(float32(target.Min.X)/float32(pageSize.X), float32(target.Min.Y)/float32(pageSize.Y))
+		baked.glyph.uv2 = NewVector2f(float32(target.Max.X)/float32(pageSize.X), float32(target.Max.Y)/float32(pageSize.Y))
+		charAtlasSet[baked.char] = baked.glyph
+	}
+	return charAtlasSet, pageImages
+}
 
-		if char == ' ' {
-			tempFont.charAtlasSet[char] = CharAtlasGlyph{uv1: Vector2fZero, uv2: Vector2fZero, size: Vector2fZero, bearing: Vector2fOne, advance: float32(ad) / float32(1<<6)}
+// Lays glyphs of the given sizes out over pages no larger than _maxSize, each the smallest power of two that
+// holds the glyphs left. Returns the page of every glyph, -1 for those too large for any page, its top-left
+// in that page and the size of every page
+func packGlyphs(_sizes []Vector2i, _maxSize, _padding int) ([]int, []Vector2i, []Vector2i) {
+	pages := make([]int, len(_sizes))
+	positions := make([]Vector2i, len(_sizes))
+	var pageSizes []Vector2i
+
+	// Tallest first packs the skyline the tightest
+	remaining := make([]int, 0, len(_sizes))
+	for i, size := range _sizes {
+		pages[i] = -1
+		if size.X+2*_padding > _maxSize || size.Y+2*_padding > _maxSize {
 			continue
 		}
+		remaining = append(remaining, i)
+	}
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return _sizes[remaining[i]].Y > _sizes[remaining[j]].Y
+	})
 
-		bounds, _, ok := face.GlyphBounds(char)
-		if !ok {
-			//LogF("Failed to load bounds of rune: %v", char)
+	for len(remaining) > 0 {
+		area := 0
+		for _, i := range remaining {
+			area += (_sizes[i].X + _padding) * (_sizes[i].Y + _padding)
 		}
-
-		tempFont.charAtlasSet[char] = CharAtlasGlyph{
-			NewVector2f(float32(x_offset)/float32(max_width), 0),
-			NewVector2f(float32(x_offset+img.Bounds().Dx())/float32(max_width), float32(y_offset)/float32(max_height)),
-			NewVector2f(float32(img.Bounds().Dx()), float32(img.Bounds().Dy())),
-			NewVector2f(float32(bounds.Max.X)/64.0, float32(-bounds.Max.Y)/64.0),
-			float32(ad) / 64.0,
+		width, height := 1, 1
+		for width*height < area && (width < _maxSize || height < _maxSize) {
+			if width <= height {
+				width = MinInt(width*2, _maxSize)
+			} else {
+				height = MinInt(height*2, _maxSize)
+			}
 		}
-		draw.Draw(atlas_img, image.Rect(x_offset, 0, x_offset+img.Bounds().Dx(), y_offset), img, image.ZP, draw.Src)
-		x_offset += img.Bounds().Dx() + GLYPH_ATLAS_GAP
-	}
-	if _fontSettings.Arabic {
-
-		for _, c := range ARABIC_UNICODE {
-			_, img, _, ad, ok := face.Glyph(dot, c)
-			if !ok {
-				//LogF("Failed To Draw Letter: %v", c)
-				if c == '\uFEAD' {
-					LogF("DETECTED ARR")
+
+		for {
+			packer := NewRectPacker(width, height, _padding)
+			left := remaining[:0:0]
+			for _, i := range remaining {
+				position, ok := packer.Pack(_sizes[i].X, _sizes[i].Y)
+				if !ok {
+					left = append(left, i)
+					continue
 				}
-				continue
+				pages[i], positions[i] = len(pageSizes), position
 			}
-			y_offset = img.Bounds().Dy()
-
-			bounds, _, ok := face.GlyphBounds(c)
-			if !ok {
-				//LogF("Failed to load bounds of rune: %v", c)
-				continue
+			// A full size page that can't take everything keeps what it holds, the rest spills into the next one
+			if len(left) == 0 || (width >= _maxSize && height >= _maxSize) {
+				pageSizes = append(pageSizes, NewVector2i(width, height))
+				remaining = left
+				break
 			}
-
-			tempFont.charAtlasSet[c] = CharAtlasGlyph{
-				NewVector2f(float32(x_offset)/float32(max_width), 0),
-				NewVector2f(float32(x_offset+img.Bounds().Dx())/float32(max_width), float32(y_offset)/float32(max_height)),
-				NewVector2f(float32(img.Bounds().Dx()), float32(img.Bounds().Dy())),
-				NewVector2f(float32(bounds.Max.X)/64.0-float32(bounds.Min.X)/64.0, float32(-bounds.Max.Y)/64.0),
-				float32(ad) / 64.0,
+			if width <= height {
+				width = MinInt(width*2, _maxSize)
+			} else {
+				height = MinInt(height*2, _maxSize)
 			}
-			draw.Draw(atlas_img, image.Rect(x_offset, 0, x_offset+img.Bounds().Dx(), y_offset), img, image.ZP, draw.Src)
-			x_offset += img.Bounds().Dx() + GLYPH_ATLAS_GAP
 		}
 	}
-
-	tempFont.textureAtlas = LoadTextureFromImg(atlas_img)
-	return tempFont
+	return pages, positions, pageSizes
 }
 
+// Draws _text with DrawStyle, as MeasureString measures it with the same style
 func (self *FontBatchAtlas) DrawString(_text string, _position Vector2f, _scale float32, _tint RGBA8) {
-	if self.fontSettings.Arabic {
-		self.drawStringArabic(_text, _position, _scale, _tint)
-	} else {
-		self.drawStringEnglish(_text, _position, _scale, _tint)
-	}
+	style := self.DrawStyle()
+	self.DrawStringStyled(_text, _position, _scale, &style, _tint)
 }
 
-func (self *FontBatchAtlas) drawStringEnglish(_text string, _position Vector2f, _scale float32, _tint RGBA8) {
-
-	originalPos := _position
-
-	for _, v := range _text {
-		charglyph := self.charAtlasSet[v]
-		if v == ' ' {
-			originalPos.X += charglyph.advance * _scale
-			continue
-		} else if v == '\n' {
-			originalPos.X = _position.X
-			originalPos.Y += self.fontSettings.LineHeight
-		}
-		loc_pos := originalPos
-		loc_pos.Y += (charglyph.bearing.Y) * _scale
-
-		self.sPatch.DrawSpriteBottomLeft(loc_pos, charglyph.size.Scale(_scale), charglyph.uv1, charglyph.uv2, &self.textureAtlas, _tint)
-		originalPos.X += charglyph.advance * _scale
+// The style DrawString uses: DefaultTextStyle, aligned right for Arabic fonts so the lines run leftward from
+// the draw position
+func (self *FontBatchAtlas) DrawStyle() TextStyle {
+	style := DefaultTextStyle()
+	if self.fontSettings.Arabic {
+		style.HorizontalAlign = TEXT_ALIGN_RIGHT
 	}
-
+	return style
 }
 
-func (self *FontBatchAtlas) drawStringArabic(_text string, _position Vector2f, _scale float32, _tint RGBA8) {
-	_new_text := ShapeArabic(_text)
-	_new_text = reverse(_new_text)
-	originalPos := _position
-
-	for _, v := range _new_text {
-		charglyph := self.charAtlasSet[v]
-		if v == ' ' {
-			originalPos.X -= charglyph.advance * 1.25 * _scale
-			continue
-		} else if v == '\n' {
-			originalPos.X = _position.X
-			originalPos.Y += self.fontSettings.LineHeight
-			continue
+// The baked glyph of _rune, or the one rasterized for it from the font or its fallbacks
+func (self *FontBatchAtlas) getGlyph(_rune rune) CharAtlasGlyph {
+	if glyph, ok := self.charAtlasSet[_rune]; ok {
+		if glyph.page >= 0 {
+			self.cache.pages[glyph.page].lastUsed = self.cache.frame
 		}
-		loc_pos := originalPos
-		loc_pos.X -= charglyph.bearing.X * _scale
-		loc_pos.Y += (charglyph.bearing.Y) * _scale
-		self.sPatch.DrawSpriteBottomLeft(loc_pos, charglyph.size.Scale(_scale), charglyph.uv1, charglyph.uv2, &self.textureAtlas, _tint)
-
-		originalPos.X -= charglyph.advance * _scale
+		return glyph
+	}
+	if self.cache == nil {
+		return CharAtlasGlyph{page: -1}
 	}
+	return self.cache.get(_rune)
 }
 
 func (self *FontBatchAtlas) Render() {
 	self.sPatch.Render(&Cam)
+	if self.cache != nil {
+		self.cache.frame++
+	}
 }
 
-func LoadFontTexture(_fontPath string, _fontSettings *FontBatchSettings) Texture2D {
-	var tempFont FontBatchAtlas
-	tempFont.Init()
-	tempFont.fontSettings = *_fontSettings
-
-	resp, err := http.Get(app_url + "/" + _fontPath)
-	if err != nil {
-		LogF(err.Error())
+// Adds a font that is searched, after the fonts added before it, for the runes this font doesn't have.
+// It is rasterized at the size and DPI of this font
+func (self *FontBatchAtlas) AddFallbackFont(_fontPath string) {
+	if self.cache == nil {
+		WarningF("[FONT]: AddFallbackFont called on a font that was never loaded")
+		return
 	}
-	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		LogF(err.Error())
+	face, ok := loadFontFace(_fontPath, &self.fontSettings)
+	if !ok {
+		return
 	}
-
-	f, err := opentype.Parse(data)
-
-	face, err := opentype.NewFace(f, &opentype.FaceOptions{
-		Size:    float64(_fontSettings.FontSize),
-		DPI:     float64(_fontSettings.DPI),
-		Hinting: font.HintingFull,
-	})
-	if err != nil {
-		LogF(err.Error())
+	self.cache.faces = append(self.cache.faces, face)
+	// Runes that fell back to the replacement glyph may be in the new font
+	replacement, ok := self.cache.glyphs[GLYPH_REPLACEMENT_RUNE]
+	if !ok {
+		return
 	}
-
-	max_width, max_height := int(0), int(0)
-
-	dot := fixed.Point26_6{X: fixed.Int26_6(face.Metrics().Ascent), Y: 0}
-	for i := 33; i < 127; i++ {
-		char := rune(i)
-
-		_, img, _, _, ok := face.Glyph(dot, char)
-
-		if !ok {
-			//LogF("Failed to load rune: %v", char)
-		}
-
-		max_width += img.Bounds().Dx() + GLYPH_ATLAS_GAP
-		if max_height < img.Bounds().Dy() {
-			max_height = img.Bounds().Dy()
+	for r, glyph := range self.cache.glyphs {
+		if r != GLYPH_REPLACEMENT_RUNE && glyph == replacement {
+			delete(self.cache.glyphs, r)
 		}
 	}
+}
 
-	for _, c := range ARABIC_UNICODE {
-		_, img, _, _, ok := face.Glyph(dot, c)
-
-		if !ok {
-			//LogF("Failed to load rune: %v", c)
-		}
-
-		max_width += img.Bounds().Dx() + GLYPH_ATLAS_GAP
-		if max_height < img.Bounds().Dy() {
-			max_height = img.Bounds().Dy()
-		}
+// Drops every fallback font, the glyphs already rasterized from them stay until their page is cleared
+func (self *FontBatchAtlas) ClearFallbackFonts() {
+	if self.cache == nil || len(self.cache.faces) == 0 {
+		return
 	}
+	self.cache.faces = self.cache.faces[:1]
+}
 
-	atlas_img := image.NewRGBA(image.Rect(0, 0, max_width, max_height))
-	draw.Draw(atlas_img, atlas_img.Bounds(), &image.Uniform{color.RGBA{0, 0, 0, 0}}, image.ZP, draw.Src)
-
-	x_offset := 0
-	y_offset := 0
-
-	for i := 32; i < 127; i++ {
-		char := rune(i)
-		_, img, _, ad, ok := face.Glyph(dot, char)
-		if !ok {
-			//LogF("Failed To Draw Letter: %v", char)
-		}
-		y_offset = img.Bounds().Dy()
-
-		if char == ' ' {
-			tempFont.charAtlasSet[char] = CharAtlasGlyph{uv1: Vector2fZero, uv2: Vector2fZero, size: Vector2fZero, bearing: Vector2fOne, advance: float32(ad) / float32(1<<6)}
-			continue
-		}
-
-		bounds, _, ok := face.GlyphBounds(char)
-		if !ok {
-			//LogF("Failed to load bounds of rune: %v", char)
-		}
-
-		tempFont.charAtlasSet[char] = CharAtlasGlyph{
-			NewVector2f(float32(x_offset)/float32(max_width), 0),
-			NewVector2f(float32(x_offset+img.Bounds().Dx())/float32(max_width), float32(y_offset)/float32(max_height)),
-			NewVector2f(float32(img.Bounds().Dx()), float32(img.Bounds().Dy())),
-			NewVector2f(float32(bounds.Max.X)/64.0, float32(-bounds.Max.Y)/64.0),
-			float32(ad) / 64.0,
-		}
-		draw.Draw(atlas_img, image.Rect(x_offset, 0, x_offset+img.Bounds().Dx(), y_offset), img, image.ZP, draw.Src)
-		x_offset += img.Bounds().Dx() + GLYPH_ATLAS_GAP
+// True when the font or one of its fallbacks has a glyph for _rune
+func (self *FontBatchAtlas) HasGlyph(_rune rune) bool {
+	if _, ok := self.charAtlasSet[_rune]; ok {
+		return true
 	}
-	if _fontSettings.Arabic {
-
-		for _, c := range ARABIC_UNICODE {
-			_, img, _, ad, ok := face.Glyph(dot, c)
-			if !ok {
-				//LogF("Failed To Draw Letter: %v", c)
-				continue
-			}
-			y_offset = img.Bounds().Dy()
+	return self.cache != nil && self.cache.findFace(_rune) != nil
+}
 
-			bounds, _, ok := face.GlyphBounds(c)
-			if !ok {
-				//LogF("Failed to load bounds of rune: %v", c)
-				continue
-			}
+// Number of textures the glyphs are spread over, the baked atlas included
+func (self *FontBatchAtlas) GetPageCount() int {
+	if self.cache == nil {
+		return 0
+	}
+	return len(self.cache.pages)
+}
 
-			tempFont.charAtlasSet[c] = CharAtlasGlyph{
-				NewVector2f(float32(x_offset)/float32(max_width), 0),
-				NewVector2f(float32(x_offset+img.Bounds().Dx())/float32(max_width), float32(y_offset)/float32(max_height)),
-				NewVector2f(float32(img.Bounds().Dx()), float32(img.Bounds().Dy())),
-				NewVector2f(float32(bounds.Max.X)/64.0-float32(bounds.Min.X)/64.0, float32(-bounds.Max.Y)/64.0),
-				float32(ad) / 64.0,
-			}
-			draw.Draw(atlas_img, image.Rect(x_offset, 0, x_offset+img.Bounds().Dx(), y_offset), img, image.ZP, draw.Src)
-			x_offset += img.Bounds().Dx() + GLYPH_ATLAS_GAP
-		}
+// Frees the textures of the glyph pages, shared by every copy of the font
+func (self *FontBatchAtlas) Delete() {
+	if self.cache != nil {
+		self.cache.delete()
+	}
+	for r := range self.charAtlasSet {
+		delete(self.charAtlasSet, r)
 	}
+}
 
-	tempFont.textureAtlas = LoadTextureFromImg(atlas_img)
-	return tempFont.textureAtlas
+// The baked glyphs of the font in a single texture, as large as the GPU allows. Glyphs that don't fit are
+// left out with a warning, LoadFontToAtlas spreads them over several pages instead
+func LoadFontTexture(_fontPath string, _fontSettings *FontBatchSettings) Texture2D {
+	loadedFace, ok := loadFontFace(_fontPath, _fontSettings)
+	if !ok {
+		return Texture2D{}
+	}
+	_, pageImages := bakeGlyphPages(loadedFace.face, bakedRunes(_fontSettings.Arabic), _fontSettings.glyphPadding(), GetMaxTextureSize())
+	if len(pageImages) == 0 {
+		return Texture2D{}
+	}
+	if len(pageImages) > 1 {
+		WarningF("[FONT]: the glyphs of %v need %v textures, only the first one is loaded", _fontPath, len(pageImages))
+	}
+	return LoadTextureFromImg(pageImages[0])
 }
@@ -0,0 +1,160 @@
+package chai
+
+import (
+	"image"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+func newTestFontFace(t *testing.T, _size float64) fontFace {
+	t.Helper()
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: _size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fontFace{font: f, face: face}
+}
+
+func isPowerOfTwo(_v int) bool {
+	return _v > 0 && _v&(_v-1) == 0
+}
+
+// Fails when a page is too large, not a power of two, or when two glyphs come closer than _padding
+func checkGlyphPacking(t *testing.T, _sizes []Vector2i, _maxSize, _padding int, _pages []int, _positions, _pageSizes []Vector2i) {
+	t.Helper()
+	for i, size := range _pageSizes {
+		if size.X > _maxSize || size.Y > _maxSize {
+			t.Errorf("page %v is %vx%v, larger than %v", i, size.X, size.Y, _maxSize)
+		}
+		if !isPowerOfTwo(size.X) || !isPowerOfTwo(size.Y) {
+			t.Errorf("page %v is %vx%v, not a power of two", i, size.X, size.Y)
+		}
+	}
+	rects := make([]image.Rectangle, len(_sizes))
+	for i, size := range _sizes {
+		if _pages[i] < 0 {
+			continue
+		}
+		rects[i] = image.Rect(_positions[i].X, _positions[i].Y, _positions[i].X+size.X, _positions[i].Y+size.Y)
+		page := _pageSizes[_pages[i]]
+		if !rects[i].Inset(-_padding).In(image.Rect(0, 0, page.X, page.Y)) {
+			t.Errorf("glyph %v at %v with padding %v leaves its %vx%v page", i, rects[i], _padding, page.X, page.Y)
+		}
+		for j := 0; j < i; j++ {
+			if _pages[j] == _pages[i] && rects[j].Inset(-_padding).Overlaps(rects[i]) {
+				t.Errorf("glyphs %v %v and %v %v are closer than %v pixels", j, rects[j], i, rects[i], _padding)
+			}
+		}
+	}
+}
+
+func TestPackGlyphs(t *testing.T) {
+	repeat := func(_size Vector2i, _count int) []Vector2i {
+		sizes := make([]Vector2i, _count)
+		for i := range sizes {
+			sizes[i] = _size
+		}
+		return sizes
+	}
+	tests := []struct {
+		name      string
+		sizes     []Vector2i
+		maxSize   int
+		padding   int
+		pageCount int
+		tooLarge  []int
+	}{
+		{"empty", nil, 256, 2, 0, nil},
+		{"single glyph", []Vector2i{{10, 20}}, 256, 2, 1, nil},
+		{"fits one page", repeat(NewVector2i(12, 16), 40), 256, 1, 1, nil},
+		// Two rows of two 30x30 glyphs fill a 64x64 page with 1 pixel of padding
+		{"spills into pages", repeat(NewVector2i(30, 30), 40), 64, 1, 10, nil},
+		{"mixed heights", []Vector2i{{8, 30}, {20, 5}, {15, 15}, {3, 40}, {25, 12}, {9, 9}}, 64, 3, 1, nil},
+		{"too large", []Vector2i{{10, 10}, {70, 10}, {10, 10}, {10, 62}}, 64, 2, 1, []int{1, 3}},
+		{"padding decides", []Vector2i{{60, 60}}, 64, 2, 1, nil},
+		{"padding too wide", []Vector2i{{61, 10}}, 64, 2, 0, []int{0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pages, positions, pageSizes := packGlyphs(test.sizes, test.maxSize, test.padding)
+			if len(pageSizes) != test.pageCount {
+				t.Errorf("got %v pages, want %v", len(pageSizes), test.pageCount)
+			}
+			tooLarge := map[int]bool{}
+			for _, i := range test.tooLarge {
+				tooLarge[i] = true
+			}
+			for i, page := range pages {
+				if tooLarge[i] != (page < 0) {
+					t.Errorf("glyph %v %v is on page %v", i, test.sizes[i], page)
+				}
+			}
+			checkGlyphPacking(t, test.sizes, test.maxSize, test.padding, pages, positions, pageSizes)
+		})
+	}
+}
+
+func TestPackGlyphsSmallestPage(t *testing.T) {
+	// Three 14x14 glyphs with 2 pixels of padding take 50x18, 64x32 only holds three of the four
+	_, _, pageSizes := packGlyphs([]Vector2i{{14, 14}, {14, 14}, {14, 14}, {14, 14}}, 1024, 2)
+	if len(pageSizes) != 1 || pageSizes[0] != NewVector2i(64, 64) {
+		t.Errorf("got pages %v, want a single 64x64 page", pageSizes)
+	}
+	// Three fit in the 64x32 page
+	_, _, pageSizes = packGlyphs([]Vector2i{{14, 14}, {14, 14}, {14, 14}}, 1024, 2)
+	if len(pageSizes) != 1 || pageSizes[0] != NewVector2i(64, 32) {
+		t.Errorf("got pages %v, want a single 64x32 page", pageSizes)
+	}
+}
+
+func TestBakeGlyphPages(t *testing.T) {
+	face := newTestFontFace(t, 32)
+	runes := bakedRunes(false)
+
+	glyphs, pages := bakeGlyphPages(face.face, runes, GLYPH_ATLAS_GAP, 2048)
+	if len(pages) != 1 {
+		t.Fatalf("got %v pages, want 1", len(pages))
+	}
+	if size := pages[0].Bounds().Size(); size != image.Pt(256, 256) {
+		t.Errorf("the atlas is %v, want 256x256", size)
+	}
+	if len(glyphs) != len(runes) {
+		t.Errorf("got %v glyphs, want %v", len(glyphs), len(runes))
+	}
+	if space := glyphs[' ']; space.page != -1 || space.advance <= 0.0 {
+		t.Errorf("space should be blank with an advance, got %+v", space)
+	}
+	for char, glyph := range glyphs {
+		if char == ' ' {
+			continue
+		}
+		if glyph.page != 0 || glyph.uv1.X < 0.0 || glyph.uv1.Y < 0.0 || glyph.uv2.X > 1.0 || glyph.uv2.Y > 1.0 || glyph.uv2.X <= glyph.uv1.X {
+			t.Errorf("%q has uvs %v %v on page %v", char, glyph.uv1, glyph.uv2, glyph.page)
+		}
+	}
+
+	// A large size with a small limit spills over several full pages
+	face = newTestFontFace(t, 96)
+	glyphs, pages = bakeGlyphPages(face.face, runes, GLYPH_ATLAS_GAP, 256)
+	if len(pages) != 6 {
+		t.Errorf("got %v pages, want 6", len(pages))
+	}
+	for i, page := range pages {
+		size := page.Bounds().Size()
+		if size.X > 256 || size.Y > 256 || !isPowerOfTwo(size.X) || !isPowerOfTwo(size.Y) {
+			t.Errorf("page %v is %v", i, size)
+		}
+	}
+	for char, glyph := range glyphs {
+		if char != ' ' && (glyph.page < 0 || glyph.page >= len(pages)) {
+			t.Errorf("%q is on page %v of %v", char, glyph.page, len(pages))
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package chai
+
+import (
+	"image"
+	"image/draw"
+	"io"
+	"net/http"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Width and height in pixels of the pages the glyphs missing from the baked atlas are rasterized into,
+// lowered to the MAX_TEXTURE_SIZE of the GPU when that is smaller
+const GLYPH_CACHE_PAGE_SIZE = 512
+
+// Pages a font rasterizes into before it starts clearing the least recently used one
+const GLYPH_CACHE_MAX_PAGES = 4
+
+// Drawn for runes that no font of the fallback chain has, '?' is used if the font lacks it too
+const GLYPH_REPLACEMENT_RUNE = '\uFFFD'
+
+// A parsed font at the size and DPI of the FontBatchAtlas it belongs to
+type fontFace struct {
+	font *opentype.Font
+	face font.Face
+}
+
+func loadFontFace(_fontPath string, _fontSettings *FontBatchSettings) (fontFace, bool) {
+	resp, err := http.Get(app_url + "/" + _fontPath)
+	if err != nil {
+		WarningF("[FONT]: %v", err.Error())
+		return fontFace{}, false
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		WarningF("[FONT]: %v", err.Error())
+		return fontFace{}, false
+	}
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		WarningF("[FONT]: %v: %v", _fontPath, err.Error())
+		return fontFace{}, false
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    float64(_fontSettings.FontSize),
+		DPI:     float64(_fontSettings.DPI),
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		WarningF("[FONT]: %v: %v", _fontPath, err.Error())
+		return fontFace{}, false
+	}
+	return fontFace{font: f, face: face}, true
+}
+
+// False when the font would draw its "missing glyph" box for the rune
+func (ff *fontFace) hasRune(_rune rune, _buffer *sfnt.Buffer) bool {
+	index, err := ff.font.GlyphIndex(_buffer, _rune)
+	return err == nil && index != 0
+}
+
+// A font followed by the fonts searched for the runes it doesn't have
+type fontChain struct {
+	faces  []fontFace
+	buffer sfnt.Buffer
+}
+
+// The first face of the chain that has _rune, nil when none does
+func (fc *fontChain) findFace(_rune rune) *fontFace {
+	for i := range fc.faces {
+		if fc.faces[i].hasRune(_rune, &fc.buffer) {
+			return &fc.faces[i]
+		}
+	}
+	return nil
+}
+
+// The face and rune drawn for _rune: the first face that has it, otherwise GLYPH_REPLACEMENT_RUNE or '?'.
+// Nil when the chain has none of them
+func (fc *fontChain) resolve(_rune rune) (*fontFace, rune) {
+	if face := fc.findFace(_rune); face != nil {
+		return face, _rune
+	}
+	if face := fc.findFace(GLYPH_REPLACEMENT_RUNE); face != nil {
+		return face, GLYPH_REPLACEMENT_RUNE
+	}
+	if len(fc.faces) > 0 && fc.faces[0].hasRune('?', &fc.buffer) {
+		return &fc.faces[0], '?'
+	}
+	return nil, _rune
+}
+
+type glyphPage struct {
+	texture Texture2D
+	// Nil for the atlas baked at load time, which is full and never cleared
+	packer *RectPacker
+	// glyphCache.frame when a glyph of the page was last drawn
+	lastUsed uint64
+}
+
+// The glyphs of a font and the faces they are rasterized from. Shared by every copy of the FontBatchAtlas
+type glyphCache struct {
+	// The font first, then its fallbacks in the order they were added
+	fontChain
+	pages  []*glyphPage
+	glyphs map[rune]CharAtlasGlyph
+	// Incremented by every Render, the pages drawn from since the last one hold glyphs that are still
+	// waiting in the batch and can't be cleared
+	frame uint64
+	// Empty pixels around the glyphs of the rasterized pages
+	padding int
+}
+
+func newGlyphCache() *glyphCache {
+	return &glyphCache{glyphs: make(map[rune]CharAtlasGlyph), frame: 1, padding: GLYPH_ATLAS_GAP}
+}
+
+// The glyph of _rune, rasterized on first use. Control characters have an empty glyph
+func (gc *glyphCache) get(_rune rune) CharAtlasGlyph {
+	if glyph, ok := gc.glyphs[_rune]; ok {
+		if glyph.page >= 0 {
+			gc.pages[glyph.page].lastUsed = gc.frame
+		}
+		return glyph
+	}
+	if unicode.IsControl(_rune) {
+		return CharAtlasGlyph{page: -1}
+	}
+
+	face, drawn := gc.resolve(_rune)
+	if face == nil {
+		return CharAtlasGlyph{page: -1}
+	}
+	if drawn != _rune {
+		glyph := gc.get(drawn)
+		// Remembered under the missing rune too so the chain is only searched once
+		if glyph.size != Vector2fZero {
+			gc.glyphs[_rune] = glyph
+		}
+		return glyph
+	}
+
+	glyph, ok := gc.rasterize(face, _rune)
+	if !ok {
+		return CharAtlasGlyph{page: -1}
+	}
+	gc.glyphs[_rune] = glyph
+	return glyph
+}
+
+func (gc *glyphCache) rasterize(_face *fontFace, _rune rune) (CharAtlasGlyph, bool) {
+	dr, mask, maskp, advance, ok := _face.face.Glyph(fixed.Point26_6{}, _rune)
+	if !ok {
+		return CharAtlasGlyph{}, false
+	}
+	bounds, _, _ := _face.face.GlyphBounds(_rune)
+	width, height := mask.Bounds().Dx(), mask.Bounds().Dy()
+	glyph := CharAtlasGlyph{
+		size:    NewVector2f(float32(width), float32(height)),
+		bearing: NewVector2f(float32(bounds.Max.X)/64.0-float32(bounds.Min.X)/64.0, float32(-bounds.Max.Y)/64.0),
+		offset:  NewVector2f(float32(dr.Min.X), float32(-dr.Max.Y)),
+		advance: float32(advance) / 64.0,
+		page:    -1,
+	}
+	if width == 0 || height == 0 {
+		// Blank glyphs such as spaces only move the pen, they take no room in a page
+		return glyph, true
+	}
+
+	pageIndex, position, ok := gc.allocate(width, height)
+	if !ok {
+		WarningF("[FONT]: no room for the glyph of %q (%vx%v) in the glyph pages", _rune, width, height)
+		return CharAtlasGlyph{}, false
+	}
+	page := gc.pages[pageIndex]
+	page.lastUsed = gc.frame
+
+	// The mask is reused by the next call to Glyph, it is copied out right away
+	pixels := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(pixels, pixels.Bounds(), mask, maskp, draw.Src)
+	page.texture.updateRegion(position.X, position.Y, pixels)
+
+	pageSize := NewVector2f(float32(page.texture.Width), float32(page.texture.Height))
+	glyph.page = pageIndex
+	glyph.uv1 = NewVector2f(float32(position.X)/pageSize.X, float32(position.Y)/pageSize.Y)
+	glyph.uv2 = NewVector2f(float32(position.X+width)/pageSize.X, float32(position.Y+height)/pageSize.Y)
+	return glyph, true
+}
+
+// Finds room for a _width x _height glyph, in a new page when the others are full and in the least
+// recently used one, cleared first, once there are GLYPH_CACHE_MAX_PAGES of them
+func (gc *glyphCache) allocate(_width, _height int) (int, Vector2i, bool) {
+	dynamicPages := 0
+	for i := len(gc.pages) - 1; i >= 0; i-- {
+		if gc.pages[i].packer == nil {
+			continue
+		}
+		dynamicPages++
+		if position, ok := gc.pages[i].packer.Pack(_width, _height); ok {
+			return i, position, true
+		}
+	}
+
+	var pageIndex int
+	if dynamicPages < GLYPH_CACHE_MAX_PAGES {
+		gc.pages = append(gc.pages, newGlyphPage(MinInt(GLYPH_CACHE_PAGE_SIZE, GetMaxTextureSize()), gc.padding))
+		pageIndex = len(gc.pages) - 1
+	} else {
+		pageIndex = gc.leastRecentlyUsedPage()
+		if pageIndex < 0 {
+			return 0, Vector2i{}, false
+		}
+		gc.evict(pageIndex)
+	}
+	position, ok := gc.pages[pageIndex].packer.Pack(_width, _height)
+	return pageIndex, position, ok
+}
+
+func newGlyphPage(_size, _padding int) *glyphPage {
+	return &glyphPage{
+		texture: newBlankTexture(_size, _size, PixelArtTextureOptions()),
+		packer:  NewRectPacker(_size, _size, _padding),
+	}
+}
+
+// The rasterized page drawn from the longest ago, -1 when they were all drawn from this frame
+func (gc *glyphCache) leastRecentlyUsedPage() int {
+	oldest := -1
+	for i, page := range gc.pages {
+		if page.packer == nil || page.lastUsed == gc.frame {
+			continue
+		}
+		if oldest < 0 || page.lastUsed < gc.pages[oldest].lastUsed {
+			oldest = i
+		}
+	}
+	return oldest
+}
+
+// Forgets the glyphs of the page and clears it for new ones
+func (gc *glyphCache) evict(_pageIndex int) {
+	for r, glyph := range gc.glyphs {
+		if glyph.page == _pageIndex {
+			delete(gc.glyphs, r)
+		}
+	}
+	page := gc.pages[_pageIndex]
+	page.packer.Reset()
+	page.texture.clear()
+}
+
+func (gc *glyphCache) delete() {
+	for _, page := range gc.pages {
+		page.texture.Delete()
+	}
+	gc.pages = gc.pages[:0]
+	for r := range gc.glyphs {
+		delete(gc.glyphs, r)
+	}
+}
@@ -1,10 +1,10 @@
 package chai
 
 import (
-	"bytes"
-	"encoding/binary"
 	"math"
 	"math/rand"
+	"sort"
+	"strconv"
 	"syscall/js"
 	"unsafe"
 )
@@ -128,9 +128,33 @@ type ShapeBatch struct {
 	Vertices         []Vertex
 	Indices          []int32
 	NumberOfElements int
+	numberOfVertices int
 	Shader           ShaderProgram
 	LineWidth        float32
+	LineJoin         LineJoin
+	LineCap          LineCap
 	Initialized      bool
+	// Renders the batch through a multisampled framebuffer, smoothing the edges of every primitive
+	AntiAliased bool
+	// Samples per pixel used when AntiAliased, zero means SHAPE_DEFAULT_SAMPLES
+	Samples int
+	msaa    multisampleTarget
+	// Used by the shapes that neither SetBlendMode nor their material set a mode for
+	BlendMode BlendMode
+
+	currentMaterial    *Material
+	currentBlendMode   BlendMode
+	segments           []shapeSegment
+	segmentsBackBuffer []shapeSegment
+	// Context generation the buffers were created in, see IsContextLost
+	generation uint32
+}
+
+// A range of indices that is drawn with the same material and blend mode
+type shapeSegment struct {
+	offset, numberOfElements int
+	material                 *Material
+	blendMode                BlendMode
 }
 
 func (_shapesB *ShapeBatch) Init() {
@@ -139,6 +163,18 @@ func (_shapesB *ShapeBatch) Init() {
 	_shapesB.Vertices = make([]Vertex, 0)
 	_shapesB.Indices = make([]int32, 0)
 
+	_shapesB.createBuffers()
+
+	_shapesB.Shader.ParseShader(SHAPES_SHADER_VERTEX, SHAPES_SHADER_FRAGMENT)
+	//_shapesB.Shader.ParseShaderFromFile("shapes.shader")
+	_shapesB.Shader.CreateShaderProgram()
+	_shapesB.Shader.AddAttribute("coordinates")
+	_shapesB.Shader.AddAttribute("colors")
+	_shapesB.Initialized = true
+}
+
+func (_shapesB *ShapeBatch) createBuffers() {
+	_shapesB.generation = contextGeneration
 	_shapesB.vao = canvasContext.Call("createVertexArray")
 	canvasContext.Call("bindVertexArray", _shapesB.vao)
 
@@ -162,13 +198,6 @@ func (_shapesB *ShapeBatch) Init() {
 	canvasContext.Call("disableVertexAttribArray", 0)
 	canvasContext.Call("disableVertexAttribArray", 1)
 	canvasContext.Call("disableVertexAttribArray", 2)
-
-	_shapesB.Shader.ParseShader(SHAPES_SHADER_VERTEX, SHAPES_SHADER_FRAGMENT)
-	//_shapesB.Shader.ParseShaderFromFile("shapes.shader")
-	_shapesB.Shader.CreateShaderProgram()
-	_shapesB.Shader.AddAttribute("coordinates")
-	_shapesB.Shader.AddAttribute("colors")
-	_shapesB.Initialized = true
 }
 
 func (_sp *ShapeBatch) DrawLine(_from, _to Vector2f, _color RGBA8) {
@@ -250,18 +279,7 @@ func (_sp *ShapeBatch) DrawTriangleRotated(_center, _dimensions Vector2f, _color
 }
 
 func (_sp *ShapeBatch) DrawCircle(_center Vector2f, _radius float32, _color RGBA8) {
-	numOfVertices := 16
-
-	pos := [16]Vector2f{}
-	for i := 0; i < numOfVertices; i++ {
-		angle := (float32(i) / float32(numOfVertices)) * 2.0 * PI
-		pos[i] = NewVector2f(_center.X+float32(math.Cos(float64(angle)))*_radius, _center.Y+float32(math.Sin(float64(angle)))*_radius)
-	}
-
-	for i := 0; i < numOfVertices-1; i++ {
-		_sp.DrawLine(pos[i], pos[i+1], _color)
-	}
-	_sp.DrawLine(pos[numOfVertices-1], pos[0], _color)
+	_sp.DrawEllipse(_center, NewVector2f(_radius, _radius), _color, 0.0)
 }
 
 func (_sp *ShapeBatch) DrawFillRect(_center, _dimensions Vector2f, _color RGBA8) {
@@ -303,6 +321,9 @@ func (_sp *ShapeBatch) finalize() {
 		_sp.NumberOfElements = 0
 		return
 	}
+	if _sp.generation != contextGeneration {
+		_sp.createBuffers()
+	}
 	canvasContext.Call("bindVertexArray", _sp.vao)
 
 	jsVerts := vertexBufferToJsVertexBuffer(_sp.Vertices)
@@ -327,40 +348,137 @@ func (_sp *ShapeBatch) finalize() {
 	canvasContext.Call("bindBuffer", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), js.Null())
 
 	_sp.NumberOfElements = len(_sp.Indices)
+	_sp.numberOfVertices = len(_sp.Vertices)
 
 	_sp.Vertices = _sp.Vertices[:0]
 	_sp.Indices = _sp.Indices[:0]
 }
 
 func (_sp *ShapeBatch) Render(cam *Camera2D) {
+	segments := _sp.closeSegments()
 	_sp.finalize()
 
-	UseShader(&_sp.Shader)
+	if _sp.AntiAliased && len(segments) > 0 {
+		_sp.renderMultisampled(segments, cam)
+	} else {
+		_sp.renderSegments(segments, cam, true)
+	}
+	renderStats.Vertices += _sp.numberOfVertices
+}
 
-	viewMatrix := cam.viewMatrix.Data()
+// _blend applies the blend mode of every segment, the multisampled path blends on its own
+func (_sp *ShapeBatch) renderSegments(segments []shapeSegment, cam *Camera2D, _blend bool) {
+	canvasContext.Call("bindVertexArray", _sp.vao)
+	var currentShader *ShaderProgram
+	currentBlendMode := BLEND_DEFAULT
+	for _, segment := range segments {
+		if segment.numberOfElements == 0 {
+			continue
+		}
+		shader := &_sp.Shader
+		if segment.material != nil {
+			shader = segment.material.Shader
+		}
+		if blendMode := _sp.segmentBlendMode(&segment); _blend && blendMode != currentBlendMode {
+			applyBlendMode(blendMode)
+			currentBlendMode = blendMode
+		}
+		if shader != currentShader {
+			UseShader(shader)
+			shader.SetUniformMat4("view_matrix", cam.viewMatrix.Data())
+			currentShader = shader
+		}
+		if segment.material != nil {
+			segment.material.apply(0)
+		}
 
-	viewBuffer := new(bytes.Buffer)
-	{
-		err := binary.Write(viewBuffer, binary.LittleEndian, viewMatrix[:])
-		Assert(err == nil, "Error writing viewBuffer")
+		canvasContext.Call("drawElements", canvasContext.Get("TRIANGLES"), segment.numberOfElements, canvasContext.Get("UNSIGNED_INT"), segment.offset*4)
+		renderStats.DrawCalls++
 	}
+	canvasContext.Call("bindVertexArray", js.Null())
+	UnuseShader()
+	if currentBlendMode != BLEND_DEFAULT && currentBlendMode != BLEND_ALPHA {
+		applyBlendMode(BLEND_ALPHA)
+	}
+}
 
-	byte_slice2 := viewBuffer.Bytes()
+// The mode of the segment, then of its material, then of the batch
+func (_sp *ShapeBatch) segmentBlendMode(_segment *shapeSegment) BlendMode {
+	blendMode := _segment.blendMode
+	if _segment.material != nil {
+		blendMode = blendMode.or(_segment.material.BlendMode)
+	}
+	return blendMode.or(_sp.BlendMode).or(BLEND_ALPHA)
+}
 
-	b2 := js.Global().Get("Uint8Array").New(len(viewMatrix) * 4)
-	js.CopyBytesToJS(b2, byte_slice2)
+// Shapes drawn after this call use _material, nil goes back to the batch's shader
+func (_sp *ShapeBatch) SetMaterial(_material *Material) {
+	if _sp.currentMaterial == _material {
+		return
+	}
+	_sp.closeCurrentSegment()
+	_sp.currentMaterial = _material
+}
 
-	viewMatrixJS := js.Global().Get("Float32Array").New(b2.Get("buffer"), b2.Get("byteOffset"), b2.Get("byteLength").Int()/4)
-	for i := 0; i < len(viewMatrix); i++ {
-		viewMatrixJS.SetIndex(i, js.ValueOf(viewMatrix[i]))
+// Shapes drawn after this call use _mode, BLEND_DEFAULT goes back to the material or batch mode
+func (_sp *ShapeBatch) SetBlendMode(_mode BlendMode) {
+	if _sp.currentBlendMode == _mode {
+		return
 	}
+	_sp.closeCurrentSegment()
+	_sp.currentBlendMode = _mode
+}
 
-	viewmatrix_loc := canvasContext.Call("getUniformLocation", _sp.Shader.ShaderProgramID, "view_matrix")
-	canvasContext.Call("uniformMatrix4fv", viewmatrix_loc, false, viewMatrixJS)
-	canvasContext.Call("bindVertexArray", _sp.vao)
+func (_sp *ShapeBatch) GetBlendMode() BlendMode {
+	return _sp.currentBlendMode
+}
 
-	canvasContext.Call("drawElements", canvasContext.Get("TRIANGLES"), _sp.NumberOfElements, canvasContext.Get("UNSIGNED_INT"), 0)
-	UnuseShader()
+func (_sp *ShapeBatch) closeCurrentSegment() {
+	start := 0
+	if len(_sp.segments) > 0 {
+		last := _sp.segments[len(_sp.segments)-1]
+		start = last.offset + last.numberOfElements
+	}
+	if len(_sp.Indices) > start {
+		_sp.segments = append(_sp.segments, shapeSegment{start, len(_sp.Indices) - start, _sp.currentMaterial, _sp.currentBlendMode})
+	}
+}
+
+// Returns the segments of this frame and starts a new list, the current material stays active
+func (_sp *ShapeBatch) closeSegments() []shapeSegment {
+	_sp.closeCurrentSegment()
+	segments := _sp.segments
+	_sp.segments = _sp.segmentsBackBuffer[:0]
+	_sp.segmentsBackBuffer = segments
+	return segments
+}
+
+// The shapes submitted to a ShapeBatch since its last Render, see saveShapes
+type shapeSnapshot struct {
+	vertices []Vertex
+	indices  []int32
+	segments []shapeSegment
+}
+
+func (_sp *ShapeBatch) saveShapes(_snapshot *shapeSnapshot) {
+	_sp.closeCurrentSegment()
+	_snapshot.vertices = append(_snapshot.vertices[:0], _sp.Vertices...)
+	_snapshot.indices = append(_snapshot.indices[:0], _sp.Indices...)
+	_snapshot.segments = append(_snapshot.segments[:0], _sp.segments...)
+}
+
+// Submits the shapes of _snapshot again, after the ones already in the batch
+func (_sp *ShapeBatch) restoreShapes(_snapshot *shapeSnapshot) {
+	_sp.closeCurrentSegment()
+	vertexOffset, indexOffset := int32(len(_sp.Vertices)), len(_sp.Indices)
+	_sp.Vertices = append(_sp.Vertices, _snapshot.vertices...)
+	for _, index := range _snapshot.indices {
+		_sp.Indices = append(_sp.Indices, index+vertexOffset)
+	}
+	for _, segment := range _snapshot.segments {
+		segment.offset += indexOffset
+		_sp.segments = append(_sp.segments, segment)
+	}
 }
 
 /*
@@ -373,12 +491,14 @@ const SPRITES_SHADER_VERTEX = `#version 300 es
 
 precision mediump float;
 
-in vec2 coordinates;
-in vec4 colors;
-in vec2 uv;
+layout(location = 0) in vec2 coordinates;
+layout(location = 1) in vec4 colors;
+layout(location = 2) in vec2 uv;
+layout(location = 3) in float texture_slot;
 
 out vec4 vertex_FragColor;
 out vec2 vertex_UV;
+out float vertex_TextureSlot;
 
 uniform mat4 projection_matrix;
 uniform mat4 view_matrix;
@@ -393,6 +513,7 @@ void main(void) {
 	
 	vertex_FragColor = colors;
 	vertex_UV = uv;
+	vertex_TextureSlot = texture_slot;
 }`
 const SPRITES_SHADER_FRAGMENT = `#version 300 es
 
@@ -400,19 +521,108 @@ precision mediump float;
 
 in vec4 vertex_FragColor;
 in vec2 vertex_UV;
+in float vertex_TextureSlot;
 
-uniform sampler2D genericSampler;
+uniform sampler2D samplers[8];
 
 out vec4 fragColor;
 
+vec4 sampleSlot(int _slot, vec2 _uv) {
+	if (_slot == 0) return texture(samplers[0], _uv);
+	if (_slot == 1) return texture(samplers[1], _uv);
+	if (_slot == 2) return texture(samplers[2], _uv);
+	if (_slot == 3) return texture(samplers[3], _uv);
+	if (_slot == 4) return texture(samplers[4], _uv);
+	if (_slot == 5) return texture(samplers[5], _uv);
+	if (_slot == 6) return texture(samplers[6], _uv);
+	return texture(samplers[7], _uv);
+}
+
 void main(void) {
-	vec4 thisColor = vertex_FragColor * texture(genericSampler, vertex_UV);
+	vec4 thisColor = vertex_FragColor * sampleSlot(int(vertex_TextureSlot + 0.5), vertex_UV);
 	fragColor = thisColor;
 }`
 
+// Number of texture units a single sprite draw call can sample from,
+// it has to match the size of the samplers array in the sprite shaders
+const SPRITE_BATCH_MAX_TEXTURES = 8
+
+const spriteVertexSize = 24
+
+// The vertex layout of the sprite batch, a regular Vertex followed by the texture unit it samples from
+type spriteVertex struct {
+	Vertex
+	TextureSlot float32
+}
+
+// How the colors of a batch are combined with what is already drawn
+type BlendMode uint8
+
+const (
+	// Takes the mode of the level above: draw call, then material, then render layer, then batch.
+	// Alpha blending when nothing sets one
+	BLEND_DEFAULT BlendMode = iota
+	// Regular transparency
+	BLEND_ALPHA
+	// Adds the colors, used for glows, sparks and fire
+	BLEND_ADDITIVE
+	// Regular transparency for colors already multiplied by their alpha
+	BLEND_PREMULTIPLIED
+	// Darkens what is below, used for shadows and tinting
+	BLEND_MULTIPLY
+	// Brightens what is below without saturating as fast as additive
+	BLEND_SCREEN
+	// Removes the colors from what is below
+	BLEND_SUBTRACT
+	// Replaces what is below, alpha included
+	BLEND_OPAQUE
+)
+
+// Returns _mode, or _fallback when _mode is BLEND_DEFAULT
+func (_mode BlendMode) or(_fallback BlendMode) BlendMode {
+	if _mode == BLEND_DEFAULT {
+		return _fallback
+	}
+	return _mode
+}
+
+func applyBlendMode(_mode BlendMode) {
+	equation := canvasContext.Get("FUNC_ADD")
+	switch _mode {
+	case BLEND_ADDITIVE:
+		canvasContext.Call("blendFunc", canvasContext.Get("SRC_ALPHA"), canvasContext.Get("ONE"))
+	case BLEND_PREMULTIPLIED:
+		canvasContext.Call("blendFunc", canvasContext.Get("ONE"), canvasContext.Get("ONE_MINUS_SRC_ALPHA"))
+	case BLEND_MULTIPLY:
+		canvasContext.Call("blendFunc", canvasContext.Get("DST_COLOR"), canvasContext.Get("ONE_MINUS_SRC_ALPHA"))
+	case BLEND_SCREEN:
+		canvasContext.Call("blendFunc", canvasContext.Get("ONE"), canvasContext.Get("ONE_MINUS_SRC_COLOR"))
+	case BLEND_SUBTRACT:
+		canvasContext.Call("blendFunc", canvasContext.Get("SRC_ALPHA"), canvasContext.Get("ONE"))
+		equation = canvasContext.Get("FUNC_REVERSE_SUBTRACT")
+	case BLEND_OPAQUE:
+		canvasContext.Call("blendFunc", canvasContext.Get("ONE"), canvasContext.Get("ZERO"))
+	default:
+		canvasContext.Call("blendFunc", canvasContext.Get("SRC_ALPHA"), canvasContext.Get("ONE_MINUS_SRC_ALPHA"))
+	}
+	canvasContext.Call("blendEquation", equation)
+}
+
+type SpriteSortMode uint8
+
+const (
+	// Glyphs keep the order they were drawn in (inside their layer)
+	SPRITE_SORT_NONE SpriteSortMode = iota
+	// Glyphs of the same layer are grouped by texture to reduce batch breaks
+	SPRITE_SORT_TEXTURE
+)
+
 type SpriteGlyph struct {
 	bottomleft, topleft, topright, bottomright Vertex
 	texture                                    *Texture2D
+	layer                                      int
+	material                                   *Material
+	blendMode                                  BlendMode
 }
 
 func NewSpriteGlyph(_pos, _dimensions, _uv1 Vector2f, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8) SpriteGlyph {
@@ -445,23 +655,103 @@ func NewSpriteGlyphRotated(_pos, _dimensions, _uv1, _uv2 Vector2f, _texture *Tex
 	return tempGlyph
 }
 
+// A quad showing _region, _dimensions is the size of the untrimmed image
+func NewSpriteGlyphRegion(_pos, _dimensions Vector2f, _region *TextureRegion, _tint RGBA8, _rotation float32) SpriteGlyph {
+	corners := _region.quadCorners(_dimensions)
+	for i := range corners {
+		corners[i] = _pos.Add(corners[i]).Rotate(_rotation, _pos)
+	}
+	return newSpriteGlyphQuad(corners, _region.cornerUVs(), _region.Texture, _tint)
+}
+
+// Corners and uvs are ordered bottom-left, top-left, top-right, bottom-right
+func newSpriteGlyphQuad(_corners, _uvs [4]Vector2f, _texture *Texture2D, _tint RGBA8) SpriteGlyph {
+	var tempGlyph SpriteGlyph
+
+	tempGlyph.bottomleft = NewVertex(_corners[0], _uvs[0], _tint)
+	tempGlyph.topleft = NewVertex(_corners[1], _uvs[1], _tint)
+	tempGlyph.topright = NewVertex(_corners[2], _uvs[2], _tint)
+	tempGlyph.bottomright = NewVertex(_corners[3], _uvs[3], _tint)
+
+	tempGlyph.texture = _texture
+
+	return tempGlyph
+}
+
+// A range of indices drawn with one draw call, every glyph inside it samples from one of its textures
 type RenderBatch struct {
-	offset, numberOfVertices int
-	texture                  *Texture2D
+	offset, numberOfElements int
+	textures                 [SPRITE_BATCH_MAX_TEXTURES]*Texture2D
+	numberOfTextures         int
+	material                 *Material
+	blendMode                BlendMode
+}
+
+func NewRenderBatch(_offset, _numberOfElements int, _texture *Texture2D, _material *Material) RenderBatch {
+	batch := RenderBatch{offset: _offset, numberOfElements: _numberOfElements, material: _material}
+	batch.textures[0] = _texture
+	batch.numberOfTextures = 1
+	return batch
+}
+
+// Returns the texture unit the texture is bound to in this batch, -1 if it is not
+func (rb *RenderBatch) textureSlot(_texture *Texture2D) int {
+	for i := 0; i < rb.numberOfTextures; i++ {
+		if rb.textures[i].handle == _texture.handle {
+			return i
+		}
+	}
+	return -1
+}
+
+type RenderStats struct {
+	DrawCalls int
+	Vertices  int
+	Sprites   int
+	// Entities the culled render systems submitted and skipped, summed over every camera
+	EntitiesDrawn  int
+	EntitiesCulled int
+}
+
+var renderStats RenderStats
+var lastFrameRenderStats RenderStats
+
+// Returns the counters of the last rendered frame
+func GetRenderStats() RenderStats {
+	return lastFrameRenderStats
 }
 
-func NewRenderBatch(_offset, _numberOfVertices int, _texture *Texture2D) RenderBatch {
-	return RenderBatch{_offset, _numberOfVertices, _texture}
+func resetRenderStats() {
+	lastFrameRenderStats = renderStats
+	renderStats = RenderStats{}
 }
 
 type SpriteBatch struct {
 	vbo js.Value
 	vao js.Value
+	ibo js.Value
 
-	shader ShaderProgram
+	shader       ShaderProgram
+	textureSlots int
+
+	SortMode SpriteSortMode
+	// Used by the glyphs that neither their draw call, material nor layer set a mode for
+	BlendMode        BlendMode
+	layer            int
+	layerBlendModes  map[int]BlendMode
+	currentMaterial  *Material
+	currentBlendMode BlendMode
 
 	renderBatches []RenderBatch
 	spriteGlyphs  []SpriteGlyph
+	sortedGlyphs  []*SpriteGlyph
+
+	vertices       []spriteVertex
+	jsVertices     js.Value
+	quadsCapacity  int
+	lastFrameStats RenderStats
+	// Context generation the buffers were created in, see IsContextLost
+	generation uint32
 }
 
 func (self *SpriteBatch) Reset() {
@@ -470,10 +760,45 @@ func (self *SpriteBatch) Reset() {
 	//`self.Init("")
 }
 
+// Appends the glyphs submitted since the last Render to _glyphs, restoreGlyphs submits them again
+func (self *SpriteBatch) saveGlyphs(_glyphs []SpriteGlyph) []SpriteGlyph {
+	return append(_glyphs, self.spriteGlyphs...)
+}
+
+func (self *SpriteBatch) restoreGlyphs(_glyphs []SpriteGlyph) {
+	self.spriteGlyphs = append(self.spriteGlyphs, _glyphs...)
+}
+
 func (self *SpriteBatch) Init(_shader_path string) {
 
 	self.renderBatches = make([]RenderBatch, 0)
 	self.spriteGlyphs = make([]SpriteGlyph, 0)
+	self.sortedGlyphs = make([]*SpriteGlyph, 0)
+	self.vertices = make([]spriteVertex, 0)
+	self.createBuffers()
+
+	if _shader_path == "" {
+		self.shader.ParseShader(SPRITES_SHADER_VERTEX, SPRITES_SHADER_FRAGMENT)
+	} else {
+		self.shader.ParseShaderFromFile(_shader_path)
+	}
+	self.shader.CreateShaderProgram()
+	self.shader.AddAttribute("coordinates")
+	self.shader.AddAttribute("colors")
+	self.shader.AddAttribute("uv")
+	self.shader.AddAttribute("texture_slot")
+
+	// Shaders that only declare a single "genericSampler" get one texture per draw call
+	self.textureSlots = SPRITE_BATCH_MAX_TEXTURES
+	if self.shader.GetUniformLocation("samplers[0]").IsNull() {
+		self.textureSlots = 1
+	}
+}
+
+// The buffers start empty, uploadVertices allocates them
+func (self *SpriteBatch) createBuffers() {
+	self.generation = contextGeneration
+	self.quadsCapacity = 0
 
 	self.vao = canvasContext.Call("createVertexArray")
 	canvasContext.Call("bindVertexArray", self.vao)
@@ -484,59 +809,251 @@ func (self *SpriteBatch) Init(_shader_path string) {
 	canvasContext.Call("enableVertexAttribArray", 0)
 	canvasContext.Call("enableVertexAttribArray", 1)
 	canvasContext.Call("enableVertexAttribArray", 2)
+	canvasContext.Call("enableVertexAttribArray", 3)
 
-	canvasContext.Call("vertexAttribPointer", 0, 2, canvasContext.Get("FLOAT"), false, VertexSize, 0)
-	canvasContext.Call("vertexAttribPointer", 1, 4, canvasContext.Get("UNSIGNED_BYTE"), true, VertexSize, 8)
-	canvasContext.Call("vertexAttribPointer", 2, 2, canvasContext.Get("FLOAT"), false, VertexSize, 12)
+	canvasContext.Call("vertexAttribPointer", 0, 2, canvasContext.Get("FLOAT"), false, spriteVertexSize, 0)
+	canvasContext.Call("vertexAttribPointer", 1, 4, canvasContext.Get("UNSIGNED_BYTE"), true, spriteVertexSize, 8)
+	canvasContext.Call("vertexAttribPointer", 2, 2, canvasContext.Get("FLOAT"), false, spriteVertexSize, 12)
+	canvasContext.Call("vertexAttribPointer", 3, 1, canvasContext.Get("FLOAT"), false, spriteVertexSize, 20)
+
+	self.ibo = canvasContext.Call("createBuffer")
+	canvasContext.Call("bindBuffer", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), self.ibo)
 
 	canvasContext.Call("bindVertexArray", js.Null())
 	canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), js.Null())
+	canvasContext.Call("bindBuffer", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), js.Null())
+}
 
-	canvasContext.Call("disableVertexAttribArray", 0)
-	canvasContext.Call("disableVertexAttribArray", 1)
-	canvasContext.Call("disableVertexAttribArray", 2)
+// Sets the layer of the glyphs drawn after this call, lower layers are rendered first
+func (self *SpriteBatch) SetLayer(_layer int) {
+	self.layer = _layer
+}
 
-	if _shader_path == "" {
-		self.shader.ParseShader(SPRITES_SHADER_VERTEX, SPRITES_SHADER_FRAGMENT)
-	} else {
-		self.shader.ParseShaderFromFile(_shader_path)
+func (self *SpriteBatch) GetLayer() int {
+	return self.layer
+}
+
+// Sprites drawn after this call use _material, nil goes back to the batch's shader
+func (self *SpriteBatch) SetMaterial(_material *Material) {
+	self.currentMaterial = _material
+}
+
+func (self *SpriteBatch) GetMaterial() *Material {
+	return self.currentMaterial
+}
+
+// Sprites drawn after this call use _mode, BLEND_DEFAULT goes back to the material, layer or batch mode
+func (self *SpriteBatch) SetBlendMode(_mode BlendMode) {
+	self.currentBlendMode = _mode
+}
+
+func (self *SpriteBatch) GetBlendMode() BlendMode {
+	return self.currentBlendMode
+}
+
+// Blend mode of every glyph of _layer whose draw call and material don't set one
+func (self *SpriteBatch) SetLayerBlendMode(_layer int, _mode BlendMode) {
+	if self.layerBlendModes == nil {
+		self.layerBlendModes = make(map[int]BlendMode)
 	}
-	self.shader.CreateShaderProgram()
-	self.shader.AddAttribute("coordinates")
-	self.shader.AddAttribute("colors")
-	self.shader.AddAttribute("uv")
+	self.layerBlendModes[_layer] = _mode
+}
+
+func (self *SpriteBatch) GetLayerBlendMode(_layer int) BlendMode {
+	return self.layerBlendModes[_layer]
+}
+
+// Returns the draw calls and vertices of the last call to Render
+func (self *SpriteBatch) GetStats() RenderStats {
+	return self.lastFrameStats
+}
+
+func (self *SpriteBatch) addGlyph(_glyph SpriteGlyph) {
+	_glyph.layer = self.layer
+	_glyph.material = self.currentMaterial
+	_glyph.blendMode = self.currentBlendMode
+	if _glyph.material != nil {
+		_glyph.blendMode = _glyph.blendMode.or(_glyph.material.BlendMode)
+	}
+	self.spriteGlyphs = append(self.spriteGlyphs, _glyph)
+}
+
+// Everything one sprite can be drawn with, see NewSpriteDrawParams for the usual defaults
+type SpriteDrawParams struct {
+	Texture *Texture2D
+	// Used instead of Texture and SourceRect when set
+	Region *TextureRegion
+	// Pixels of Texture to draw, from its top-left. The whole texture when its size is zero
+	SourceRect Rect
+	// World position of the pivot
+	Position Vector2f
+	// Point the sprite is placed and rotated around, relative to its centre and normalized by its size:
+	// (0, 0) is the centre, (-0.5, -0.5) the bottom-left corner and (0.5, 0.5) the top-right one
+	Pivot Vector2f
+	// World size before Scale, the source size in pixels when zero
+	Size Vector2f
+	// One when zero
+	Scale Vector2f
+	// Counter-clockwise, in degrees
+	Rotation     float32
+	FlipX, FlipY bool
+	Tint         RGBA8
+	// Bottom-left, top-left, top-right and bottom-right colours multiplied with Tint, for gradients.
+	// Ignored when all of them are zero
+	CornerColors [4]RGBA8
+	// Overrides the batch material for this sprite when set
+	Material *Material
+	// Overrides the material, layer and batch blend modes when set
+	BlendMode BlendMode
+}
+
+func NewSpriteDrawParams(_texture *Texture2D, _position Vector2f) SpriteDrawParams {
+	return SpriteDrawParams{Texture: _texture, Position: _position, Tint: WHITE}
+}
+
+func NewSpriteDrawParamsRegion(_region *TextureRegion, _position Vector2f) SpriteDrawParams {
+	return SpriteDrawParams{Region: _region, Position: _position, Tint: WHITE}
+}
+
+// The region drawn and the world corners and uvs of its quad, false without a texture or a region
+func (_params *SpriteDrawParams) quad() (TextureRegion, [4]Vector2f, [4]Vector2f, bool) {
+	var region TextureRegion
+	switch {
+	case _params.Region != nil:
+		region = *_params.Region
+	case _params.Texture != nil:
+		region = NewTextureRegionFromTexture(_params.Texture)
+		if _params.SourceRect.Size.X > 0.0 && _params.SourceRect.Size.Y > 0.0 {
+			rect := _params.SourceRect
+			region = NewTextureRegion(_params.Texture, int(rect.Position.X), int(rect.Position.Y), int(rect.Size.X), int(rect.Size.Y))
+		}
+	default:
+		return region, [4]Vector2f{}, [4]Vector2f{}, false
+	}
+
+	size := _params.Size
+	if size.X == 0.0 && size.Y == 0.0 {
+		pixels := region.GetSize()
+		size = NewVector2f(float32(pixels.X), float32(pixels.Y))
+	}
+	scale := _params.Scale
+	if scale.X == 0.0 && scale.Y == 0.0 {
+		scale = Vector2fOne
+	}
+	size = NewVector2f(size.X*scale.X, size.Y*scale.Y)
+
+	corners := region.quadCorners(size)
+	uvs := region.cornerUVs()
+	// Mirroring the quad and swapping its corners back in order keeps the winding and flips the image
+	if _params.FlipX {
+		for i := range corners {
+			corners[i].X = -corners[i].X
+		}
+		corners[0], corners[3], corners[1], corners[2] = corners[3], corners[0], corners[2], corners[1]
+		uvs[0], uvs[3], uvs[1], uvs[2] = uvs[3], uvs[0], uvs[2], uvs[1]
+	}
+	if _params.FlipY {
+		for i := range corners {
+			corners[i].Y = -corners[i].Y
+		}
+		corners[0], corners[1], corners[2], corners[3] = corners[1], corners[0], corners[3], corners[2]
+		uvs[0], uvs[1], uvs[2], uvs[3] = uvs[1], uvs[0], uvs[3], uvs[2]
+	}
+
+	centre := NewVector2f(-_params.Pivot.X*size.X, -_params.Pivot.Y*size.Y)
+	for i := range corners {
+		corners[i] = _params.Position.Add(centre).Add(corners[i]).Rotate(_params.Rotation, _params.Position)
+	}
+	return region, corners, uvs, true
+}
 
+// World bounding box of the quad Draw makes from the params, empty without a texture or a region
+func (_params *SpriteDrawParams) Bounds() Rect {
+	_, corners, _, ok := _params.quad()
+	if !ok {
+		return Rect{}
+	}
+	min, max := corners[0], corners[0]
+	for _, corner := range corners[1:] {
+		min = NewVector2f(MinFloat32(min.X, corner.X), MinFloat32(min.Y, corner.Y))
+		max = NewVector2f(MaxFloat32(max.X, corner.X), MaxFloat32(max.Y, corner.Y))
+	}
+	return Rect{Position: min, Size: max.Subtract(min)}
+}
+
+func (self *SpriteBatch) Draw(_params SpriteDrawParams) {
+	region, corners, uvs, ok := _params.quad()
+	if !ok {
+		WarningF("[SPRITES]: Draw called without a texture or a region")
+		return
+	}
+	glyph := newSpriteGlyphQuad(corners, uvs, region.Texture, _params.Tint)
+	if _params.CornerColors != [4]RGBA8{} {
+		glyph.bottomleft.Color = multiplyRGBA8(_params.Tint, _params.CornerColors[0])
+		glyph.topleft.Color = multiplyRGBA8(_params.Tint, _params.CornerColors[1])
+		glyph.topright.Color = multiplyRGBA8(_params.Tint, _params.CornerColors[2])
+		glyph.bottomright.Color = multiplyRGBA8(_params.Tint, _params.CornerColors[3])
+	}
+
+	previousMaterial, previousBlendMode := self.currentMaterial, self.currentBlendMode
+	if _params.Material != nil {
+		self.currentMaterial = _params.Material
+	}
+	self.currentBlendMode = _params.BlendMode.or(self.currentBlendMode)
+	self.addGlyph(glyph)
+	self.currentMaterial, self.currentBlendMode = previousMaterial, previousBlendMode
 }
 
 func (self *SpriteBatch) DrawSprite(_center, _dimensions, _uv1, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyph(_center, _dimensions, _uv1, _uv2, _texture, _tint))
+	self.addGlyph(NewSpriteGlyph(_center, _dimensions, _uv1, _uv2, _texture, _tint))
 }
 
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteOrigin(_center, _uv1, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyph(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)), _uv1, _uv2, _texture, _tint))
+	self.addGlyph(NewSpriteGlyph(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)), _uv1, _uv2, _texture, _tint))
 
 }
+
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteOriginScaled(_center, _uv1, _uv2 Vector2f, _scale float32, _texture *Texture2D, _tint RGBA8) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyph(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)).Scale(_scale), _uv1, _uv2, _texture, _tint))
+	self.addGlyph(NewSpriteGlyph(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)).Scale(_scale), _uv1, _uv2, _texture, _tint))
 
 }
+
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteBottomLeft(_pos, _dimensions, _uv1, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyph(_pos.Add(_dimensions.Scale(0.5)), _dimensions, _uv1, _uv2, _texture, _tint))
+	self.addGlyph(NewSpriteGlyph(_pos.Add(_dimensions.Scale(0.5)), _dimensions, _uv1, _uv2, _texture, _tint))
 }
+
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteBottomRight(_pos, _dimensions, _uv1, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyph(_pos.AddXY(-_dimensions.Scale(0.5).X, _dimensions.Scale(0.5).Y), _dimensions, _uv1, _uv2, _texture, _tint))
+	self.addGlyph(NewSpriteGlyph(_pos.AddXY(-_dimensions.Scale(0.5).X, _dimensions.Scale(0.5).Y), _dimensions, _uv1, _uv2, _texture, _tint))
 }
+
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteBottomLeftOrigin(_pos, _uv1, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyph(_pos.Subtract(NewVector2f(float32(_texture.Width), float32(_texture.Height)).Scale(0.5)), NewVector2f(float32(_texture.Width), float32(_texture.Height)), _uv1, _uv2, _texture, _tint))
+	self.addGlyph(NewSpriteGlyph(_pos.Subtract(NewVector2f(float32(_texture.Width), float32(_texture.Height)).Scale(0.5)), NewVector2f(float32(_texture.Width), float32(_texture.Height)), _uv1, _uv2, _texture, _tint))
 
 }
 
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteOriginRotated(_center, _uv1, _uv2 Vector2f, _texture *Texture2D, _tint RGBA8, _rotation float32) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyphRotated(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)), _uv1, _uv2, _texture, _tint, _rotation))
+	self.addGlyph(NewSpriteGlyphRotated(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)), _uv1, _uv2, _texture, _tint, _rotation))
 }
 
+// Deprecated: use Draw with a SpriteDrawParams
 func (self *SpriteBatch) DrawSpriteOriginScaledRotated(_center, _uv1, _uv2 Vector2f, _scale float32, _texture *Texture2D, _tint RGBA8, _rotation float32) {
-	self.spriteGlyphs = append(self.spriteGlyphs, NewSpriteGlyphRotated(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)).Scale(_scale), _uv1, _uv2, _texture, _tint, _rotation))
+	self.addGlyph(NewSpriteGlyphRotated(_center, NewVector2f(float32(_texture.Width), float32(_texture.Height)).Scale(_scale), _uv1, _uv2, _texture, _tint, _rotation))
+}
+
+func (self *SpriteBatch) DrawRegion(_center, _dimensions Vector2f, _region *TextureRegion, _tint RGBA8, _rotation float32) {
+	self.addGlyph(NewSpriteGlyphRegion(_center, _dimensions, _region, _tint, _rotation))
+}
+
+// Draws _region at its original pixel size times _scale
+func (self *SpriteBatch) DrawRegionOrigin(_center Vector2f, _region *TextureRegion, _scale float32, _tint RGBA8, _rotation float32) {
+	size := _region.GetSize()
+	self.addGlyph(NewSpriteGlyphRegion(_center, NewVector2f(float32(size.X), float32(size.Y)).Scale(_scale), _region, _tint, _rotation))
 }
 
 func (self *SpriteBatch) finalize() {
@@ -546,96 +1063,177 @@ func (self *SpriteBatch) finalize() {
 
 func (self *SpriteBatch) Render(cam *Camera2D) {
 	self.finalize()
+	self.lastFrameStats = RenderStats{}
 
-	UseShader(&self.shader)
-
-	viewMatrix := cam.viewMatrix.Data()
-
-	viewBuffer := new(bytes.Buffer)
-	{
-		err := binary.Write(viewBuffer, binary.LittleEndian, viewMatrix[:])
-		Assert(err == nil, "Error writing viewBuffer")
+	if len(self.renderBatches) == 0 {
+		self.spriteGlyphs = self.spriteGlyphs[:0]
+		return
 	}
 
-	byte_slice2 := viewBuffer.Bytes()
+	canvasContext.Call("bindVertexArray", self.vao)
+	var currentShader *ShaderProgram
+	currentBlendMode := BLEND_DEFAULT
+	for i := 0; i < len(self.renderBatches); i++ {
+		batch := &self.renderBatches[i]
+		if batch.blendMode != currentBlendMode {
+			applyBlendMode(batch.blendMode)
+			currentBlendMode = batch.blendMode
+		}
 
-	b2 := js.Global().Get("Uint8Array").New(len(viewMatrix) * 4)
-	js.CopyBytesToJS(b2, byte_slice2)
+		shader, textureSlots := &self.shader, self.textureSlots
+		if batch.material != nil {
+			shader, textureSlots = batch.material.Shader, batch.material.spriteTextureSlots()
+		}
+		if shader != currentShader {
+			UseShader(shader)
+			shader.SetUniformMat4("view_matrix", cam.viewMatrix.Data())
+			if textureSlots == 1 {
+				shader.SetUniformSampler("genericSampler", 0)
+			} else {
+				for slot := 0; slot < textureSlots; slot++ {
+					shader.SetUniformSampler("samplers["+strconv.Itoa(slot)+"]", slot)
+				}
+			}
+			currentShader = shader
+		}
+		if batch.material != nil {
+			batch.material.apply(MATERIAL_FIRST_TEXTURE_UNIT)
+		}
 
-	viewMatrixJS := js.Global().Get("Float32Array").New(b2.Get("buffer"), b2.Get("byteOffset"), b2.Get("byteLength").Int()/4)
-	for i := 0; i < len(viewMatrix); i++ {
-		viewMatrixJS.SetIndex(i, js.ValueOf(viewMatrix[i]))
+		for slot := 0; slot < batch.numberOfTextures; slot++ {
+			canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0").Int()+slot)
+			canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), batch.textures[slot].glTexture())
+		}
+		canvasContext.Call("drawElements", canvasContext.Get("TRIANGLES"), batch.numberOfElements, canvasContext.Get("UNSIGNED_INT"), batch.offset*4)
+		self.lastFrameStats.DrawCalls++
 	}
-
-	viewmatrix_loc := canvasContext.Call("getUniformLocation", self.shader.ShaderProgramID, "view_matrix")
-	canvasContext.Call("uniformMatrix4fv", viewmatrix_loc, false, viewMatrixJS)
-
+	canvasContext.Call("bindVertexArray", js.Null())
 	canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0"))
-	canvasContext.Call("uniform1i", self.shader.GetUniformLocation("genericSampler"), 0)
+	UnuseShader()
+	applyBlendMode(BLEND_ALPHA)
 
-	canvasContext.Call("bindVertexArray", self.vao)
-	for i := 0; i < len(self.renderBatches); i++ {
-		canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), self.renderBatches[i].texture.textureId)
-		canvasContext.Call("drawArrays", canvasContext.Get("TRIANGLES"), self.renderBatches[i].offset, self.renderBatches[i].numberOfVertices)
-	}
-	canvasContext.Call("bindVertexArray", js.Null())
+	self.lastFrameStats.Sprites = len(self.spriteGlyphs)
+	self.lastFrameStats.Vertices = len(self.vertices)
+	renderStats.DrawCalls += self.lastFrameStats.DrawCalls
+	renderStats.Sprites += self.lastFrameStats.Sprites
+	renderStats.Vertices += self.lastFrameStats.Vertices
 
 	self.renderBatches = self.renderBatches[:0]
 	self.spriteGlyphs = self.spriteGlyphs[:0]
 }
 
-func (self *SpriteBatch) createRenderBatches() {
-	vertices := make([]Vertex, len(self.spriteGlyphs)*6)
+// Orders the glyphs by layer, and by blend mode, material then texture inside each layer when SPRITE_SORT_TEXTURE is used.
+// The sort is stable so glyphs that compare equal keep their drawing order
+func (self *SpriteBatch) sortGlyphs() {
+	self.sortedGlyphs = self.sortedGlyphs[:0]
+	sameLayer := true
+	for i := range self.spriteGlyphs {
+		glyph := &self.spriteGlyphs[i]
+		glyph.blendMode = glyph.blendMode.or(self.layerBlendModes[glyph.layer]).or(self.BlendMode).or(BLEND_ALPHA)
+		self.sortedGlyphs = append(self.sortedGlyphs, glyph)
+		if self.spriteGlyphs[i].layer != self.spriteGlyphs[0].layer {
+			sameLayer = false
+		}
+	}
+
+	switch self.SortMode {
+	case SPRITE_SORT_NONE:
+		if sameLayer {
+			return
+		}
+		sort.SliceStable(self.sortedGlyphs, func(i, j int) bool {
+			return self.sortedGlyphs[i].layer < self.sortedGlyphs[j].layer
+		})
+	case SPRITE_SORT_TEXTURE:
+		sort.SliceStable(self.sortedGlyphs, func(i, j int) bool {
+			a, b := self.sortedGlyphs[i], self.sortedGlyphs[j]
+			if a.layer != b.layer {
+				return a.layer < b.layer
+			}
+			if a.blendMode != b.blendMode {
+				return a.blendMode < b.blendMode
+			}
+			if a.material != b.material {
+				return materialUid(a.material) < materialUid(b.material)
+			}
+			return a.texture.uid < b.texture.uid
+		})
+	}
+}
 
-	if len(vertices) == 0 {
+func (self *SpriteBatch) createRenderBatches() {
+	if len(self.spriteGlyphs) == 0 {
 		return
 	}
 
-	offset := 0
-	vertexNum := 0
-
-	self.renderBatches = append(self.renderBatches, NewRenderBatch(offset, 6, self.spriteGlyphs[0].texture))
-	vertices[vertexNum] = self.spriteGlyphs[0].bottomleft
-	vertexNum++
-	vertices[vertexNum] = self.spriteGlyphs[0].topright
-	vertexNum++
-	vertices[vertexNum] = self.spriteGlyphs[0].bottomright
-	vertexNum++
-	vertices[vertexNum] = self.spriteGlyphs[0].bottomleft
-	vertexNum++
-	vertices[vertexNum] = self.spriteGlyphs[0].topright
-	vertexNum++
-	vertices[vertexNum] = self.spriteGlyphs[0].topleft
-	vertexNum++
-	offset += 6
-
-	for i := 1; i < len(self.spriteGlyphs); i++ {
-		if !self.spriteGlyphs[i-1].texture.textureId.Equal(self.spriteGlyphs[i].texture.textureId) {
-			self.renderBatches = append(self.renderBatches, NewRenderBatch(offset, 6, self.spriteGlyphs[i].texture))
-		} else {
-			self.renderBatches[len(self.renderBatches)-1].numberOfVertices += 6
+	self.sortGlyphs()
+	self.vertices = self.vertices[:0]
+
+	for i, glyph := range self.sortedGlyphs {
+		slot := -1
+		if last := len(self.renderBatches) - 1; last >= 0 && self.renderBatches[last].material == glyph.material && self.renderBatches[last].blendMode == glyph.blendMode {
+			current := &self.renderBatches[len(self.renderBatches)-1]
+			textureSlots := self.textureSlots
+			if current.material != nil {
+				textureSlots = current.material.spriteTextureSlots()
+			}
+			slot = current.textureSlot(glyph.texture)
+			if slot == -1 && current.numberOfTextures < textureSlots {
+				slot = current.numberOfTextures
+				current.textures[slot] = glyph.texture
+				current.numberOfTextures++
+			}
+			if slot != -1 {
+				current.numberOfElements += 6
+			}
+		}
+		if slot == -1 {
+			slot = 0
+			self.renderBatches = append(self.renderBatches, NewRenderBatch(i*6, 6, glyph.texture, glyph.material))
+			self.renderBatches[len(self.renderBatches)-1].blendMode = glyph.blendMode
 		}
 
-		vertices[vertexNum] = self.spriteGlyphs[i].bottomleft
-		vertexNum++
-		vertices[vertexNum] = self.spriteGlyphs[i].topright
-		vertexNum++
-		vertices[vertexNum] = self.spriteGlyphs[i].bottomright
-		vertexNum++
-		vertices[vertexNum] = self.spriteGlyphs[i].bottomleft
-		vertexNum++
-		vertices[vertexNum] = self.spriteGlyphs[i].topright
-		vertexNum++
-		vertices[vertexNum] = self.spriteGlyphs[i].topleft
-		vertexNum++
-		offset += 6
+		textureSlot := float32(slot)
+		self.vertices = append(self.vertices,
+			spriteVertex{glyph.bottomleft, textureSlot},
+			spriteVertex{glyph.topleft, textureSlot},
+			spriteVertex{glyph.topright, textureSlot},
+			spriteVertex{glyph.bottomright, textureSlot},
+		)
 	}
+
+	self.uploadVertices()
+}
+
+// Streams the vertices into the vertex buffer, the buffers are only reallocated when the number of quads outgrows them
+func (self *SpriteBatch) uploadVertices() {
+	if self.generation != contextGeneration {
+		self.createBuffers()
+	}
+	canvasContext.Call("bindVertexArray", self.vao)
 	canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), self.vbo)
 
-	jsVerts := vertexBufferToJsVertexBuffer(vertices)
+	numberOfQuads := len(self.vertices) / 4
+	if numberOfQuads > self.quadsCapacity {
+		self.quadsCapacity = MaxInt(MaxInt(numberOfQuads, self.quadsCapacity*2), 64)
 
-	canvasContext.Call("bufferData", canvasContext.Get("ARRAY_BUFFER"), jsVerts, canvasContext.Get("STATIC_DRAW"))
+		canvasContext.Call("bufferData", canvasContext.Get("ARRAY_BUFFER"), self.quadsCapacity*4*spriteVertexSize, canvasContext.Get("DYNAMIC_DRAW"))
+		self.jsVertices = js.Global().Get("Uint8Array").New(self.quadsCapacity * 4 * spriteVertexSize)
 
-	canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), js.Null())
+		indices := make([]int32, 0, self.quadsCapacity*6)
+		for q := 0; q < self.quadsCapacity; q++ {
+			v := int32(q * 4)
+			// bottomleft, topright, bottomright - bottomleft, topright, topleft
+			indices = append(indices, v, v+2, v+3, v, v+2, v+1)
+		}
+		canvasContext.Call("bindBuffer", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), self.ibo)
+		canvasContext.Call("bufferData", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), int32BufferToJsInt32Buffer(indices), canvasContext.Get("STATIC_DRAW"))
+	}
 
+	byteLength := len(self.vertices) * spriteVertexSize
+	js.CopyBytesToJS(self.jsVertices, spriteVertexSliceAsBytes(self.vertices))
+	canvasContext.Call("bufferSubData", canvasContext.Get("ARRAY_BUFFER"), 0, self.jsVertices, 0, byteLength)
+
+	canvasContext.Call("bindVertexArray", js.Null())
+	canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), js.Null())
 }
@@ -0,0 +1,436 @@
+package chai
+
+import (
+	"math"
+
+	"github.com/ByteArena/box2d"
+)
+
+/* ####### Scene Settings ####### */
+
+// Lighting of a scene. When enabled, everything drawn is multiplied by a light map that starts at Ambient
+// and receives every LightComponent of the scene
+type SceneLighting struct {
+	Enabled bool
+	// Light that reaches everything, black leaves only the lights visible
+	Ambient RGBA8
+	// Physics colliders (sensors excepted) block the lights that cast shadows, like OccluderComponent does
+	CollidersCastShadows bool
+}
+
+/* ####### Components ####### */
+
+type LightType uint8
+
+const (
+	LIGHT_POINT LightType = iota
+	// A point light limited to a cone
+	LIGHT_SPOT
+	// Lights the whole view from one direction, like the sun or the moon
+	LIGHT_DIRECTIONAL
+)
+
+type LightComponent struct {
+	Component
+	Type      LightType
+	Color     RGBA8
+	Intensity float32
+	// Reach of point and spot lights in world units
+	Radius float32
+	// How the light fades towards Radius, 1 is linear and higher values fade faster
+	Falloff float32
+	// Full width of the spot cone in degrees
+	ConeAngle float32
+	// Degrees at the edge of the cone that fade out
+	ConeSoftness float32
+	// Counter-clockwise from +x in degrees, added to the entity rotation. Used by spot and directional lights
+	Direction float32
+	// Distance of the light above the scene, only used by normal maps
+	Height      float32
+	CastShadows bool
+	// Position of the light relative to the entity
+	Offset Vector2f
+}
+
+func (t *LightComponent) ComponentSet(val interface{}) { *t = val.(LightComponent) }
+
+func NewPointLight(_color RGBA8, _intensity, _radius float32) LightComponent {
+	return LightComponent{
+		Type:        LIGHT_POINT,
+		Color:       _color,
+		Intensity:   _intensity,
+		Radius:      _radius,
+		Falloff:     1.0,
+		Height:      _radius * 0.25,
+		CastShadows: true,
+	}
+}
+
+func NewSpotLight(_color RGBA8, _intensity, _radius, _coneAngle, _direction float32) LightComponent {
+	light := NewPointLight(_color, _intensity, _radius)
+	light.Type = LIGHT_SPOT
+	light.ConeAngle = _coneAngle
+	light.ConeSoftness = _coneAngle * 0.2
+	light.Direction = _direction
+	return light
+}
+
+func NewDirectionalLight(_color RGBA8, _intensity, _direction float32) LightComponent {
+	return LightComponent{
+		Type:      LIGHT_DIRECTIONAL,
+		Color:     _color,
+		Intensity: _intensity,
+		Direction: _direction,
+		Height:    1.0,
+	}
+}
+
+// Blocks the lights that cast shadows
+type OccluderComponent struct {
+	Component
+	// Outline relative to the entity, it turns with the entity
+	Points []Vector2f
+	// Connects the last point back to the first
+	Closed bool
+}
+
+func (t *OccluderComponent) ComponentSet(val interface{}) { *t = val.(OccluderComponent) }
+
+func NewOccluderComponent(_points []Vector2f, _closed bool) OccluderComponent {
+	return OccluderComponent{Points: _points, Closed: _closed}
+}
+
+// A rectangle of _size centred on the entity
+func NewBoxOccluder(_size Vector2f) OccluderComponent {
+	half := _size.Scale(0.5)
+	return NewOccluderComponent([]Vector2f{
+		NewVector2f(-half.X, -half.Y), NewVector2f(half.X, -half.Y),
+		NewVector2f(half.X, half.Y), NewVector2f(-half.X, half.Y),
+	}, true)
+}
+
+/* ####### Rendering ####### */
+
+const LIGHT_SHADER_VERTEX = `#version 300 es
+
+precision highp float;
+
+layout(location = 0) in vec2 coordinates;
+
+uniform mat4 view_matrix;
+
+out vec2 world_position;
+
+void main(void) {
+	gl_Position = view_matrix * vec4(coordinates, 0.0, 1.0);
+	gl_Position.z = 0.0;
+	gl_Position.w = 1.0;
+	world_position = coordinates;
+}
+`
+
+const LIGHT_SHADER_FRAGMENT = `#version 300 es
+
+precision highp float;
+
+in vec2 world_position;
+
+uniform int lightType;
+uniform vec2 lightPosition;
+uniform vec4 lightColor;
+uniform float radius;
+uniform float falloff;
+uniform vec2 direction;
+uniform float coneOuterCos;
+uniform float coneInnerCos;
+uniform float height;
+uniform sampler2D normalMap;
+uniform vec2 resolution;
+
+out vec4 fragColor;
+
+void main(void) {
+	vec3 toLight;
+	float attenuation = 1.0;
+	if (lightType == 2) {
+		toLight = normalize(vec3(-direction, height));
+	} else {
+		vec2 delta = lightPosition - world_position;
+		float distance = length(delta);
+		if (distance >= radius) {
+			discard;
+		}
+		attenuation = pow(1.0 - distance / radius, falloff);
+		if (lightType == 1) {
+			attenuation *= smoothstep(coneOuterCos, coneInnerCos, dot(normalize(-delta), direction));
+		}
+		toLight = normalize(vec3(delta, height));
+	}
+
+	// Pixels without a normal map (alpha 0) are lit evenly
+	vec4 normalSample = texture(normalMap, gl_FragCoord.xy / resolution);
+	vec3 normal = normalize(normalSample.xyz * 2.0 - 1.0);
+	float diffuse = mix(1.0, max(dot(normal, toLight), 0.0), normalSample.a);
+
+	fragColor = vec4(lightColor.rgb * lightColor.a * attenuation * diffuse, 1.0);
+}
+`
+
+// Filled with the normal maps of the sprites drawn this frame, see SpriteComponent.NormalMap
+var NormalMaps SpriteBatch
+
+type lightingRenderer struct {
+	lightTarget  RenderTarget
+	normalTarget RenderTarget
+	created      bool
+	shapes       ShapeBatch
+	material     *Material
+	// Occluder edges of the frame, in world space
+	segments [][2]Vector2f
+}
+
+var lighting lightingRenderer
+
+func (lr *lightingRenderer) ensure(_width, _height int) {
+	if !lr.created {
+		lr.lightTarget = NewRenderTarget(_width, _height, true)
+		lr.normalTarget = NewRenderTarget(_width, _height, false)
+		lr.shapes.Init()
+		lr.shapes.BlendMode = BLEND_ADDITIVE
+		shader := &ShaderProgram{}
+		shader.ParseShader(LIGHT_SHADER_VERTEX, LIGHT_SHADER_FRAGMENT)
+		shader.CreateShaderProgram()
+		lr.material = NewMaterial(shader)
+		lr.created = true
+		return
+	}
+	if lr.lightTarget.Width != _width || lr.lightTarget.Height != _height {
+		lr.lightTarget.Resize(_width, _height)
+		lr.normalTarget.Resize(_width, _height)
+	}
+}
+
+type lightCompositePass struct{}
+
+func (p *lightCompositePass) fragmentSource() string {
+	return `
+void main(void) {
+	fragColor = texture(screenTexture, vertex_UV);
+}
+`
+}
+
+func (p *lightCompositePass) setUniforms(_shader *ShaderProgram) {}
+
+// Multiplies what the camera drew with the light map of _scene, the camera viewport is the one currently set
+func renderSceneLighting(_scene *Scene, _cam *Camera2D) {
+	if !_scene.Lighting.Enabled {
+		NormalMaps.Reset()
+		return
+	}
+
+	viewport := canvasContext.Call("getParameter", canvasContext.Get("VIEWPORT"))
+	x, y, width, height := viewport.Index(0).Int(), viewport.Index(1).Int(), viewport.Index(2).Int(), viewport.Index(3).Int()
+	if width <= 0 || height <= 0 {
+		NormalMaps.Reset()
+		return
+	}
+	lighting.ensure(width, height)
+
+	// The render targets cover the viewport alone, the camera scissor would cut them
+	scissor := canvasContext.Call("isEnabled", canvasContext.Get("SCISSOR_TEST")).Bool()
+	canvasContext.Call("disable", canvasContext.Get("SCISSOR_TEST"))
+
+	lighting.normalTarget.Bind()
+	canvasContext.Call("clearColor", 0.5, 0.5, 1.0, 0.0)
+	canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+	NormalMaps.Render(_cam)
+	lighting.normalTarget.Unbind()
+
+	lighting.lightTarget.Bind()
+	ambient := _scene.Lighting.Ambient
+	canvasContext.Call("clearColor", ambient.GetColorRFloat32(), ambient.GetColorGFloat32(), ambient.GetColorBFloat32(), 1.0)
+	canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT").Int()|canvasContext.Get("STENCIL_BUFFER_BIT").Int())
+
+	previousScene := current_scene
+	current_scene = _scene
+	lighting.collectOccluders(_scene)
+	EachEntity(LightComponent{}, func(entity *EcsEntity, a interface{}) {
+		if !IsRenderLayerVisible(entity.RenderLayer) {
+			return
+		}
+		light := a.(LightComponent)
+		lighting.drawLight(&light, entity, _cam, width, height)
+	})
+	current_scene = previousScene
+	lighting.lightTarget.Unbind()
+
+	if scissor {
+		canvasContext.Call("enable", canvasContext.Get("SCISSOR_TEST"))
+	}
+	canvasContext.Call("viewport", x, y, width, height)
+	canvasContext.Call("blendFunc", canvasContext.Get("DST_COLOR"), canvasContext.Get("ZERO"))
+	applyPostProcessPass(&lightCompositePass{}, lighting.lightTarget.GetTexture(), width, height)
+	applyBlendMode(BLEND_ALPHA)
+}
+
+func (lr *lightingRenderer) drawLight(_light *LightComponent, _entity *EcsEntity, _cam *Camera2D, _width, _height int) {
+	position := _entity.Pos.Add(_light.Offset.RotateCenter(_entity.Rot))
+	angle := float64((_light.Direction + _entity.Rot) * PI / 180.0)
+	direction := NewVector2f(float32(math.Cos(angle)), float32(math.Sin(angle)))
+
+	// The area the light can reach
+	var area Rect
+	if _light.Type == LIGHT_DIRECTIONAL {
+		area = _cam.GetViewBounds()
+	} else {
+		if _light.Radius <= 0.0 {
+			return
+		}
+		area = NewRect(position.X-_light.Radius, position.Y-_light.Radius, _light.Radius*2.0, _light.Radius*2.0)
+		if !area.Overlaps(_cam.GetViewBounds()) {
+			return
+		}
+	}
+
+	shadows := _light.CastShadows && len(lr.segments) > 0
+	if shadows {
+		canvasContext.Call("clear", canvasContext.Get("STENCIL_BUFFER_BIT"))
+		canvasContext.Call("enable", canvasContext.Get("STENCIL_TEST"))
+		canvasContext.Call("colorMask", false, false, false, false)
+		canvasContext.Call("stencilFunc", canvasContext.Get("ALWAYS"), 1, 0xFF)
+		canvasContext.Call("stencilOp", canvasContext.Get("KEEP"), canvasContext.Get("KEEP"), canvasContext.Get("REPLACE"))
+		lr.shapes.SetMaterial(nil)
+		lr.drawShadows(_light, position, direction, area)
+		lr.shapes.Render(_cam)
+
+		canvasContext.Call("colorMask", true, true, true, true)
+		canvasContext.Call("stencilFunc", canvasContext.Get("EQUAL"), 0, 0xFF)
+		canvasContext.Call("stencilOp", canvasContext.Get("KEEP"), canvasContext.Get("KEEP"), canvasContext.Get("KEEP"))
+	}
+
+	falloff := _light.Falloff
+	if falloff <= 0.0 {
+		falloff = 1.0
+	}
+	halfCone := float64(_light.ConeAngle/2.0) * math.Pi / 180.0
+	innerCone := math.Max(halfCone-float64(_light.ConeSoftness)*math.Pi/180.0, 0.0)
+	color := _light.Color
+	material := lr.material
+	material.SetInt("lightType", int(_light.Type))
+	material.SetVec2("lightPosition", position)
+	material.SetVec4("lightColor", color.GetColorRFloat32(), color.GetColorGFloat32(), color.GetColorBFloat32(), _light.Intensity)
+	material.SetFloat("radius", _light.Radius)
+	material.SetFloat("falloff", falloff)
+	material.SetVec2("direction", direction)
+	material.SetFloat("coneOuterCos", float32(math.Cos(halfCone)))
+	material.SetFloat("coneInnerCos", float32(math.Cos(innerCone)))
+	material.SetFloat("height", _light.Height)
+	material.SetTexture("normalMap", lr.normalTarget.GetTexture())
+	material.SetVec2("resolution", NewVector2f(float32(_width), float32(_height)))
+
+	lr.shapes.SetMaterial(material)
+	lr.shapes.DrawFillPolygon([]Vector2f{area.Min(), NewVector2f(area.Max().X, area.Min().Y), area.Max(), NewVector2f(area.Min().X, area.Max().Y)}, WHITE)
+	lr.shapes.Render(_cam)
+	lr.shapes.SetMaterial(nil)
+
+	if shadows {
+		canvasContext.Call("disable", canvasContext.Get("STENCIL_TEST"))
+	}
+}
+
+// Extrudes every occluder edge away from the light, the stencil then keeps the light out of these areas
+func (lr *lightingRenderer) drawShadows(_light *LightComponent, _position, _direction Vector2f, _area Rect) {
+	far := _area.Size.X + _area.Size.Y
+	for _, segment := range lr.segments {
+		a, b := segment[0], segment[1]
+		if _light.Type == LIGHT_DIRECTIONAL {
+			offset := _direction.Scale(far)
+			lr.shapes.DrawFillTriangle(a, b, b.Add(offset), WHITE)
+			lr.shapes.DrawFillTriangle(a, b.Add(offset), a.Add(offset), WHITE)
+			continue
+		}
+		if distanceToSegment(_position, a, b) >= _light.Radius {
+			continue
+		}
+		toA, toB := a.Subtract(_position).Normalize(), b.Subtract(_position).Normalize()
+		farA, farB := _position.Add(toA.Scale(far)), _position.Add(toB.Scale(far))
+		// The middle point keeps the far edge outside of the light even when the edge spans a wide angle
+		bisector := toA.Add(toB)
+		if bisector.LengthSquared() < 1e-6 {
+			bisector = toA.Perpendicular()
+		}
+		halfAngleCos := MaxFloat32(DotProduct(toA, bisector.Normalize()), 0.05)
+		farMiddle := _position.Add(bisector.Normalize().Scale(far / halfAngleCos))
+		lr.shapes.DrawFillTriangle(a, b, farB, WHITE)
+		lr.shapes.DrawFillTriangle(a, farB, farMiddle, WHITE)
+		lr.shapes.DrawFillTriangle(a, farMiddle, farA, WHITE)
+	}
+}
+
+func distanceToSegment(_point, _a, _b Vector2f) float32 {
+	ab := _b.Subtract(_a)
+	t := float32(0.0)
+	if lengthSquared := ab.LengthSquared(); lengthSquared > 0.0 {
+		t = ClampFloat32(DotProduct(_point.Subtract(_a), ab)/lengthSquared, 0.0, 1.0)
+	}
+	offset := _point.Subtract(_a.Add(ab.Scale(t)))
+	return offset.Length()
+}
+
+func (lr *lightingRenderer) collectOccluders(_scene *Scene) {
+	lr.segments = lr.segments[:0]
+	EachEntity(OccluderComponent{}, func(entity *EcsEntity, a interface{}) {
+		occluder := a.(OccluderComponent)
+		count := len(occluder.Points)
+		if count < 2 {
+			return
+		}
+		last := count - 1
+		if occluder.Closed {
+			last = count
+		}
+		for i := 0; i < last; i++ {
+			p1 := entity.Pos.Add(occluder.Points[i].RotateCenter(entity.Rot))
+			p2 := entity.Pos.Add(occluder.Points[(i+1)%count].RotateCenter(entity.Rot))
+			lr.segments = append(lr.segments, [2]Vector2f{p1, p2})
+		}
+	})
+
+	if !_scene.Lighting.CollidersCastShadows {
+		return
+	}
+	for body := physics_world.box2dWorld.GetBodyList(); body != nil; body = body.GetNext() {
+		transform := body.GetTransform()
+		toWorld := func(_v box2d.B2Vec2) Vector2f {
+			return Vector2fFromBoxVec(box2d.B2TransformVec2Mul(transform, _v))
+		}
+		for fixture := body.GetFixtureList(); fixture != nil; fixture = fixture.GetNext() {
+			if fixture.IsSensor() {
+				continue
+			}
+			switch shape := fixture.GetShape().(type) {
+			case *box2d.B2PolygonShape:
+				for i := 0; i < shape.M_count; i++ {
+					lr.segments = append(lr.segments, [2]Vector2f{toWorld(shape.M_vertices[i]), toWorld(shape.M_vertices[(i+1)%shape.M_count])})
+				}
+			case *box2d.B2ChainShape:
+				for i := 0; i+1 < shape.M_count; i++ {
+					lr.segments = append(lr.segments, [2]Vector2f{toWorld(shape.M_vertices[i]), toWorld(shape.M_vertices[i+1])})
+				}
+			case *box2d.B2EdgeShape:
+				lr.segments = append(lr.segments, [2]Vector2f{toWorld(shape.M_vertex1), toWorld(shape.M_vertex2)})
+			case *box2d.B2CircleShape:
+				const circleSegments = 12
+				center := shape.M_p
+				for i := 0; i < circleSegments; i++ {
+					a1 := 2.0 * math.Pi * float64(i) / circleSegments
+					a2 := 2.0 * math.Pi * float64(i+1) / circleSegments
+					p1 := box2d.MakeB2Vec2(center.X+math.Cos(a1)*shape.M_radius, center.Y+math.Sin(a1)*shape.M_radius)
+					p2 := box2d.MakeB2Vec2(center.X+math.Cos(a2)*shape.M_radius, center.Y+math.Sin(a2)*shape.M_radius)
+					lr.segments = append(lr.segments, [2]Vector2f{toWorld(p1), toWorld(p2)})
+				}
+			}
+		}
+	}
+}
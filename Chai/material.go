@@ -0,0 +1,175 @@
+package chai
+
+import "sort"
+
+type uniformKind uint8
+
+const (
+	uniformFloat uniformKind = iota
+	uniformVec2
+	uniformVec3
+	uniformVec4
+	uniformMat4
+	uniformInt
+)
+
+type materialUniform struct {
+	kind   uniformKind
+	values [16]float32
+	ival   int
+}
+
+type materialTexture struct {
+	name    string
+	texture *Texture2D
+}
+
+// A shader together with the values of its uniforms and the extra textures it samples.
+// Sprites and shapes drawn with different materials end up in different draw calls
+type Material struct {
+	Shader *ShaderProgram
+	// Used by everything drawn with the material unless the draw call sets its own
+	BlendMode    BlendMode
+	uniforms     map[string]materialUniform
+	uniformNames []string
+	textures     []materialTexture
+	uid          uint32
+	textureSlots int
+}
+
+// Texture units below this one are used by the sprite batch itself
+const MATERIAL_FIRST_TEXTURE_UNIT = SPRITE_BATCH_MAX_TEXTURES
+
+var materialUidCounter uint32
+
+func NewMaterial(_shader *ShaderProgram) *Material {
+	materialUidCounter++
+	return &Material{
+		Shader:   _shader,
+		uniforms: make(map[string]materialUniform),
+		uid:      materialUidCounter,
+	}
+}
+
+// Builds a material for SpriteBatch from a fragment shader, it is linked against the default sprite vertex shader,
+// so the fragment shader receives vertex_FragColor, vertex_UV and vertex_TextureSlot
+func NewSpriteMaterial(_fragmentSource string) *Material {
+	shader := &ShaderProgram{}
+	shader.ParseShader(SPRITES_SHADER_VERTEX, _fragmentSource)
+	shader.CreateShaderProgram()
+	return NewMaterial(shader)
+}
+
+// Builds a material for ShapeBatch from a fragment shader that receives vertex_FragColor
+func NewShapeMaterial(_fragmentSource string) *Material {
+	shader := &ShaderProgram{}
+	shader.ParseShader(SHAPES_SHADER_VERTEX, _fragmentSource)
+	shader.CreateShaderProgram()
+	return NewMaterial(shader)
+}
+
+func (m *Material) setUniform(_name string, _uniform materialUniform) {
+	if _, ok := m.uniforms[_name]; !ok {
+		m.uniformNames = append(m.uniformNames, _name)
+	}
+	m.uniforms[_name] = _uniform
+}
+
+func (m *Material) SetFloat(_name string, _value float32) {
+	u := materialUniform{kind: uniformFloat}
+	u.values[0] = _value
+	m.setUniform(_name, u)
+}
+
+func (m *Material) SetVec2(_name string, _value Vector2f) {
+	u := materialUniform{kind: uniformVec2}
+	u.values[0], u.values[1] = _value.X, _value.Y
+	m.setUniform(_name, u)
+}
+
+func (m *Material) SetVec3(_name string, _x, _y, _z float32) {
+	u := materialUniform{kind: uniformVec3}
+	u.values[0], u.values[1], u.values[2] = _x, _y, _z
+	m.setUniform(_name, u)
+}
+
+func (m *Material) SetVec4(_name string, _x, _y, _z, _w float32) {
+	u := materialUniform{kind: uniformVec4}
+	u.values[0], u.values[1], u.values[2], u.values[3] = _x, _y, _z, _w
+	m.setUniform(_name, u)
+}
+
+func (m *Material) SetColor(_name string, _color RGBA8) {
+	m.SetVec4(_name, _color.GetColorRFloat32(), _color.GetColorGFloat32(), _color.GetColorBFloat32(), _color.GetColorAFloat32())
+}
+
+// _matrix holds 16 values in column-major order
+func (m *Material) SetMat4(_name string, _matrix []float32) {
+	Assert(len(_matrix) == 16, "Material.SetMat4: expected 16 values, got %v", len(_matrix))
+	u := materialUniform{kind: uniformMat4}
+	copy(u.values[:], _matrix)
+	m.setUniform(_name, u)
+}
+
+func (m *Material) SetInt(_name string, _value int) {
+	m.setUniform(_name, materialUniform{kind: uniformInt, ival: _value})
+}
+
+// Binds a texture to the sampler uniform _name, the material picks the texture unit
+func (m *Material) SetTexture(_name string, _texture *Texture2D) {
+	for i := range m.textures {
+		if m.textures[i].name == _name {
+			m.textures[i].texture = _texture
+			return
+		}
+	}
+	m.textures = append(m.textures, materialTexture{_name, _texture})
+	sort.SliceStable(m.textures, func(i, j int) bool { return m.textures[i].name < m.textures[j].name })
+}
+
+// Number of sprite textures a draw call with this material can sample from
+func (m *Material) spriteTextureSlots() int {
+	if m.textureSlots == 0 {
+		m.textureSlots = 1
+		if m.Shader.HasUniform("samplers[0]") {
+			m.textureSlots = SPRITE_BATCH_MAX_TEXTURES
+		}
+	}
+	return m.textureSlots
+}
+
+// Uploads the uniforms and binds the textures, the shader has to be in use
+func (m *Material) apply(_firstTextureUnit int) {
+	for _, name := range m.uniformNames {
+		u := m.uniforms[name]
+		switch u.kind {
+		case uniformFloat:
+			m.Shader.SetUniformFloat(name, u.values[0])
+		case uniformVec2:
+			m.Shader.SetUniformVec2(name, NewVector2f(u.values[0], u.values[1]))
+		case uniformVec3:
+			m.Shader.SetUniformVec3(name, u.values[0], u.values[1], u.values[2])
+		case uniformVec4:
+			m.Shader.SetUniformVec4(name, u.values[0], u.values[1], u.values[2], u.values[3])
+		case uniformMat4:
+			m.Shader.SetUniformMat4(name, u.values[:])
+		case uniformInt:
+			m.Shader.SetUniformInt(name, u.ival)
+		}
+	}
+
+	for i, t := range m.textures {
+		unit := _firstTextureUnit + i
+		canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0").Int()+unit)
+		canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), t.texture.glTexture())
+		m.Shader.SetUniformSampler(t.name, unit)
+	}
+	canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0"))
+}
+
+func materialUid(_material *Material) uint32 {
+	if _material == nil {
+		return 0
+	}
+	return _material.uid
+}
@@ -65,6 +65,10 @@ func LerpUint8(_a, _b uint8, _t float32) uint8 {
 	return _a + (_b-_a)*uint8(_t*255)
 }
 
+func LerpVector2f(_a, _b Vector2f, _t float32) Vector2f {
+	return Vector2f{LerpFloat32(_a.X, _b.X, _t), LerpFloat32(_a.Y, _b.Y, _t)}
+}
+
 func Float1ToUint8_255(_inp float32) uint8 {
 	output := (_inp * 255.0)
 	if output >= 255 {
@@ -319,6 +323,11 @@ func DotProduct(v1, v2 Vector2f) float32 {
 	return v1.X*v2.X + v1.Y*v2.Y
 }
 
+// Z component of the 3D cross product, positive when v2 is counter-clockwise from v1
+func CrossProduct(v1, v2 Vector2f) float32 {
+	return v1.X*v2.Y - v1.Y*v2.X
+}
+
 func (v Vector2f) Perpendicular() Vector2f {
 	return Vector2f{-v.Y, v.X}
 }
@@ -471,3 +480,245 @@ func (v Vector2i) ToString() string {
 ############## VECTOR2  - VECTOR2 #################################################
 ###################################################################################
 */
+
+/*
+############## RECT ###############################################################
+###################################################################################
+*/
+
+// Axis-aligned rectangle, Position is the bottom-left corner
+type Rect struct {
+	Position Vector2f
+	Size     Vector2f
+}
+
+func NewRect(x, y, width, height float32) Rect {
+	return Rect{Position: NewVector2f(x, y), Size: NewVector2f(width, height)}
+}
+
+func (r Rect) Min() Vector2f {
+	return r.Position
+}
+
+func (r Rect) Max() Vector2f {
+	return r.Position.Add(r.Size)
+}
+
+func (r Rect) Center() Vector2f {
+	return r.Position.Add(r.Size.Scale(0.5))
+}
+
+func (r Rect) Contains(_point Vector2f) bool {
+	return _point.X >= r.Position.X && _point.Y >= r.Position.Y && _point.X <= r.Position.X+r.Size.X && _point.Y <= r.Position.Y+r.Size.Y
+}
+
+func (r Rect) Overlaps(_other Rect) bool {
+	return r.Position.X <= _other.Position.X+_other.Size.X && _other.Position.X <= r.Position.X+r.Size.X &&
+		r.Position.Y <= _other.Position.Y+_other.Size.Y && _other.Position.Y <= r.Position.Y+r.Size.Y
+}
+
+/*
+############## CURVES #############################################################
+###################################################################################
+*/
+
+func QuadraticBezier(_p0, _p1, _p2 Vector2f, _t float32) Vector2f {
+	u := 1.0 - _t
+	return _p0.Scale(u * u).Add(_p1.Scale(2.0 * u * _t)).Add(_p2.Scale(_t * _t))
+}
+
+// Derivative of the curve at _t, not normalized
+func QuadraticBezierTangent(_p0, _p1, _p2 Vector2f, _t float32) Vector2f {
+	return _p1.Subtract(_p0).Scale(2.0 * (1.0 - _t)).Add(_p2.Subtract(_p1).Scale(2.0 * _t))
+}
+
+func CubicBezier(_p0, _p1, _p2, _p3 Vector2f, _t float32) Vector2f {
+	u := 1.0 - _t
+	return _p0.Scale(u * u * u).Add(_p1.Scale(3.0 * u * u * _t)).Add(_p2.Scale(3.0 * u * _t * _t)).Add(_p3.Scale(_t * _t * _t))
+}
+
+// Derivative of the curve at _t, not normalized
+func CubicBezierTangent(_p0, _p1, _p2, _p3 Vector2f, _t float32) Vector2f {
+	u := 1.0 - _t
+	return _p1.Subtract(_p0).Scale(3.0 * u * u).Add(_p2.Subtract(_p1).Scale(6.0 * u * _t)).Add(_p3.Subtract(_p2).Scale(3.0 * _t * _t))
+}
+
+// Uniform Catmull-Rom segment between _p1 and _p2
+func CatmullRom(_p0, _p1, _p2, _p3 Vector2f, _t float32) Vector2f {
+	t2 := _t * _t
+	t3 := t2 * _t
+	return _p1.Scale(2.0).
+		Add(_p2.Subtract(_p0).Scale(_t)).
+		Add(_p0.Scale(2.0).Subtract(_p1.Scale(5.0)).Add(_p2.Scale(4.0)).Subtract(_p3).Scale(t2)).
+		Add(_p1.Scale(3.0).Subtract(_p0).Subtract(_p2.Scale(3.0)).Add(_p3).Scale(t3)).
+		Scale(0.5)
+}
+
+// Derivative of the Catmull-Rom segment at _t, not normalized
+func CatmullRomTangent(_p0, _p1, _p2, _p3 Vector2f, _t float32) Vector2f {
+	return _p2.Subtract(_p0).
+		Add(_p0.Scale(2.0).Subtract(_p1.Scale(5.0)).Add(_p2.Scale(4.0)).Subtract(_p3).Scale(2.0 * _t)).
+		Add(_p1.Scale(3.0).Subtract(_p0).Subtract(_p2.Scale(3.0)).Add(_p3).Scale(3.0 * _t * _t)).
+		Scale(0.5)
+}
+
+// Samples a Catmull-Rom spline passing through every point, _segments points per span.
+// The end points are duplicated so the curve starts and ends on them
+func CatmullRomPoints(_points []Vector2f, _segments int, _closed bool) []Vector2f {
+	count := len(_points)
+	if count < 2 || _segments < 1 {
+		return append([]Vector2f{}, _points...)
+	}
+	at := func(i int) Vector2f {
+		if _closed {
+			return _points[((i%count)+count)%count]
+		}
+		return _points[int(ClampFloat32(float32(i), 0, float32(count-1)))]
+	}
+
+	spans := count - 1
+	if _closed {
+		spans = count
+	}
+	result := make([]Vector2f, 0, spans*_segments+1)
+	for span := 0; span < spans; span++ {
+		for i := 0; i < _segments; i++ {
+			result = append(result, CatmullRom(at(span-1), at(span), at(span+1), at(span+2), float32(i)/float32(_segments)))
+		}
+	}
+	if !_closed {
+		result = append(result, _points[count-1])
+	}
+	return result
+}
+
+// A polyline with cumulative lengths, so points can be found by distance travelled (arc-length parameterization).
+// Build one from any curve with NewCurvePath(QuadraticBezier samples, CatmullRomPoints...) to move entities at constant speed
+type CurvePath struct {
+	Points  []Vector2f
+	lengths []float32
+	Closed  bool
+}
+
+func NewCurvePath(_points []Vector2f, _closed bool) CurvePath {
+	path := CurvePath{Points: _points, Closed: _closed}
+	path.lengths = make([]float32, len(_points))
+	for i := 1; i < len(_points); i++ {
+		segment := _points[i].Subtract(_points[i-1])
+		path.lengths[i] = path.lengths[i-1] + segment.Length()
+	}
+	if _closed && len(_points) > 1 {
+		closing := _points[0].Subtract(_points[len(_points)-1])
+		path.lengths = append(path.lengths, path.lengths[len(_points)-1]+closing.Length())
+	}
+	return path
+}
+
+func NewQuadraticBezierPath(_p0, _p1, _p2 Vector2f, _segments int) CurvePath {
+	_segments = MaxInt(_segments, 1)
+	points := make([]Vector2f, _segments+1)
+	for i := range points {
+		points[i] = QuadraticBezier(_p0, _p1, _p2, float32(i)/float32(_segments))
+	}
+	return NewCurvePath(points, false)
+}
+
+func NewCubicBezierPath(_p0, _p1, _p2, _p3 Vector2f, _segments int) CurvePath {
+	_segments = MaxInt(_segments, 1)
+	points := make([]Vector2f, _segments+1)
+	for i := range points {
+		points[i] = CubicBezier(_p0, _p1, _p2, _p3, float32(i)/float32(_segments))
+	}
+	return NewCurvePath(points, false)
+}
+
+func NewCatmullRomPath(_points []Vector2f, _segments int, _closed bool) CurvePath {
+	return NewCurvePath(CatmullRomPoints(_points, _segments, _closed), _closed)
+}
+
+func (path *CurvePath) Length() float32 {
+	if len(path.lengths) == 0 {
+		return 0.0
+	}
+	return path.lengths[len(path.lengths)-1]
+}
+
+func (path *CurvePath) point(_index int) Vector2f {
+	return path.Points[_index%len(path.Points)]
+}
+
+// Index of the segment containing _distance and how far along it, in [0, 1]
+func (path *CurvePath) locate(_distance float32) (int, float32) {
+	total := path.Length()
+	if path.Closed && total > 0.0 && (_distance < 0.0 || _distance > total) {
+		_distance = float32(math.Mod(float64(_distance), float64(total)))
+		if _distance < 0.0 {
+			_distance += total
+		}
+	}
+	_distance = ClampFloat32(_distance, 0.0, total)
+
+	// Binary search for the first cumulative length past _distance
+	low, high := 1, len(path.lengths)-1
+	for low < high {
+		middle := (low + high) / 2
+		if path.lengths[middle] < _distance {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+	segmentLength := path.lengths[low] - path.lengths[low-1]
+	if segmentLength <= 0.0 {
+		return low - 1, 0.0
+	}
+	return low - 1, (_distance - path.lengths[low-1]) / segmentLength
+}
+
+// Point at _distance along the path, wraps around closed paths and clamps open ones
+func (path *CurvePath) PointAtDistance(_distance float32) Vector2f {
+	if len(path.Points) == 0 {
+		return Vector2fZero
+	}
+	if len(path.lengths) < 2 {
+		return path.Points[0]
+	}
+	index, t := path.locate(_distance)
+	return LerpVector2f(path.point(index), path.point(index+1), t)
+}
+
+// Normalized direction of the path at _distance
+func (path *CurvePath) TangentAtDistance(_distance float32) Vector2f {
+	if len(path.lengths) < 2 {
+		return Vector2fZero
+	}
+	index, _ := path.locate(_distance)
+	direction := path.point(index + 1).Subtract(path.point(index))
+	if direction.LengthSquared() == 0.0 {
+		return Vector2fZero
+	}
+	return direction.Normalize()
+}
+
+// Same as PointAtDistance with _t in [0, 1] of the whole length
+func (path *CurvePath) PointAt(_t float32) Vector2f {
+	return path.PointAtDistance(_t * path.Length())
+}
+
+func (path *CurvePath) TangentAt(_t float32) Vector2f {
+	return path.TangentAtDistance(_t * path.Length())
+}
+
+// The part of an open path between two distances, including the path points in between
+func (path *CurvePath) SubPath(_from, _to float32) []Vector2f {
+	if len(path.lengths) < 2 || _to <= _from {
+		return nil
+	}
+	fromIndex, _ := path.locate(_from)
+	toIndex, _ := path.locate(_to)
+	points := []Vector2f{path.PointAtDistance(_from)}
+	for i := fromIndex + 1; i <= toIndex; i++ {
+		points = append(points, path.point(i))
+	}
+	return append(points, path.PointAtDistance(_to))
+}
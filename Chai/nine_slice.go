@@ -0,0 +1,159 @@
+package chai
+
+import "math"
+
+type NineSliceMode uint8
+
+const (
+	// Edges and centre are stretched to fill the size
+	NINE_SLICE_STRETCH NineSliceMode = iota
+	// Edges and centre are repeated at their source size, the last copy is cut
+	NINE_SLICE_TILE
+)
+
+// Widths of the borders in pixels of the source image, the corners are never scaled unevenly
+type NineSliceInsets struct {
+	Left, Right, Top, Bottom int
+}
+
+func NewNineSliceInsets(_left, _right, _top, _bottom int) NineSliceInsets {
+	return NineSliceInsets{Left: _left, Right: _right, Top: _top, Bottom: _bottom}
+}
+
+// Same inset on the four sides
+func NewNineSliceInsetsUniform(_inset int) NineSliceInsets {
+	return NineSliceInsets{_inset, _inset, _inset, _inset}
+}
+
+// Draws _region as a nine-slice of size _dimensions centred on _center. _borderScale is the world size
+// of one source pixel, borders shrink evenly when _dimensions is smaller than them
+func (self *SpriteBatch) DrawNineSlice(_center, _dimensions Vector2f, _region *TextureRegion, _insets NineSliceInsets, _borderScale float32, _mode NineSliceMode, _tint RGBA8, _rotation float32) {
+	source := _region.trimmedSize()
+	// Source pixel bounds of the three columns and the three rows (top to bottom)
+	columns := [4]float32{0.0, float32(_insets.Left), float32(source.X - _insets.Right), float32(source.X)}
+	rows := [4]float32{0.0, float32(_insets.Top), float32(source.Y - _insets.Bottom), float32(source.Y)}
+	if columns[1] > columns[2] || rows[1] > rows[2] {
+		WarningF("[NINE SLICE]: insets %v are larger than the %vx%v image", _insets, source.X, source.Y)
+		return
+	}
+
+	// World bounds of the same columns and rows, from the left and from the top
+	destColumns := nineSliceBounds(columns, _dimensions.X, _borderScale)
+	destRows := nineSliceBounds(rows, _dimensions.Y, _borderScale)
+
+	topLeft := NewVector2f(-_dimensions.X/2.0, _dimensions.Y/2.0)
+	for row := 0; row < 3; row++ {
+		for column := 0; column < 3; column++ {
+			tileX := _mode == NINE_SLICE_TILE && column == 1
+			tileY := _mode == NINE_SLICE_TILE && row == 1
+			self.drawNineSlicePatch(
+				_center, topLeft, _rotation, _region, _tint, _borderScale,
+				[2]float32{destColumns[column], destColumns[column+1]}, [2]float32{destRows[row], destRows[row+1]},
+				[2]float32{columns[column], columns[column+1]}, [2]float32{rows[row], rows[row+1]},
+				tileX, tileY,
+			)
+		}
+	}
+}
+
+func nineSliceBounds(_source [4]float32, _size, _scale float32) [4]float32 {
+	first := (_source[1] - _source[0]) * _scale
+	last := (_source[3] - _source[2]) * _scale
+	if first+last > _size && first+last > 0.0 {
+		shrink := _size / (first + last)
+		first, last = first*shrink, last*shrink
+	}
+	return [4]float32{0.0, first, _size - last, _size}
+}
+
+// One of the nine patches, repeated along the axes that tile. Destination bounds go right and down from _topLeft
+func (self *SpriteBatch) drawNineSlicePatch(_center, _topLeft Vector2f, _rotation float32, _region *TextureRegion, _tint RGBA8, _scale float32, _destX, _destY, _srcX, _srcY [2]float32, _tileX, _tileY bool) {
+	if _destX[1]-_destX[0] <= 0.0 || _destY[1]-_destY[0] <= 0.0 {
+		return
+	}
+	stepX, stepY := _destX[1]-_destX[0], _destY[1]-_destY[0]
+	if _tileX && _srcX[1] > _srcX[0] {
+		stepX = (_srcX[1] - _srcX[0]) * _scale
+	}
+	if _tileY && _srcY[1] > _srcY[0] {
+		stepY = (_srcY[1] - _srcY[0]) * _scale
+	}
+	if stepX <= 0.0 || stepY <= 0.0 {
+		return
+	}
+
+	for y := _destY[0]; y < _destY[1]-1e-5; y += stepY {
+		height := float32(math.Min(float64(stepY), float64(_destY[1]-y)))
+		srcBottom := _srcY[0] + (_srcY[1]-_srcY[0])*height/stepY
+		for x := _destX[0]; x < _destX[1]-1e-5; x += stepX {
+			width := float32(math.Min(float64(stepX), float64(_destX[1]-x)))
+			srcRight := _srcX[0] + (_srcX[1]-_srcX[0])*width/stepX
+
+			left, top := _topLeft.X+x, _topLeft.Y-y
+			corners := [4]Vector2f{
+				NewVector2f(left, top-height), NewVector2f(left, top),
+				NewVector2f(left+width, top), NewVector2f(left+width, top-height),
+			}
+			for i := range corners {
+				corners[i] = _center.Add(corners[i]).Rotate(_rotation, _center)
+			}
+			uvs := [4]Vector2f{
+				_region.pixelUV(_srcX[0], srcBottom), _region.pixelUV(_srcX[0], _srcY[0]),
+				_region.pixelUV(srcRight, _srcY[0]), _region.pixelUV(srcRight, srcBottom),
+			}
+			self.addGlyph(newSpriteGlyphQuad(corners, uvs, _region.Texture, _tint))
+		}
+	}
+}
+
+/* ####### Component ####### */
+
+// Draws a nine-slice filling the entity's Dimensions, centred on its position
+type NineSliceComponent struct {
+	Component
+	Texture Texture2D
+	// Used instead of Texture when set
+	Region *TextureRegion
+	Insets NineSliceInsets
+	// World size of one source pixel of the borders, 1 when zero
+	BorderScale float32
+	Mode        NineSliceMode
+	Tint        RGBA8
+	Material    *Material
+}
+
+func (t *NineSliceComponent) ComponentSet(val interface{}) { *t = val.(NineSliceComponent) }
+
+func NewNineSliceComponent(_texture Texture2D, _insets NineSliceInsets, _borderScale float32) NineSliceComponent {
+	return NineSliceComponent{Texture: _texture, Insets: _insets, BorderScale: _borderScale, Tint: WHITE}
+}
+
+func NewNineSliceComponentRegion(_region *TextureRegion, _insets NineSliceInsets, _borderScale float32) NineSliceComponent {
+	return NineSliceComponent{Region: _region, Insets: _insets, BorderScale: _borderScale, Tint: WHITE}
+}
+
+type NineSliceRenderSystem struct {
+	EcsSystemImpl
+	Sprites *SpriteBatch
+}
+
+func (_render *NineSliceRenderSystem) Update(dt float32) {
+	EachVisibleEntity(NineSliceComponent{}, func(entity *EcsEntity, a interface{}) {
+		nineSlice := a.(NineSliceComponent)
+		region := nineSlice.Region
+		if region == nil {
+			// The component is a copy, point at a copy of the texture that lives as long as this draw
+			texture := nineSlice.Texture
+			fullRegion := NewTextureRegionFromTexture(&texture)
+			region = &fullRegion
+		}
+		scale := nineSlice.BorderScale
+		if scale == 0.0 {
+			scale = 1.0
+		}
+		previousMaterial := _render.Sprites.GetMaterial()
+		_render.Sprites.SetMaterial(nineSlice.Material)
+		_render.Sprites.DrawNineSlice(entity.Pos, entity.Dimensions, region, nineSlice.Insets, scale, nineSlice.Mode, nineSlice.Tint, entity.Rot)
+		_render.Sprites.SetMaterial(previousMaterial)
+	})
+}
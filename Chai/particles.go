@@ -0,0 +1,400 @@
+package chai
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+/* ####### Ranges & Curves ####### */
+
+// A value picked uniformly between Min and Max for every particle
+type FloatRange struct {
+	Min, Max float32
+}
+
+func NewFloatRange(_min, _max float32) FloatRange {
+	return FloatRange{Min: _min, Max: _max}
+}
+
+func (r FloatRange) Random() float32 {
+	return r.Min + (r.Max-r.Min)*rand.Float32()
+}
+
+type FloatKey struct {
+	Time  float32
+	Value float32
+}
+
+// Piecewise linear curve over [0, 1], an empty curve evaluates to 1
+type FloatCurve struct {
+	Keys []FloatKey
+}
+
+func NewFloatCurve(_keys ...FloatKey) FloatCurve {
+	return FloatCurve{Keys: _keys}
+}
+
+func (c *FloatCurve) Evaluate(_t float32) float32 {
+	if len(c.Keys) == 0 {
+		return 1.0
+	}
+	if _t <= c.Keys[0].Time {
+		return c.Keys[0].Value
+	}
+	for i := 1; i < len(c.Keys); i++ {
+		if _t <= c.Keys[i].Time {
+			previous := c.Keys[i-1]
+			span := c.Keys[i].Time - previous.Time
+			if span <= 0.0 {
+				return c.Keys[i].Value
+			}
+			return LerpFloat32(previous.Value, c.Keys[i].Value, (_t-previous.Time)/span)
+		}
+	}
+	return c.Keys[len(c.Keys)-1].Value
+}
+
+type ColorKey struct {
+	Time  float32
+	Color RGBA8
+}
+
+// Piecewise linear colors over [0, 1], an empty gradient evaluates to WHITE
+type ColorGradient struct {
+	Keys []ColorKey
+}
+
+func NewColorGradient(_keys ...ColorKey) ColorGradient {
+	return ColorGradient{Keys: _keys}
+}
+
+func (g *ColorGradient) Evaluate(_t float32) RGBA8 {
+	if len(g.Keys) == 0 {
+		return WHITE
+	}
+	if _t <= g.Keys[0].Time {
+		return g.Keys[0].Color
+	}
+	for i := 1; i < len(g.Keys); i++ {
+		if _t <= g.Keys[i].Time {
+			previous := g.Keys[i-1]
+			span := g.Keys[i].Time - previous.Time
+			if span <= 0.0 {
+				return g.Keys[i].Color
+			}
+			return lerpRGBA8(previous.Color, g.Keys[i].Color, (_t-previous.Time)/span)
+		}
+	}
+	return g.Keys[len(g.Keys)-1].Color
+}
+
+func lerpRGBA8(_a, _b RGBA8, _t float32) RGBA8 {
+	channel := func(a, b uint8) uint8 {
+		return uint8(LerpFloat32(float32(a), float32(b), _t) + 0.5)
+	}
+	return RGBA8{channel(_a.r, _b.r), channel(_a.g, _b.g), channel(_a.b, _b.b), channel(_a.a, _b.a)}
+}
+
+/* ####### Emitter ####### */
+
+type ParticleShape uint8
+
+const (
+	PARTICLE_SHAPE_SQUARE ParticleShape = iota
+	PARTICLE_SHAPE_CIRCLE
+)
+
+// Emits Count particles when the emitter time reaches Time
+type ParticleBurst struct {
+	Time  float32
+	Count int
+}
+
+type particle struct {
+	position        Vector2f
+	velocity        Vector2f
+	rotation        float32
+	angularVelocity float32
+	size            float32
+	age, lifetime   float32
+}
+
+// Runtime state of one emitter. Allocated by the first update of each entity and written back with the
+// component, so entities made from the same component value never share a pool
+type particleEmitterState struct {
+	particles       []particle
+	alive           int
+	time            float32
+	emitAccumulator float32
+	nextBurst       int
+	pendingEmits    int
+}
+
+type ParticleEmitterComponent struct {
+	Component
+	Emitting bool
+	// Particles per second
+	Rate   float32
+	Bursts []ParticleBurst
+	// Length in seconds of one emission cycle, bursts are timed inside it
+	Duration     float32
+	Loop         bool
+	MaxParticles int
+
+	Lifetime FloatRange
+	Speed    FloatRange
+	// Direction in degrees, relative to the entity rotation
+	Angle FloatRange
+	// Particles spawn anywhere inside this radius around the entity
+	EmissionRadius  float32
+	Rotation        FloatRange
+	AngularVelocity FloatRange
+	Gravity         Vector2f
+	// Fraction of the velocity lost per second
+	Drag float32
+
+	StartSize FloatRange
+	// Multiplies StartSize over the lifetime of a particle
+	SizeOverLifetime  FloatCurve
+	ColorOverLifetime ColorGradient
+
+	// Drawn when set, otherwise the particles are drawn as Shape
+	Texture *Texture2D
+	Shape   ParticleShape
+	// Local space particles move with the entity, world space ones stay where they were emitted
+	LocalSpace bool
+	Offset     Vector2f
+
+	state *particleEmitterState
+}
+
+func (t *ParticleEmitterComponent) ComponentSet(val interface{}) { *t = val.(ParticleEmitterComponent) }
+
+func NewParticleEmitterComponent(_maxParticles int) ParticleEmitterComponent {
+	return ParticleEmitterComponent{
+		Emitting:     true,
+		Rate:         20.0,
+		Duration:     1.0,
+		Loop:         true,
+		MaxParticles: _maxParticles,
+		Lifetime:     NewFloatRange(1.0, 1.0),
+		Speed:        NewFloatRange(50.0, 100.0),
+		Angle:        NewFloatRange(0.0, 360.0),
+		StartSize:    NewFloatRange(8.0, 8.0),
+		Shape:        PARTICLE_SHAPE_CIRCLE,
+	}
+}
+
+// Queues _count particles to be emitted on the next update, e.g. on a collision
+func (t *ParticleEmitterComponent) Emit(_count int) {
+	if t.state == nil {
+		t.state = &particleEmitterState{}
+	}
+	t.state.pendingEmits += _count
+}
+
+func (t *ParticleEmitterComponent) GetAliveParticles() int {
+	if t.state == nil {
+		return 0
+	}
+	return t.state.alive
+}
+
+// Restarts the emission cycle and removes the particles alive
+func (t *ParticleEmitterComponent) Reset() {
+	if t.state == nil {
+		return
+	}
+	*t.state = particleEmitterState{particles: t.state.particles}
+}
+
+func (t *ParticleEmitterComponent) spawn(_entity *EcsEntity, _count int) {
+	state := t.state
+	for i := 0; i < _count && state.alive < t.MaxParticles; i++ {
+		angle := Deg2Rad(t.Angle.Random() + _entity.Rot)
+		direction := NewVector2f(float32(math.Cos(float64(angle))), float32(math.Sin(float64(angle))))
+
+		position := t.Offset.RotateCenter(_entity.Rot)
+		if t.EmissionRadius > 0.0 {
+			spawnAngle := rand.Float32() * 2.0 * PI
+			// sqrt keeps the distribution uniform over the disc
+			distance := t.EmissionRadius * float32(math.Sqrt(float64(rand.Float32())))
+			position = position.AddXY(float32(math.Cos(float64(spawnAngle)))*distance, float32(math.Sin(float64(spawnAngle)))*distance)
+		}
+		velocity := direction.Scale(t.Speed.Random())
+		if t.LocalSpace {
+			// Stored relative to the entity, unrotated
+			position = position.RotateCenter(-_entity.Rot)
+			velocity = velocity.RotateCenter(-_entity.Rot)
+		} else {
+			position = position.Add(_entity.Pos)
+		}
+
+		state.particles[state.alive] = particle{
+			position:        position,
+			velocity:        velocity,
+			rotation:        t.Rotation.Random(),
+			angularVelocity: t.AngularVelocity.Random(),
+			size:            t.StartSize.Random(),
+			lifetime:        MaxFloat32(t.Lifetime.Random(), 0.0001),
+		}
+		state.alive++
+	}
+}
+
+func (t *ParticleEmitterComponent) update(_entity *EcsEntity, _dt float32) {
+	if t.state == nil {
+		t.state = &particleEmitterState{}
+	}
+	state := t.state
+	maxParticles := MaxInt(t.MaxParticles, 0)
+	if len(state.particles) != maxParticles {
+		particles := make([]particle, maxParticles)
+		state.alive = copy(particles, state.particles[:state.alive])
+		state.particles = particles
+	}
+
+	if t.Emitting {
+		previousTime := state.time
+		state.time += _dt
+		if t.Loop && t.Duration > 0.0 && state.time >= t.Duration {
+			// Fire the bursts left in this cycle before wrapping
+			t.spawnBursts(_entity, previousTime, t.Duration)
+			state.time = float32(math.Mod(float64(state.time), float64(t.Duration)))
+			state.nextBurst = 0
+			previousTime = 0.0
+		}
+		t.spawnBursts(_entity, previousTime, state.time)
+
+		if !t.Loop && t.Duration > 0.0 && state.time >= t.Duration {
+			t.Emitting = false
+		} else {
+			state.emitAccumulator += t.Rate * _dt
+			count := int(state.emitAccumulator)
+			state.emitAccumulator -= float32(count)
+			t.spawn(_entity, count)
+		}
+	}
+	if state.pendingEmits > 0 {
+		t.spawn(_entity, state.pendingEmits)
+		state.pendingEmits = 0
+	}
+
+	drag := float32(math.Max(0.0, 1.0-float64(t.Drag*_dt)))
+	gravity := t.Gravity
+	if t.LocalSpace {
+		gravity = gravity.RotateCenter(-_entity.Rot)
+	}
+	for i := 0; i < state.alive; {
+		p := &state.particles[i]
+		p.age += _dt
+		if p.age >= p.lifetime {
+			// Swap with the last alive particle, the pool never reallocates
+			state.alive--
+			state.particles[i] = state.particles[state.alive]
+			continue
+		}
+		p.velocity = p.velocity.Add(gravity.Scale(_dt)).Scale(drag)
+		p.position = p.position.Add(p.velocity.Scale(_dt))
+		p.rotation += p.angularVelocity * _dt
+		i++
+	}
+}
+
+func (t *ParticleEmitterComponent) spawnBursts(_entity *EcsEntity, _from, _to float32) {
+	state := t.state
+	for state.nextBurst < len(t.Bursts) {
+		burst := t.Bursts[state.nextBurst]
+		if burst.Time > _to {
+			return
+		}
+		if burst.Time >= _from {
+			t.spawn(_entity, burst.Count)
+		}
+		state.nextBurst++
+	}
+}
+
+/* ####### Systems ####### */
+
+// Simulates every ParticleEmitterComponent, add it as an update system
+type ParticleUpdateSystem struct {
+	EcsSystemImpl
+}
+
+func (_sys *ParticleUpdateSystem) Update(dt float32) {
+	EachEntity(ParticleEmitterComponent{}, func(entity *EcsEntity, a interface{}) {
+		emitter := a.(ParticleEmitterComponent)
+		hadState := emitter.state != nil
+		emitting := emitter.Emitting
+		emitter.update(entity, dt)
+		if !hadState || emitting != emitter.Emitting {
+			WriteComponent(_sys.GetEcsEngine(), entity, emitter)
+		}
+	})
+}
+
+// Draws the particles, use &Particles for additive glows or &Sprites for alpha blended smoke
+type ParticleRenderSystem struct {
+	EcsSystemImpl
+	Sprites *SpriteBatch
+}
+
+func (_render *ParticleRenderSystem) Update(dt float32) {
+	EachEntity(ParticleEmitterComponent{}, func(entity *EcsEntity, a interface{}) {
+		if !IsRenderLayerVisible(entity.RenderLayer) {
+			return
+		}
+		emitter := a.(ParticleEmitterComponent)
+		if emitter.state == nil {
+			return
+		}
+
+		texture := emitter.Texture
+		if texture == nil {
+			texture = getParticleShapeTexture(emitter.Shape)
+		}
+		uv1, uv2 := NewVector2f(0.0, 0.0), NewVector2f(1.0, 1.0)
+		for i := 0; i < emitter.state.alive; i++ {
+			p := &emitter.state.particles[i]
+			t := p.age / p.lifetime
+			position, rotation := p.position, p.rotation
+			if emitter.LocalSpace {
+				position = position.RotateCenter(entity.Rot).Add(entity.Pos)
+				rotation += entity.Rot
+			}
+			size := p.size * emitter.SizeOverLifetime.Evaluate(t)
+			_render.Sprites.addGlyph(NewSpriteGlyphRotated(position, NewVector2f(size, size), uv1, uv2, texture, emitter.ColorOverLifetime.Evaluate(t), rotation))
+		}
+	})
+}
+
+// White textures generated on first use for the shape particles
+var particleShapeTextures [2]*Texture2D
+
+const particleShapeTextureSize = 32
+
+func getParticleShapeTexture(_shape ParticleShape) *Texture2D {
+	if particleShapeTextures[_shape] != nil {
+		return particleShapeTextures[_shape]
+	}
+	img := image.NewRGBA(image.Rect(0, 0, particleShapeTextureSize, particleShapeTextureSize))
+	half := float64(particleShapeTextureSize) / 2.0
+	for y := 0; y < particleShapeTextureSize; y++ {
+		for x := 0; x < particleShapeTextureSize; x++ {
+			alpha := 1.0
+			if _shape == PARTICLE_SHAPE_CIRCLE {
+				distance := math.Hypot(float64(x)+0.5-half, float64(y)+0.5-half)
+				// One pixel of falloff keeps the edge smooth
+				alpha = math.Max(0.0, math.Min(1.0, half-distance))
+			}
+			// LoadTextureFromImg uploads the stored values as they are, so the color stays white at any alpha
+			img.SetRGBA(x, y, color.RGBA{255, 255, 255, uint8(alpha * 255)})
+		}
+	}
+	texture := LoadTextureFromImg(img)
+	particleShapeTextures[_shape] = &texture
+	return &texture
+}
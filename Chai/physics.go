@@ -11,6 +11,8 @@ type ColliderShape uint8
 
 const Shape_CircleCollider ColliderShape = 0
 const Shape_RectCollider ColliderShape = 1
+const Shape_PolygonCollider ColliderShape = 2
+const Shape_ChainCollider ColliderShape = 3
 
 func BoxVector2f(v Vector2f) box2d.B2Vec2 {
 	return box2d.MakeB2Vec2(float64(v.X), float64(v.Y))
@@ -277,6 +279,92 @@ func NewTriggerArea(ent *EcsEntity, colliderShape ColliderShape, bodySize Vector
 	return staticComp
 }
 
+// A static body shaped like a simple polygon, convex or concave, with _points relative to the entity.
+// Concave polygons are split into triangles
+func NewStaticPolygonBody(ent *EcsEntity, _points []Vector2f, friction float32, phy_world *PhysicsWorld) StaticBodyComponent {
+	fixtures := make([]box2d.B2FixtureDef, 0)
+	indices := TriangulatePolygon(_points)
+	for i := 0; i+2 < len(indices); i += 3 {
+		a, b, c := _points[indices[i]], _points[indices[i+1]], _points[indices[i+2]]
+		// Box2D rejects degenerate polygons
+		if AbsFloat32(CrossProduct(b.Subtract(a), c.Subtract(a))) < 1e-4 {
+			continue
+		}
+		shape := box2d.MakeB2PolygonShape()
+		shape.Set([]box2d.B2Vec2{BoxVector2f(a), BoxVector2f(b), BoxVector2f(c)}, 3)
+		fd := box2d.MakeB2FixtureDef()
+		fd.Shape = &shape
+		fd.Friction = float64(friction)
+		fixtures = append(fixtures, fd)
+	}
+	return StaticBodyComponent{
+		Active:   true,
+		phy_body: newStaticPhysicsBody(Shape_PolygonCollider, ent, fixtures, phy_world),
+	}
+}
+
+// A static body made of connected edges, e.g. terrain outlines. _loop connects the last point to the first
+func NewStaticChainBody(ent *EcsEntity, _points []Vector2f, _loop bool, friction float32, phy_world *PhysicsWorld) StaticBodyComponent {
+	vertices := make([]box2d.B2Vec2, 0, len(_points))
+	for i, point := range _points {
+		if i > 0 && point.NearlyEqual(_points[i-1]) {
+			continue
+		}
+		vertices = append(vertices, BoxVector2f(point))
+	}
+	if _loop && len(vertices) > 1 && Vector2fFromBoxVec(vertices[0]).NearlyEqual(Vector2fFromBoxVec(vertices[len(vertices)-1])) {
+		vertices = vertices[:len(vertices)-1]
+	}
+
+	fixtures := make([]box2d.B2FixtureDef, 0, 1)
+	if len(vertices) >= 2 {
+		shape := box2d.MakeB2ChainShape()
+		if _loop && len(vertices) >= 3 {
+			shape.CreateLoop(vertices, len(vertices))
+		} else {
+			shape.CreateChain(vertices, len(vertices))
+		}
+		fd := box2d.MakeB2FixtureDef()
+		fd.Shape = &shape
+		fd.Friction = float64(friction)
+		fixtures = append(fixtures, fd)
+	}
+	return StaticBodyComponent{
+		Active:   true,
+		phy_body: newStaticPhysicsBody(Shape_ChainCollider, ent, fixtures, phy_world),
+	}
+}
+
+func newStaticPhysicsBody(colliderShape ColliderShape, ent *EcsEntity, fixtures []box2d.B2FixtureDef, phy_world *PhysicsWorld) *PhysicsBody {
+	bodyDef := box2d.MakeB2BodyDef()
+	bodyDef.Position = BoxVector2f(ent.Pos)
+	bodyDef.Type = box2d.B2BodyType.B2_staticBody
+	bodyDef.AllowSleep = false
+	bodyDef.Angle = float64(ent.Rot * PI / 180.0)
+	body := phy_world.box2dWorld.CreateBody(&bodyDef)
+
+	phyBody := &PhysicsBody{
+		BodyType:      Type_BodyStatic,
+		ColliderShape: colliderShape,
+		body:          body,
+		OwnerEntity:   ent,
+		Debug_Tint:    WHITE,
+	}
+	for i := range fixtures {
+		fixture := body.CreateFixtureFromDef(&fixtures[i])
+		if phyBody.fixture == nil {
+			phyBody.fixture = fixture
+		}
+	}
+	if phyBody.fixture == nil {
+		WarningF("[PHYSICS]: static body created without any valid shape")
+	}
+	phyBody.OnCollisionStart.init()
+	phyBody.OnCollisionEnd.init()
+	body.SetUserData(phyBody)
+	return phyBody
+}
+
 type BoxCastQueryCallback struct {
 	FoundBodies []*box2d.B2Body
 }
@@ -0,0 +1,386 @@
+package chai
+
+import (
+	"syscall/js"
+)
+
+const POST_PROCESS_SHADER_VERTEX = `#version 300 es
+
+precision mediump float;
+
+layout(location = 0) in vec2 coordinates;
+layout(location = 2) in vec2 uv;
+
+out vec2 vertex_UV;
+
+void main(void) {
+	gl_Position = vec4(coordinates, 0.0, 1.0);
+	vertex_UV = uv;
+}
+`
+
+// Every pass fragment shader receives these uniforms and writes to fragColor
+const postProcessFragmentHeader = `#version 300 es
+
+precision mediump float;
+
+in vec2 vertex_UV;
+
+uniform sampler2D screenTexture;
+uniform vec2 resolution;
+uniform float time;
+
+out vec4 fragColor;
+`
+
+// A full-screen shader pass, it samples the output of the previous pass through "screenTexture"
+type PostProcessPass interface {
+	fragmentSource() string
+	setUniforms(_shader *ShaderProgram)
+}
+
+// Passes that share the same fragment source share the same compiled program
+var postProcessPrograms map[string]*ShaderProgram
+
+func getPostProcessProgram(_pass PostProcessPass) *ShaderProgram {
+	if postProcessPrograms == nil {
+		postProcessPrograms = make(map[string]*ShaderProgram)
+	}
+	source := _pass.fragmentSource()
+	program, ok := postProcessPrograms[source]
+	if !ok {
+		program = &ShaderProgram{}
+		program.ParseShader(POST_PROCESS_SHADER_VERTEX, postProcessFragmentHeader+source)
+		program.CreateShaderProgram()
+		postProcessPrograms[source] = program
+	}
+	return program
+}
+
+var fullscreenQuadVao js.Value
+var fullscreenQuadVbo js.Value
+
+func drawFullscreenQuad() {
+	if fullscreenQuadVao.IsUndefined() || fullscreenQuadVao.IsNull() {
+		quad := []Vertex{
+			NewVertex(NewVector2f(-1.0, -1.0), NewVector2f(0.0, 0.0), WHITE),
+			NewVertex(NewVector2f(1.0, -1.0), NewVector2f(1.0, 0.0), WHITE),
+			NewVertex(NewVector2f(-1.0, 1.0), NewVector2f(0.0, 1.0), WHITE),
+			NewVertex(NewVector2f(1.0, 1.0), NewVector2f(1.0, 1.0), WHITE),
+		}
+		fullscreenQuadVao = canvasContext.Call("createVertexArray")
+		canvasContext.Call("bindVertexArray", fullscreenQuadVao)
+		fullscreenQuadVbo = canvasContext.Call("createBuffer")
+		canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), fullscreenQuadVbo)
+		canvasContext.Call("bufferData", canvasContext.Get("ARRAY_BUFFER"), vertexBufferToJsVertexBuffer(quad), canvasContext.Get("STATIC_DRAW"))
+		canvasContext.Call("enableVertexAttribArray", 0)
+		canvasContext.Call("enableVertexAttribArray", 2)
+		canvasContext.Call("vertexAttribPointer", 0, 2, canvasContext.Get("FLOAT"), false, VertexSize, 0)
+		canvasContext.Call("vertexAttribPointer", 2, 2, canvasContext.Get("FLOAT"), false, VertexSize, 12)
+		canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), js.Null())
+	} else {
+		canvasContext.Call("bindVertexArray", fullscreenQuadVao)
+	}
+
+	canvasContext.Call("drawArrays", canvasContext.Get("TRIANGLE_STRIP"), 0, 4)
+	canvasContext.Call("bindVertexArray", js.Null())
+	renderStats.DrawCalls++
+	renderStats.Vertices += 4
+}
+
+// Draws _texture over the whole currently bound framebuffer with the shader of _pass
+func applyPostProcessPass(_pass PostProcessPass, _texture *Texture2D, _width, _height int) {
+	program := getPostProcessProgram(_pass)
+	UseShader(program)
+
+	canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0"))
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), _texture.glTexture())
+	program.SetUniformSampler("screenTexture", 0)
+	program.SetUniformVec2("resolution", NewVector2f(float32(_width), float32(_height)))
+	program.SetUniformFloat("time", ElapsedTime)
+	_pass.setUniforms(program)
+
+	drawFullscreenQuad()
+	UnuseShader()
+}
+
+// An ordered chain of passes, the scene is rendered into an offscreen target and every pass feeds the next one,
+// the last pass draws to whatever was bound before Begin (normally the canvas)
+type PostProcessStack struct {
+	Passes  []PostProcessPass
+	targets [2]RenderTarget
+	created bool
+}
+
+func (stack *PostProcessStack) AddPass(_pass PostProcessPass) {
+	stack.Passes = append(stack.Passes, _pass)
+}
+
+func (stack *PostProcessStack) RemovePass(_pass PostProcessPass) {
+	for i, p := range stack.Passes {
+		if p == _pass {
+			stack.Passes = append(stack.Passes[:i], stack.Passes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (stack *PostProcessStack) IsActive() bool {
+	return len(stack.Passes) > 0
+}
+
+func (stack *PostProcessStack) ensureTargets(_width, _height int) {
+	if !stack.created {
+		stack.targets[0] = NewRenderTarget(_width, _height, true)
+		stack.targets[1] = NewRenderTarget(_width, _height, true)
+		stack.created = true
+		return
+	}
+	for i := range stack.targets {
+		if stack.targets[i].Width != _width || stack.targets[i].Height != _height {
+			stack.targets[i].Resize(_width, _height)
+		}
+	}
+}
+
+// Redirects the rendering of the frame into the stack's first target
+func (stack *PostProcessStack) Begin(_width, _height int) {
+	stack.ensureTargets(_width, _height)
+	stack.targets[0].Bind()
+}
+
+// Runs the passes and writes the result to the framebuffer that was bound before Begin
+func (stack *PostProcessStack) End() {
+	stack.targets[0].Unbind()
+
+	canvasContext.Call("disable", canvasContext.Get("BLEND"))
+	source := 0
+	for i, pass := range stack.Passes {
+		last := i == len(stack.Passes)-1
+		if !last {
+			stack.targets[1-source].Bind()
+		}
+		applyPostProcessPass(pass, stack.targets[source].GetTexture(), stack.targets[source].Width, stack.targets[source].Height)
+		if !last {
+			stack.targets[1-source].Unbind()
+			source = 1 - source
+		}
+	}
+	canvasContext.Call("enable", canvasContext.Get("BLEND"))
+}
+
+func (stack *PostProcessStack) Delete() {
+	if !stack.created {
+		return
+	}
+	stack.targets[0].Delete()
+	stack.targets[1].Delete()
+	stack.created = false
+}
+
+/*
+##############################################################
+############ Built-in Passes - Built-in Passes ###############
+##############################################################
+*/
+
+// Adds a blurred copy of the pixels brighter than Threshold on top of the image
+type BloomPass struct {
+	Threshold float32
+	Intensity float32
+	// Distance in pixels between the blur samples
+	Radius float32
+}
+
+func NewBloomPass() *BloomPass {
+	return &BloomPass{Threshold: 0.7, Intensity: 1.0, Radius: 2.0}
+}
+
+func (p *BloomPass) fragmentSource() string {
+	return `
+uniform float threshold;
+uniform float intensity;
+uniform float radius;
+
+vec3 brightPart(vec2 _uv) {
+	vec3 color = texture(screenTexture, _uv).rgb;
+	float luminance = dot(color, vec3(0.2126, 0.7152, 0.0722));
+	return color * smoothstep(threshold, threshold + 0.1, luminance);
+}
+
+void main(void) {
+	vec4 base = texture(screenTexture, vertex_UV);
+	vec3 bloom = vec3(0.0);
+	float total = 0.0;
+	for (int x = -4; x <= 4; x++) {
+		for (int y = -4; y <= 4; y++) {
+			float weight = exp(-float(x * x + y * y) / 8.0);
+			bloom += brightPart(vertex_UV + vec2(float(x), float(y)) * radius / resolution) * weight;
+			total += weight;
+		}
+	}
+	fragColor = vec4(base.rgb + bloom / total * intensity, base.a);
+}`
+}
+
+func (p *BloomPass) setUniforms(_shader *ShaderProgram) {
+	_shader.SetUniformFloat("threshold", p.Threshold)
+	_shader.SetUniformFloat("intensity", p.Intensity)
+	_shader.SetUniformFloat("radius", p.Radius)
+}
+
+// Darkens the image towards Color the further a pixel is from the centre
+type VignettePass struct {
+	// Distance from the centre (0.5 reaches the edges) where the darkening starts
+	Radius   float32
+	Softness float32
+	Strength float32
+	Color    RGBA8
+}
+
+func NewVignettePass() *VignettePass {
+	return &VignettePass{Radius: 0.75, Softness: 0.45, Strength: 1.0, Color: NewRGBA8(0, 0, 0, 255)}
+}
+
+func (p *VignettePass) fragmentSource() string {
+	return `
+uniform float radius;
+uniform float softness;
+uniform float strength;
+uniform vec3 vignetteColor;
+
+void main(void) {
+	vec4 base = texture(screenTexture, vertex_UV);
+	float dist = distance(vertex_UV, vec2(0.5));
+	float vignette = smoothstep(radius, radius - softness, dist);
+	fragColor = vec4(mix(vignetteColor, base.rgb, mix(1.0, vignette, strength)), base.a);
+}`
+}
+
+func (p *VignettePass) setUniforms(_shader *ShaderProgram) {
+	_shader.SetUniformFloat("radius", p.Radius)
+	_shader.SetUniformFloat("softness", p.Softness)
+	_shader.SetUniformFloat("strength", p.Strength)
+	_shader.SetUniformVec3("vignetteColor", p.Color.GetColorRFloat32(), p.Color.GetColorGFloat32(), p.Color.GetColorBFloat32())
+}
+
+// Old monitor look: screen curvature, scanlines and color fringes
+type CRTPass struct {
+	Curvature           float32
+	ScanlineIntensity   float32
+	ScanlineCount       float32
+	ChromaticAberration float32
+}
+
+func NewCRTPass() *CRTPass {
+	return &CRTPass{Curvature: 0.1, ScanlineIntensity: 0.25, ScanlineCount: 300.0, ChromaticAberration: 1.5}
+}
+
+func (p *CRTPass) fragmentSource() string {
+	return `
+uniform float curvature;
+uniform float scanlineIntensity;
+uniform float scanlineCount;
+uniform float chromaticAberration;
+
+void main(void) {
+	vec2 centered = vertex_UV * 2.0 - 1.0;
+	centered *= 1.0 + curvature * dot(centered.yx, centered.yx) * 0.25;
+	vec2 uv = centered * 0.5 + 0.5;
+	if (uv.x < 0.0 || uv.x > 1.0 || uv.y < 0.0 || uv.y > 1.0) {
+		fragColor = vec4(0.0, 0.0, 0.0, 1.0);
+		return;
+	}
+
+	vec2 offset = vec2(chromaticAberration / resolution.x, 0.0);
+	vec4 base = texture(screenTexture, uv);
+	float r = texture(screenTexture, uv + offset).r;
+	float b = texture(screenTexture, uv - offset).b;
+	float scanline = 1.0 - scanlineIntensity * (0.5 + 0.5 * sin(uv.y * scanlineCount * 6.2831853));
+	fragColor = vec4(vec3(r, base.g, b) * scanline, base.a);
+}`
+}
+
+func (p *CRTPass) setUniforms(_shader *ShaderProgram) {
+	_shader.SetUniformFloat("curvature", p.Curvature)
+	_shader.SetUniformFloat("scanlineIntensity", p.ScanlineIntensity)
+	_shader.SetUniformFloat("scanlineCount", p.ScanlineCount)
+	_shader.SetUniformFloat("chromaticAberration", p.ChromaticAberration)
+}
+
+// Brightness is added, Contrast and Saturation are factors where 1 leaves the image unchanged
+type ColorGradingPass struct {
+	Brightness float32
+	Contrast   float32
+	Saturation float32
+	Tint       RGBA8
+}
+
+func NewColorGradingPass() *ColorGradingPass {
+	return &ColorGradingPass{Brightness: 0.0, Contrast: 1.0, Saturation: 1.0, Tint: WHITE}
+}
+
+func (p *ColorGradingPass) fragmentSource() string {
+	return `
+uniform float brightness;
+uniform float contrast;
+uniform float saturation;
+uniform vec3 tint;
+
+void main(void) {
+	vec4 base = texture(screenTexture, vertex_UV);
+	vec3 color = base.rgb + brightness;
+	color = (color - 0.5) * contrast + 0.5;
+	float luminance = dot(color, vec3(0.2126, 0.7152, 0.0722));
+	color = mix(vec3(luminance), color, saturation);
+	fragColor = vec4(clamp(color * tint, 0.0, 1.0), base.a);
+}`
+}
+
+func (p *ColorGradingPass) setUniforms(_shader *ShaderProgram) {
+	_shader.SetUniformFloat("brightness", p.Brightness)
+	_shader.SetUniformFloat("contrast", p.Contrast)
+	_shader.SetUniformFloat("saturation", p.Saturation)
+	_shader.SetUniformVec3("tint", p.Tint.GetColorRFloat32(), p.Tint.GetColorGFloat32(), p.Tint.GetColorBFloat32())
+}
+
+// Snaps the image to blocks of PixelSize screen pixels
+type PixelationPass struct {
+	PixelSize float32
+}
+
+func NewPixelationPass(_pixelSize float32) *PixelationPass {
+	return &PixelationPass{PixelSize: _pixelSize}
+}
+
+func (p *PixelationPass) fragmentSource() string {
+	return `
+uniform float pixelSize;
+
+void main(void) {
+	vec2 blocks = resolution / max(pixelSize, 1.0);
+	vec2 uv = (floor(vertex_UV * blocks) + 0.5) / blocks;
+	fragColor = texture(screenTexture, uv);
+}`
+}
+
+func (p *PixelationPass) setUniforms(_shader *ShaderProgram) {
+	_shader.SetUniformFloat("pixelSize", p.PixelSize)
+}
+
+// A pass with a user written fragment body, it gets the same inputs as the built-in passes
+// (vertex_UV, screenTexture, resolution, time) and must write fragColor
+type CustomPass struct {
+	FragmentSource string
+	SetUniforms    func(_shader *ShaderProgram)
+}
+
+func (p *CustomPass) fragmentSource() string {
+	return p.FragmentSource
+}
+
+func (p *CustomPass) setUniforms(_shader *ShaderProgram) {
+	if p.SetUniforms != nil {
+		p.SetUniforms(_shader)
+	}
+}
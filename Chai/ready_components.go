@@ -244,6 +244,9 @@ type SpriteAnimationSystem struct {
 
 func (sa *SpriteAnimationSystem) Update(dt float32) {
 	EachEntity(SpriteAnimation{}, func(entity *EcsEntity, a interface{}) {
+		if !IsRenderLayerVisible(entity.RenderLayer) {
+			return
+		}
 		spAnim := a.(SpriteAnimation)
 		anim := AnimationComponent[Vector2i]{}
 		ReadComponent(sa.GetEcsEngine(), entity, &anim)
@@ -0,0 +1,105 @@
+package chai
+
+// Packs rectangles into a fixed area using the skyline bottom-left heuristic, shared by the texture
+// atlases and the font glyph pages. Positions are in pixels from the top-left
+type RectPacker struct {
+	width, height int
+	// Empty pixels kept around every rectangle, avoids bleeding when the texture is filtered
+	padding int
+	skyline []skylineNode
+}
+
+// A horizontal segment of the skyline, everything below y is taken
+type skylineNode struct {
+	x, y, width int
+}
+
+func NewRectPacker(_width, _height, _padding int) *RectPacker {
+	packer := &RectPacker{width: _width, height: _height, padding: _padding}
+	packer.Reset()
+	return packer
+}
+
+func (p *RectPacker) Reset() {
+	p.skyline = append(p.skyline[:0], skylineNode{x: p.padding, y: p.padding, width: p.width - p.padding})
+}
+
+func (p *RectPacker) GetSize() Vector2i {
+	return NewVector2i(p.width, p.height)
+}
+
+// Reserves a _width x _height rectangle and returns its top-left corner, false when there is no room left
+func (p *RectPacker) Pack(_width, _height int) (Vector2i, bool) {
+	width, height := _width+p.padding, _height+p.padding
+	bestIndex, bestBottom, bestWidth, bestY := -1, p.height+1, p.width+1, 0
+	for i := range p.skyline {
+		y, ok := p.fit(i, width, height)
+		if !ok {
+			continue
+		}
+		// Lowest bottom edge first, the narrowest segment breaks ties to keep the skyline flat
+		if y+height < bestBottom || (y+height == bestBottom && p.skyline[i].width < bestWidth) {
+			bestIndex, bestBottom, bestWidth, bestY = i, y+height, p.skyline[i].width, y
+		}
+	}
+	if bestIndex < 0 {
+		return Vector2i{}, false
+	}
+
+	position := NewVector2i(p.skyline[bestIndex].x, bestY)
+	p.insert(bestIndex, skylineNode{x: position.X, y: bestY + height, width: width})
+	return position, true
+}
+
+// The y at which a rectangle starting on node _index rests, false if it leaves the area
+func (p *RectPacker) fit(_index, _width, _height int) (int, bool) {
+	x := p.skyline[_index].x
+	if x+_width > p.width {
+		return 0, false
+	}
+	y := 0
+	remaining := _width
+	for i := _index; remaining > 0; i++ {
+		if i >= len(p.skyline) {
+			return 0, false
+		}
+		y = MaxInt(y, p.skyline[i].y)
+		if y+_height > p.height {
+			return 0, false
+		}
+		remaining -= p.skyline[i].width
+	}
+	return y, true
+}
+
+func (p *RectPacker) insert(_index int, _node skylineNode) {
+	p.skyline = append(p.skyline, skylineNode{})
+	copy(p.skyline[_index+1:], p.skyline[_index:])
+	p.skyline[_index] = _node
+
+	// Shrink or remove the nodes now covered by the new one
+	right := _node.x + _node.width
+	for i := _index + 1; i < len(p.skyline); {
+		node := &p.skyline[i]
+		if node.x >= right {
+			break
+		}
+		shrink := right - node.x
+		if shrink < node.width {
+			node.x += shrink
+			node.width -= shrink
+			break
+		}
+		p.skyline = append(p.skyline[:i], p.skyline[i+1:]...)
+	}
+
+	// Merge neighbours at the same height
+	for i := 0; i+1 < len(p.skyline); {
+		if p.skyline[i].y == p.skyline[i+1].y {
+			p.skyline[i].width += p.skyline[i+1].width
+			p.skyline = append(p.skyline[:i+1], p.skyline[i+2:]...)
+			continue
+		}
+		i++
+	}
+}
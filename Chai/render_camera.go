@@ -0,0 +1,237 @@
+package chai
+
+import "sort"
+
+type RenderLayerMask uint32
+
+const (
+	RENDER_LAYER_DEFAULT uint8           = 0
+	RENDER_LAYER_ALL     RenderLayerMask = 0xFFFFFFFF
+)
+
+func RenderLayerBit(_layer uint8) RenderLayerMask {
+	return RenderLayerMask(1) << (_layer % 32)
+}
+
+func (mask RenderLayerMask) Has(_layer uint8) bool {
+	return mask&RenderLayerBit(_layer) != 0
+}
+
+type CameraLighting uint8
+
+const (
+	// Lit unless the camera is ScreenSpace, so the light map doesn't darken the UI
+	CAMERA_LIGHTING_AUTO CameraLighting = iota
+	CAMERA_LIGHTING_ON
+	CAMERA_LIGHTING_OFF
+)
+
+// A camera entity, the scene is drawn once per camera in ascending Order.
+// When a scene has no camera entities, the global Cam renders to the whole canvas.
+// The render systems run for every camera, App.OnDraw once per frame, see frameUserDraws
+type CameraComponent struct {
+	Component
+	// Normalized rectangle of the scene viewport the camera draws to, (0, 0) is the bottom-left corner. See GetViewportRect
+	Viewport   Rect
+	Clear      bool
+	ClearColor RGBA8
+	// Entities whose RenderLayer is not in the mask are skipped by the render systems
+	LayerMask RenderLayerMask
+	Order     int
+	Zoom      float32
+	// Ignores the entity transform and zoom, one world unit is one virtual pixel with the origin at the bottom-left of the viewport. Meant for UI
+	ScreenSpace bool
+	// Copies the position, scale and rotation of the global Cam instead of using the entity transform
+	UseMainCamera bool
+	// Whether the scene lighting is applied to what the camera draws
+	Lighting CameraLighting
+}
+
+func (t *CameraComponent) ComponentSet(val interface{}) { *t = val.(CameraComponent) }
+
+func NewCameraComponent(_viewport Rect, _order int) CameraComponent {
+	return CameraComponent{
+		Viewport:   _viewport,
+		Clear:      true,
+		ClearColor: NewRGBA8(0, 0, 0, 255),
+		LayerMask:  RENDER_LAYER_ALL,
+		Order:      _order,
+		Zoom:       1.0,
+	}
+}
+
+type renderCamera struct {
+	camera     Camera2D
+	x, y       int
+	width      int
+	height     int
+	clear      bool
+	clearColor RGBA8
+	layerMask  RenderLayerMask
+	order      int
+	lit        bool
+}
+
+var renderCameras []renderCamera
+var currentRenderCamera *Camera2D = &Cam
+var currentRenderLayerMask RenderLayerMask = RENDER_LAYER_ALL
+
+// The camera the scene is currently being drawn with, the global Cam outside of drawing
+func GetRenderCamera() *Camera2D {
+	return currentRenderCamera
+}
+
+func IsRenderLayerVisible(_layer uint8) bool {
+	return currentRenderLayerMask.Has(_layer)
+}
+
+// The top-most camera whose viewport contains the point in canvas pixels, nil if there is none
+func GetCameraAt(_screenPoint Vector2f) *Camera2D {
+	for i := len(renderCameras) - 1; i >= 0; i-- {
+		rc := &renderCameras[i]
+		if NewRect(float32(rc.x), float32(rc.y), float32(rc.width), float32(rc.height)).Contains(_screenPoint) {
+			return &rc.camera
+		}
+	}
+	if len(renderCameras) == 0 {
+		return &Cam
+	}
+	return nil
+}
+
+func collectRenderCameras() []renderCamera {
+	renderCameras = renderCameras[:0]
+	EachEntity(CameraComponent{}, func(entity *EcsEntity, a interface{}) {
+		component := a.(CameraComponent)
+
+		rc := renderCamera{
+			x:          display.viewportX + int(component.Viewport.Position.X*float32(display.viewportWidth)),
+			y:          display.viewportY + int(component.Viewport.Position.Y*float32(display.viewportHeight)),
+			width:      int(component.Viewport.Size.X * float32(display.viewportWidth)),
+			height:     int(component.Viewport.Size.Y * float32(display.viewportHeight)),
+			clear:      component.Clear,
+			clearColor: component.ClearColor,
+			layerMask:  component.LayerMask,
+			order:      component.Order,
+			lit:        component.Lighting == CAMERA_LIGHTING_ON || (component.Lighting == CAMERA_LIGHTING_AUTO && !component.ScreenSpace),
+		}
+		if rc.width <= 0 || rc.height <= 0 {
+			return
+		}
+
+		rc.camera.setViewport(rc.x, rc.y, rc.width, rc.height)
+		rc.camera.pixelScale = display.pixelScale
+		switch {
+		case component.ScreenSpace:
+			rc.camera.position = rc.camera.viewportSize.Scale(0.5 / display.pixelScale)
+			rc.camera.scale = 1.0
+		case component.UseMainCamera:
+			rc.camera.position = Cam.position
+			rc.camera.scale = Cam.scale
+			rc.camera.rotation = Cam.rotation
+			rc.camera.shakeOffset = Cam.shakeOffset
+			rc.camera.shakeAngle = Cam.shakeAngle
+		default:
+			rc.camera.position = entity.Pos
+			rc.camera.scale = component.Zoom
+			rc.camera.rotation = entity.Rot
+		}
+		rc.camera.Update(*appRef)
+		renderCameras = append(renderCameras, rc)
+	})
+	sort.SliceStable(renderCameras, func(i, j int) bool {
+		return renderCameras[i].order < renderCameras[j].order
+	})
+	return renderCameras
+}
+
+// Draws the scene once per camera entity, or once with the global Cam when there are none
+func drawSceneCameras(_canvasWidth, _canvasHeight int) {
+	cullingFrame++
+	cameras := collectRenderCameras()
+	letterboxed := display.viewportWidth != _canvasWidth || display.viewportHeight != _canvasHeight
+	if letterboxed {
+		setBackgroundColor(appRef.LetterboxColor)
+		canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+	}
+	if len(cameras) == 0 {
+		if letterboxed {
+			canvasContext.Call("enable", canvasContext.Get("SCISSOR_TEST"))
+			canvasContext.Call("scissor", display.viewportX, display.viewportY, display.viewportWidth, display.viewportHeight)
+			canvasContext.Call("viewport", display.viewportX, display.viewportY, display.viewportWidth, display.viewportHeight)
+		}
+		setBackgroundColor(current_scene.Background)
+		canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+		drawSceneWith(&Cam, RENDER_LAYER_ALL, true, 0, 1)
+		if letterboxed {
+			canvasContext.Call("disable", canvasContext.Get("SCISSOR_TEST"))
+			canvasContext.Call("viewport", 0, 0, _canvasWidth, _canvasHeight)
+		}
+		return
+	}
+
+	canvasContext.Call("enable", canvasContext.Get("SCISSOR_TEST"))
+	for i := range cameras {
+		rc := &cameras[i]
+		canvasContext.Call("viewport", rc.x, rc.y, rc.width, rc.height)
+		canvasContext.Call("scissor", rc.x, rc.y, rc.width, rc.height)
+		if rc.clear {
+			setBackgroundColor(rc.clearColor)
+			canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+		}
+		drawSceneWith(&rc.camera, rc.layerMask, rc.lit, i, len(cameras))
+	}
+	canvasContext.Call("disable", canvasContext.Get("SCISSOR_TEST"))
+	canvasContext.Call("viewport", 0, 0, _canvasWidth, _canvasHeight)
+}
+
+// What App.OnDraw submitted to the global batches in the pass of the first camera, replayed into the passes
+// of the others so OnDraw runs once per frame. Batches it renders itself only reach the first camera
+type userDraws struct {
+	sprites, particles, normalMaps []SpriteGlyph
+	shapes                         shapeSnapshot
+}
+
+var frameUserDraws userDraws
+
+func (ud *userDraws) save() {
+	ud.sprites = Sprites.saveGlyphs(ud.sprites[:0])
+	ud.particles = Particles.saveGlyphs(ud.particles[:0])
+	ud.normalMaps = NormalMaps.saveGlyphs(ud.normalMaps[:0])
+	Shapes.saveShapes(&ud.shapes)
+}
+
+func (ud *userDraws) restore() {
+	Sprites.restoreGlyphs(ud.sprites)
+	Particles.restoreGlyphs(ud.particles)
+	NormalMaps.restoreGlyphs(ud.normalMaps)
+	Shapes.restoreShapes(&ud.shapes)
+}
+
+// Draws pass _pass of the _passCount camera passes of the frame
+func drawSceneWith(_cam *Camera2D, _layerMask RenderLayerMask, _lit bool, _pass, _passCount int) {
+	currentRenderCamera = _cam
+	currentRenderLayerMask = _layerMask
+
+	if _pass == 0 {
+		tempDraw()
+		if _passCount > 1 {
+			frameUserDraws.save()
+		}
+	} else {
+		frameUserDraws.restore()
+	}
+	current_scene.OnDraw()
+	Sprites.Render(_cam)
+	Particles.Render(_cam)
+	Shapes.Render(_cam)
+	if _lit {
+		renderSceneLighting(current_scene, _cam)
+	} else {
+		// The normal maps queued by the render systems would otherwise reach the next lit camera
+		NormalMaps.Reset()
+	}
+
+	currentRenderCamera = &Cam
+	currentRenderLayerMask = RENDER_LAYER_ALL
+}
@@ -0,0 +1,199 @@
+package chai
+
+import (
+	"syscall/js"
+)
+
+// An offscreen framebuffer with a color texture that can be drawn like any other Texture2D
+type RenderTarget struct {
+	Width, Height   int
+	colorTexture    Texture2D
+	gl              *renderTargetHandles
+	hasDepthStencil bool
+}
+
+// Shared by the copies of a RenderTarget, like textureHandle
+type renderTargetHandles struct {
+	framebuffer  js.Value
+	depthStencil js.Value
+	generation   uint32
+}
+
+// The render targets currently bound, the last one receives the draw calls
+var renderTargetStack []*RenderTarget
+
+// The viewport that was set when each render target of the stack was bound, Unbind restores it
+var renderTargetViewports []js.Value
+
+func NewRenderTarget(_width, _height int, _depthStencil bool) RenderTarget {
+	var rt RenderTarget
+	rt.hasDepthStencil = _depthStencil
+	rt.gl = &renderTargetHandles{}
+	rt.colorTexture.uid = nextTextureUid()
+	rt.colorTexture.handle = newTextureHandle(_width, _height, TextureOptions{MinFilter: TEXTURE_FILTER_LINEAR, MagFilter: TEXTURE_FILTER_LINEAR})
+	rt.createHandles()
+	rt.Resize(_width, _height)
+
+	return rt
+}
+
+func (rt *RenderTarget) createHandles() {
+	rt.gl.framebuffer = canvasContext.Call("createFramebuffer")
+	if rt.hasDepthStencil {
+		rt.gl.depthStencil = canvasContext.Call("createRenderbuffer")
+	}
+	rt.gl.generation = contextGeneration
+}
+
+// Recreates the framebuffer after a context loss, the content comes back cleared
+func (rt *RenderTarget) ensureContext() {
+	if rt.gl.generation == contextGeneration {
+		return
+	}
+	rt.createHandles()
+	rt.Resize(rt.Width, rt.Height)
+}
+
+// Reallocates the attachments of the render target, the previous content is lost
+func (rt *RenderTarget) Resize(_width, _height int) {
+	Assert(_width > 0 && _height > 0, "RenderTarget: invalid size %vx%v", _width, _height)
+	rt.Width = _width
+	rt.Height = _height
+	rt.colorTexture.Width = _width
+	rt.colorTexture.Height = _height
+	rt.colorTexture.handle.width, rt.colorTexture.handle.height = _width, _height
+
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), rt.colorTexture.glTexture())
+	canvasContext.Call("texImage2D", canvasContext.Get("TEXTURE_2D"), 0, canvasContext.Get("RGBA8"), _width, _height, 0, canvasContext.Get("RGBA"), canvasContext.Get("UNSIGNED_BYTE"), js.Null())
+	// Keeps what was set through GetTexture().SetOptions
+	rt.colorTexture.handle.applyOptions()
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), js.Null())
+
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("FRAMEBUFFER"), rt.gl.framebuffer)
+	canvasContext.Call("framebufferTexture2D", canvasContext.Get("FRAMEBUFFER"), canvasContext.Get("COLOR_ATTACHMENT0"), canvasContext.Get("TEXTURE_2D"), rt.colorTexture.glTexture(), 0)
+
+	if rt.hasDepthStencil {
+		canvasContext.Call("bindRenderbuffer", canvasContext.Get("RENDERBUFFER"), rt.gl.depthStencil)
+		canvasContext.Call("renderbufferStorage", canvasContext.Get("RENDERBUFFER"), canvasContext.Get("DEPTH24_STENCIL8"), _width, _height)
+		canvasContext.Call("framebufferRenderbuffer", canvasContext.Get("FRAMEBUFFER"), canvasContext.Get("DEPTH_STENCIL_ATTACHMENT"), canvasContext.Get("RENDERBUFFER"), rt.gl.depthStencil)
+		canvasContext.Call("bindRenderbuffer", canvasContext.Get("RENDERBUFFER"), js.Null())
+	}
+
+	status := canvasContext.Call("checkFramebufferStatus", canvasContext.Get("FRAMEBUFFER"))
+	if !status.Equal(canvasContext.Get("FRAMEBUFFER_COMPLETE")) {
+		WarningF("[RENDER TARGET]: framebuffer is incomplete (%v)", status.Int())
+	}
+
+	rebindCurrentFramebuffer()
+}
+
+// Redirects every following draw call into the render target until Unbind is called. The viewport covers
+// the whole target
+func (rt *RenderTarget) Bind() {
+	renderTargetViewports = append(renderTargetViewports, canvasContext.Call("getParameter", canvasContext.Get("VIEWPORT")))
+	renderTargetStack = append(renderTargetStack, rt)
+	rebindCurrentFramebuffer()
+}
+
+// Restores the previously bound render target, or the canvas, with the viewport it had when Bind was called
+func (rt *RenderTarget) Unbind() {
+	AssertNot(len(renderTargetStack) == 0 || renderTargetStack[len(renderTargetStack)-1] != rt, "RenderTarget: Unbind called on a render target that is not bound")
+	renderTargetStack = renderTargetStack[:len(renderTargetStack)-1]
+	viewport := renderTargetViewports[len(renderTargetViewports)-1]
+	renderTargetViewports = renderTargetViewports[:len(renderTargetViewports)-1]
+	rebindCurrentFramebuffer()
+	canvasContext.Call("viewport", viewport.Index(0).Int(), viewport.Index(1).Int(), viewport.Index(2).Int(), viewport.Index(3).Int())
+}
+
+func rebindCurrentFramebuffer() {
+	if len(renderTargetStack) == 0 {
+		canvasContext.Call("bindFramebuffer", canvasContext.Get("FRAMEBUFFER"), js.Null())
+		canvasContext.Call("viewport", 0, 0, currentWidth, currentHeight)
+		return
+	}
+	top := renderTargetStack[len(renderTargetStack)-1]
+	top.ensureContext()
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("FRAMEBUFFER"), top.gl.framebuffer)
+	canvasContext.Call("viewport", 0, 0, top.Width, top.Height)
+}
+
+// Size in pixels of the render target currently bound, or of the canvas
+func currentFramebufferSize() (int, int) {
+	if len(renderTargetStack) == 0 {
+		return currentWidth, currentHeight
+	}
+	top := renderTargetStack[len(renderTargetStack)-1]
+	return top.Width, top.Height
+}
+
+func (rt *RenderTarget) Clear(_color RGBA8) {
+	rt.Bind()
+	canvasContext.Call("clearColor", _color.GetColorRFloat32(), _color.GetColorGFloat32(), _color.GetColorBFloat32(), _color.GetColorAFloat32())
+	mask := canvasContext.Get("COLOR_BUFFER_BIT").Int()
+	if rt.hasDepthStencil {
+		mask |= canvasContext.Get("DEPTH_BUFFER_BIT").Int() | canvasContext.Get("STENCIL_BUFFER_BIT").Int()
+	}
+	canvasContext.Call("clear", mask)
+	rt.Unbind()
+}
+
+func (rt *RenderTarget) HasDepthStencil() bool {
+	return rt.hasDepthStencil
+}
+
+// The color attachment, it can be passed to any SpriteBatch draw call
+func (rt *RenderTarget) GetTexture() *Texture2D {
+	return &rt.colorTexture
+}
+
+// Framebuffer textures are stored bottom-up, these are the uvs that draw them upright
+func (rt *RenderTarget) UV() (Vector2f, Vector2f) {
+	return NewVector2f(0.0, 1.0), NewVector2f(1.0, 0.0)
+}
+
+func (rt *RenderTarget) Delete() {
+	canvasContext.Call("deleteFramebuffer", rt.gl.framebuffer)
+	rt.colorTexture.Delete()
+	if rt.hasDepthStencil {
+		canvasContext.Call("deleteRenderbuffer", rt.gl.depthStencil)
+	}
+}
+
+func (self *SpriteBatch) RenderTo(_target *RenderTarget, cam *Camera2D) {
+	_target.Bind()
+	self.Render(cam)
+	_target.Unbind()
+}
+
+func (_sp *ShapeBatch) RenderTo(_target *RenderTarget, cam *Camera2D) {
+	_target.Bind()
+	_sp.Render(cam)
+	_target.Unbind()
+}
+
+// Draws the render systems of the scene into the target, cleared with the scene background. cam is projected
+// onto the whole target, its own viewport is left as it was
+func RenderSceneTo(_target *RenderTarget, _scene *Scene, cam *Camera2D) {
+	targetCam := *cam
+	targetCam.setViewport(0, 0, _target.Width, _target.Height)
+	targetCam.Update(*appRef)
+	cam = &targetCam
+
+	_target.Bind()
+	setBackgroundColor(_scene.Background)
+	canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+
+	cullingFrame++
+	previousScene := current_scene
+	current_scene = _scene
+	currentRenderCamera = cam
+	_scene.OnDraw()
+	currentRenderCamera = &Cam
+	current_scene = previousScene
+
+	Sprites.Render(cam)
+	Particles.Render(cam)
+	Shapes.Render(cam)
+	renderSceneLighting(_scene, cam)
+	_target.Unbind()
+}
@@ -16,12 +16,28 @@ type ShaderProgram struct {
 	ShaderSource     ShaderSource
 	AttributesNumber int
 	ShaderProgramID  js.Value
+	uniformLocations map[string]js.Value
+	// Bound again when the program is relinked after a context loss
+	attributes []string
+	generation uint32
 }
 
 func UseShader(_sp *ShaderProgram) {
+	_sp.ensureContext()
 	canvasContext.Call("useProgram", _sp.ShaderProgramID)
 }
 
+// Relinks the program from its sources if the context was restored since it was created
+func (_sp *ShaderProgram) ensureContext() {
+	if _sp.generation == contextGeneration || _sp.ShaderProgramID.IsUndefined() {
+		return
+	}
+	_sp.linkProgram()
+	for i, attribute := range _sp.attributes {
+		canvasContext.Call("bindAttribLocation", _sp.ShaderProgramID, i, attribute)
+	}
+}
+
 func UnuseShader() {
 	//glRef.UseProgram(nil)
 	canvasContext.Call("useProgram", js.Null())
@@ -68,6 +84,13 @@ func (_sp *ShaderProgram) ParseShaderFromFile(_filePath string) {
 
 func (_sp *ShaderProgram) CreateShaderProgram() {
 	_sp.AttributesNumber = 0
+	_sp.attributes = _sp.attributes[:0]
+	_sp.linkProgram()
+}
+
+func (_sp *ShaderProgram) linkProgram() {
+	_sp.generation = contextGeneration
+	_sp.uniformLocations = make(map[string]js.Value)
 	_sp.ShaderProgramID = canvasContext.Call("createProgram")
 	vertex_shader := CompileShader(canvasContext.Get("VERTEX_SHADER"), _sp.ShaderSource.vertexShader)
 	fragment_shader := CompileShader(canvasContext.Get("FRAGMENT_SHADER"), _sp.ShaderSource.fragmentShader)
@@ -77,7 +100,8 @@ func (_sp *ShaderProgram) CreateShaderProgram() {
 
 	canvasContext.Call("linkProgram", _sp.ShaderProgramID)
 
-	if canvasContext.Call("getProgramParameter", _sp.ShaderProgramID, canvasContext.Get("LINK_STATUS")).IsNull() {
+	// Every call fails while the context is lost, the program is linked again once it is restored
+	if canvasContext.Call("getProgramParameter", _sp.ShaderProgramID, canvasContext.Get("LINK_STATUS")).IsNull() && !contextLost {
 		//return webgl.Program(js.Null()), errors.New("link failed: " + glRef.GetProgramInfoLog(program))
 		WarningF("[LINK FAILED]: " + canvasContext.Call("getProgramInfoLog", _sp.ShaderProgramID).String())
 	}
@@ -86,6 +110,7 @@ func (_sp *ShaderProgram) CreateShaderProgram() {
 func (_sp *ShaderProgram) AddAttribute(_attributeName string) {
 	//BindAttribLocation(_sp.ShaderProgramID, _sp.AttributesNumber, _attributeName)
 	canvasContext.Call("bindAttribLocation", _sp.ShaderProgramID, _sp.AttributesNumber, _attributeName)
+	_sp.attributes = append(_sp.attributes, _attributeName)
 	_sp.AttributesNumber += 1
 }
 
@@ -95,7 +120,7 @@ func CompileShader(_shaderType js.Value, _shaderSource string) js.Value {
 	canvasContext.Call("shaderSource", shader, _shaderSource)
 	canvasContext.Call("compileShader", shader)
 
-	if canvasContext.Call("getShaderParameter", shader, canvasContext.Get("COMPILE_STATUS")).IsNull() {
+	if canvasContext.Call("getShaderParameter", shader, canvasContext.Get("COMPILE_STATUS")).IsNull() && !contextLost {
 		if _shaderType.Equal(canvasContext.Get("FRAGMENT_SHADER")) {
 			WarningF("[FRAGMENT SHADER] compile failure: " + canvasContext.Call("getShaderInfoLog", shader).String())
 
@@ -108,5 +133,59 @@ func CompileShader(_shaderType js.Value, _shaderSource string) js.Value {
 }
 
 func (_sp *ShaderProgram) GetUniformLocation(_uniformName string) js.Value {
-	return canvasContext.Call("getUniformLocation", _sp.ShaderProgramID, _uniformName)
+	_sp.ensureContext()
+	if _sp.uniformLocations == nil {
+		_sp.uniformLocations = make(map[string]js.Value)
+	}
+	location, ok := _sp.uniformLocations[_uniformName]
+	if !ok {
+		location = canvasContext.Call("getUniformLocation", _sp.ShaderProgramID, _uniformName)
+		_sp.uniformLocations[_uniformName] = location
+	}
+	return location
+}
+
+func (_sp *ShaderProgram) HasUniform(_uniformName string) bool {
+	return !_sp.GetUniformLocation(_uniformName).IsNull()
+}
+
+/*
+The setters below write to the uniforms of the program, which has to be in use (UseShader).
+Uniforms that do not exist in the program, or were optimized away, are silently ignored.
+*/
+
+func (_sp *ShaderProgram) SetUniformFloat(_uniformName string, _value float32) {
+	canvasContext.Call("uniform1f", _sp.GetUniformLocation(_uniformName), _value)
+}
+
+func (_sp *ShaderProgram) SetUniformVec2(_uniformName string, _value Vector2f) {
+	canvasContext.Call("uniform2f", _sp.GetUniformLocation(_uniformName), _value.X, _value.Y)
+}
+
+func (_sp *ShaderProgram) SetUniformVec3(_uniformName string, _x, _y, _z float32) {
+	canvasContext.Call("uniform3f", _sp.GetUniformLocation(_uniformName), _x, _y, _z)
+}
+
+func (_sp *ShaderProgram) SetUniformVec4(_uniformName string, _x, _y, _z, _w float32) {
+	canvasContext.Call("uniform4f", _sp.GetUniformLocation(_uniformName), _x, _y, _z, _w)
+}
+
+// Uploads the color as a vec4 with components in the 0-1 range
+func (_sp *ShaderProgram) SetUniformColor(_uniformName string, _color RGBA8) {
+	_sp.SetUniformVec4(_uniformName, _color.GetColorRFloat32(), _color.GetColorGFloat32(), _color.GetColorBFloat32(), _color.GetColorAFloat32())
+}
+
+// _matrix holds the 16 values in column-major order, like goglmath.Matrix4.Data()
+func (_sp *ShaderProgram) SetUniformMat4(_uniformName string, _matrix []float32) {
+	Assert(len(_matrix) == 16, "SetUniformMat4: expected 16 values, got %v", len(_matrix))
+	canvasContext.Call("uniformMatrix4fv", _sp.GetUniformLocation(_uniformName), false, float32BufferToJsFloat32Buffer(_matrix))
+}
+
+func (_sp *ShaderProgram) SetUniformInt(_uniformName string, _value int) {
+	canvasContext.Call("uniform1i", _sp.GetUniformLocation(_uniformName), _value)
+}
+
+// Points a sampler uniform to a texture unit
+func (_sp *ShaderProgram) SetUniformSampler(_uniformName string, _textureUnit int) {
+	_sp.SetUniformInt(_uniformName, _textureUnit)
 }
@@ -0,0 +1,733 @@
+package chai
+
+import (
+	"math"
+	"syscall/js"
+)
+
+type LineJoin uint8
+
+const (
+	LINE_JOIN_MITER LineJoin = iota
+	LINE_JOIN_BEVEL
+	LINE_JOIN_ROUND
+)
+
+type LineCap uint8
+
+const (
+	LINE_CAP_BUTT LineCap = iota
+	LINE_CAP_SQUARE
+	LINE_CAP_ROUND
+)
+
+const (
+	// Maximum distance in pixels between a curve and the segments approximating it
+	SHAPE_CURVE_TOLERANCE float32 = 0.25
+	SHAPE_MIN_SEGMENTS            = 8
+	SHAPE_MAX_SEGMENTS            = 256
+	// Miter joins longer than this many half widths are drawn as bevels
+	SHAPE_MITER_LIMIT float32 = 4.0
+)
+
+/* ####### Circles & Ellipses ####### */
+
+func (_sp *ShapeBatch) DrawFillCircle(_center Vector2f, _radius float32, _color RGBA8) {
+	_sp.fillFan(ellipsePoints(_center, NewVector2f(_radius, _radius), 0.0, 0.0, 360.0, segmentsForRadius(_radius, 360.0), false), _color)
+}
+
+func (_sp *ShapeBatch) DrawEllipse(_center, _radii Vector2f, _color RGBA8, _rotation float32) {
+	points := ellipsePoints(_center, _radii, _rotation, 0.0, 360.0, segmentsForRadius(MaxFloat32(_radii.X, _radii.Y), 360.0), false)
+	_sp.strokePolyline(points, _sp.strokeWidth(), true, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawFillEllipse(_center, _radii Vector2f, _color RGBA8, _rotation float32) {
+	_sp.fillFan(ellipsePoints(_center, _radii, _rotation, 0.0, 360.0, segmentsForRadius(MaxFloat32(_radii.X, _radii.Y), 360.0), false), _color)
+}
+
+/* ####### Arcs & Pies ####### */
+
+// Outline of the part of a circle between two angles in degrees, counter-clockwise from _startAngle
+func (_sp *ShapeBatch) DrawArc(_center Vector2f, _radius, _startAngle, _endAngle float32, _color RGBA8) {
+	sweep := _endAngle - _startAngle
+	points := ellipsePoints(_center, NewVector2f(_radius, _radius), 0.0, _startAngle, sweep, segmentsForRadius(_radius, sweep), true)
+	_sp.strokePolyline(points, _sp.strokeWidth(), false, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawPie(_center Vector2f, _radius, _startAngle, _endAngle float32, _color RGBA8) {
+	sweep := _endAngle - _startAngle
+	points := append([]Vector2f{_center}, ellipsePoints(_center, NewVector2f(_radius, _radius), 0.0, _startAngle, sweep, segmentsForRadius(_radius, sweep), true)...)
+	_sp.strokePolyline(points, _sp.strokeWidth(), true, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawFillPie(_center Vector2f, _radius, _startAngle, _endAngle float32, _color RGBA8) {
+	sweep := _endAngle - _startAngle
+	points := append([]Vector2f{_center}, ellipsePoints(_center, NewVector2f(_radius, _radius), 0.0, _startAngle, sweep, segmentsForRadius(_radius, sweep), true)...)
+	// Every point of a pie slice is visible from its centre, so a fan is enough even above 180 degrees
+	_sp.fillFan(points, _color)
+}
+
+/* ####### Rounded Rectangles & Capsules ####### */
+
+func (_sp *ShapeBatch) DrawRoundedRect(_center, _dimensions Vector2f, _radius float32, _color RGBA8, _rotation float32) {
+	_sp.strokePolyline(roundedRectPoints(_center, _dimensions, _radius, _rotation), _sp.strokeWidth(), true, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawFillRoundedRect(_center, _dimensions Vector2f, _radius float32, _color RGBA8, _rotation float32) {
+	_sp.fillFan(roundedRectPoints(_center, _dimensions, _radius, _rotation), _color)
+}
+
+// A rectangle with half circles of _radius at _from and _to
+func (_sp *ShapeBatch) DrawCapsule(_from, _to Vector2f, _radius float32, _color RGBA8) {
+	_sp.strokePolyline(capsulePoints(_from, _to, _radius), _sp.strokeWidth(), true, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawFillCapsule(_from, _to Vector2f, _radius float32, _color RGBA8) {
+	_sp.fillFan(capsulePoints(_from, _to, _radius), _color)
+}
+
+/* ####### Polygons & Polylines ####### */
+
+func (_sp *ShapeBatch) DrawFillTriangle(_p1, _p2, _p3 Vector2f, _color RGBA8) {
+	_sp.fillFan([]Vector2f{_p1, _p2, _p3}, _color)
+}
+
+func (_sp *ShapeBatch) DrawPolygon(_points []Vector2f, _color RGBA8) {
+	_sp.strokePolyline(_points, _sp.strokeWidth(), true, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+// Fills a simple polygon, convex or concave, given in either winding order
+func (_sp *ShapeBatch) DrawFillPolygon(_points []Vector2f, _color RGBA8) {
+	if len(_points) < 3 {
+		return
+	}
+	_sp.fillTriangles(_points, TriangulatePolygon(_points), _color)
+}
+
+// Draws connected line segments _width wide, using the join and cap styles given
+func (_sp *ShapeBatch) DrawPolyline(_points []Vector2f, _width float32, _closed bool, _join LineJoin, _cap LineCap, _color RGBA8) {
+	_sp.strokePolyline(_points, _width, _closed, _join, _cap, _color)
+}
+
+/* ####### Geometry ####### */
+
+// The outlines use the same thickness as DrawLine, which offsets both sides by LineWidth
+func (_sp *ShapeBatch) strokeWidth() float32 {
+	return _sp.LineWidth * 2.0
+}
+
+func (_sp *ShapeBatch) pushVertex(_position Vector2f, _color RGBA8) int32 {
+	_sp.Vertices = append(_sp.Vertices, Vertex{Coordinates: _position, Color: _color})
+	return int32(len(_sp.Vertices) - 1)
+}
+
+func (_sp *ShapeBatch) pushTriangle(_a, _b, _c int32) {
+	_sp.Indices = append(_sp.Indices, _a, _b, _c)
+}
+
+// Fills a polygon whose every point is visible from the first one (convex polygons, pies)
+func (_sp *ShapeBatch) fillFan(_points []Vector2f, _color RGBA8) {
+	if len(_points) < 3 {
+		return
+	}
+	first := int32(len(_sp.Vertices))
+	for _, point := range _points {
+		_sp.pushVertex(point, _color)
+	}
+	for i := int32(1); i < int32(len(_points))-1; i++ {
+		_sp.pushTriangle(first, first+i, first+i+1)
+	}
+}
+
+func (_sp *ShapeBatch) fillTriangles(_points []Vector2f, _indices []int32, _color RGBA8) {
+	first := int32(len(_sp.Vertices))
+	for _, point := range _points {
+		_sp.pushVertex(point, _color)
+	}
+	for _, index := range _indices {
+		_sp.Indices = append(_sp.Indices, first+index)
+	}
+}
+
+// Vertex indices used by the segment entering and the segment leaving a polyline point
+type polylineJoint struct {
+	leftIn, rightIn, leftOut, rightOut int32
+}
+
+func (_sp *ShapeBatch) strokePolyline(_points []Vector2f, _width float32, _closed bool, _join LineJoin, _cap LineCap, _color RGBA8) {
+	points := removeDuplicatePoints(_points, _closed)
+	count := len(points)
+	if count < 2 || _width <= 0.0 {
+		return
+	}
+	if count == 2 {
+		_closed = false
+	}
+	halfWidth := _width / 2.0
+
+	joints := make([]polylineJoint, count)
+	for i := 0; i < count; i++ {
+		point := points[i]
+		hasPrevious := _closed || i > 0
+		hasNext := _closed || i < count-1
+
+		if !hasPrevious || !hasNext {
+			var direction Vector2f
+			if hasNext {
+				direction = points[i+1].Subtract(point).Normalize()
+			} else {
+				direction = point.Subtract(points[i-1]).Normalize()
+			}
+			normal := direction.Perpendicular()
+			if _cap == LINE_CAP_SQUARE {
+				if hasNext {
+					point = point.Subtract(direction.Scale(halfWidth))
+				} else {
+					point = point.Add(direction.Scale(halfWidth))
+				}
+			}
+			left := _sp.pushVertex(point.Add(normal.Scale(halfWidth)), _color)
+			right := _sp.pushVertex(point.Subtract(normal.Scale(halfWidth)), _color)
+			joints[i] = polylineJoint{left, right, left, right}
+
+			if _cap == LINE_CAP_ROUND {
+				// Half circle behind the start or ahead of the end, from the left vertex to the right one
+				sweep := float32(180.0)
+				if !hasNext {
+					sweep = -180.0
+				}
+				_sp.pushWedge(point, left, right, normal.Angle()*180.0/PI, sweep, halfWidth, _color)
+			}
+			continue
+		}
+
+		previous := points[(i-1+count)%count]
+		next := points[(i+1)%count]
+		directionIn := point.Subtract(previous).Normalize()
+		directionOut := next.Subtract(point).Normalize()
+		normalIn := directionIn.Perpendicular()
+		normalOut := directionOut.Perpendicular()
+		turn := CrossProduct(directionIn, directionOut)
+
+		miter := normalIn.Add(normalOut)
+		if miter.Length() < 1e-4 {
+			// The line folds back on itself, there is no inner side to share
+			leftIn := _sp.pushVertex(point.Add(normalIn.Scale(halfWidth)), _color)
+			rightIn := _sp.pushVertex(point.Subtract(normalIn.Scale(halfWidth)), _color)
+			leftOut := _sp.pushVertex(point.Add(normalOut.Scale(halfWidth)), _color)
+			rightOut := _sp.pushVertex(point.Subtract(normalOut.Scale(halfWidth)), _color)
+			joints[i] = polylineJoint{leftIn, rightIn, leftOut, rightOut}
+			if _join == LINE_JOIN_ROUND {
+				_sp.pushWedge(point, leftIn, leftOut, normalIn.Angle()*180.0/PI, 180.0, halfWidth, _color)
+			}
+			continue
+		}
+		miter = miter.Normalize()
+		miterLength := halfWidth / DotProduct(miter, normalIn)
+
+		if AbsFloat32(turn) < 1e-4 || (_join == LINE_JOIN_MITER && miterLength <= SHAPE_MITER_LIMIT*halfWidth) {
+			left := _sp.pushVertex(point.Add(miter.Scale(miterLength)), _color)
+			right := _sp.pushVertex(point.Subtract(miter.Scale(miterLength)), _color)
+			joints[i] = polylineJoint{left, right, left, right}
+			continue
+		}
+
+		// The inner side shares the miter point, the outer side is closed with a bevel or a round wedge
+		innerLength := MinFloat32(miterLength, SHAPE_MITER_LIMIT*halfWidth)
+		center := _sp.pushVertex(point, _color)
+		if turn > 0.0 {
+			left := _sp.pushVertex(point.Add(miter.Scale(innerLength)), _color)
+			rightIn := _sp.pushVertex(point.Subtract(normalIn.Scale(halfWidth)), _color)
+			rightOut := _sp.pushVertex(point.Subtract(normalOut.Scale(halfWidth)), _color)
+			joints[i] = polylineJoint{left, rightIn, left, rightOut}
+			_sp.pushTriangle(center, left, rightIn)
+			_sp.pushTriangle(center, rightOut, left)
+			_sp.closeJoin(_join, point, center, rightIn, rightOut, normalIn.Scale(-1.0), normalOut.Scale(-1.0), halfWidth, _color)
+		} else {
+			right := _sp.pushVertex(point.Subtract(miter.Scale(innerLength)), _color)
+			leftIn := _sp.pushVertex(point.Add(normalIn.Scale(halfWidth)), _color)
+			leftOut := _sp.pushVertex(point.Add(normalOut.Scale(halfWidth)), _color)
+			joints[i] = polylineJoint{leftIn, right, leftOut, right}
+			_sp.pushTriangle(center, leftIn, right)
+			_sp.pushTriangle(center, right, leftOut)
+			_sp.closeJoin(_join, point, center, leftIn, leftOut, normalIn, normalOut, halfWidth, _color)
+		}
+	}
+
+	segments := count - 1
+	if _closed {
+		segments = count
+	}
+	for i := 0; i < segments; i++ {
+		from := joints[i]
+		to := joints[(i+1)%count]
+		_sp.pushTriangle(from.leftOut, from.rightOut, to.leftIn)
+		_sp.pushTriangle(to.leftIn, from.rightOut, to.rightIn)
+	}
+}
+
+func (_sp *ShapeBatch) closeJoin(_join LineJoin, _point Vector2f, _center, _outerIn, _outerOut int32, _normalIn, _normalOut Vector2f, _halfWidth float32, _color RGBA8) {
+	if _join != LINE_JOIN_ROUND {
+		_sp.pushTriangle(_center, _outerIn, _outerOut)
+		return
+	}
+	startAngle := _normalIn.Angle() * 180.0 / PI
+	sweep := (_normalOut.Angle() - _normalIn.Angle()) * 180.0 / PI
+	if sweep > 180.0 {
+		sweep -= 360.0
+	} else if sweep < -180.0 {
+		sweep += 360.0
+	}
+	_sp.pushWedge(_point, _outerIn, _outerOut, startAngle, sweep, _halfWidth, _color)
+}
+
+// Fills the circle sector around _point between two existing vertices
+func (_sp *ShapeBatch) pushWedge(_point Vector2f, _from, _to int32, _startAngle, _sweep, _radius float32, _color RGBA8) {
+	segments := segmentsForRadius(_radius, _sweep)
+	center := _sp.pushVertex(_point, _color)
+	previous := _from
+	for i := 1; i < segments; i++ {
+		angle := Deg2Rad(_startAngle + _sweep*float32(i)/float32(segments))
+		current := _sp.pushVertex(_point.AddXY(float32(math.Cos(float64(angle)))*_radius, float32(math.Sin(float64(angle)))*_radius), _color)
+		_sp.pushTriangle(center, previous, current)
+		previous = current
+	}
+	_sp.pushTriangle(center, previous, _to)
+}
+
+// Number of segments needed for an arc to stay within SHAPE_CURVE_TOLERANCE pixels of the curve with the current render camera
+func segmentsForRadius(_radius, _sweepDegrees float32) int {
+	sweep := AbsFloat32(_sweepDegrees)
+	if sweep > 360.0 {
+		sweep = 360.0
+	}
+	minSegments := int(math.Ceil(float64(SHAPE_MIN_SEGMENTS * sweep / 360.0)))
+	if minSegments < 1 {
+		minSegments = 1
+	}
+
+	radiusPixels := AbsFloat32(_radius) * GetRenderCamera().effectiveScale()
+	if radiusPixels <= SHAPE_CURVE_TOLERANCE {
+		return minSegments
+	}
+	step := 2.0 * math.Acos(float64(1.0-SHAPE_CURVE_TOLERANCE/radiusPixels))
+	segments := int(math.Ceil(float64(Deg2Rad(sweep)) / step))
+	if segments < minSegments {
+		return minSegments
+	}
+	if segments > SHAPE_MAX_SEGMENTS {
+		return SHAPE_MAX_SEGMENTS
+	}
+	return segments
+}
+
+// Points along an ellipse from _startAngle over _sweep degrees, both relative to the ellipse rotation.
+// The end point is only included when _includeEnd is set, closed shapes leave it out as it equals the start
+func ellipsePoints(_center, _radii Vector2f, _rotation, _startAngle, _sweep float32, _segments int, _includeEnd bool) []Vector2f {
+	count := _segments
+	if _includeEnd {
+		count++
+	}
+	points := make([]Vector2f, count)
+	for i := 0; i < count; i++ {
+		angle := Deg2Rad(_startAngle + _sweep*float32(i)/float32(_segments))
+		local := NewVector2f(float32(math.Cos(float64(angle)))*_radii.X, float32(math.Sin(float64(angle)))*_radii.Y)
+		points[i] = local.RotateCenter(_rotation).Add(_center)
+	}
+	return points
+}
+
+func roundedRectPoints(_center, _dimensions Vector2f, _radius, _rotation float32) []Vector2f {
+	half := _dimensions.Scale(0.5)
+	radius := ClampFloat32(_radius, 0.0, MinFloat32(half.X, half.Y))
+	inner := half.SubtractXY(radius, radius)
+	corners := [4]Vector2f{
+		NewVector2f(inner.X, inner.Y),
+		NewVector2f(-inner.X, inner.Y),
+		NewVector2f(-inner.X, -inner.Y),
+		NewVector2f(inner.X, -inner.Y),
+	}
+
+	segments := segmentsForRadius(radius, 90.0)
+	points := make([]Vector2f, 0, 4*(segments+1))
+	for i, corner := range corners {
+		if radius == 0.0 {
+			points = append(points, corner.RotateCenter(_rotation).Add(_center))
+			continue
+		}
+		points = append(points, ellipsePoints(corner, NewVector2f(radius, radius), 0.0, float32(i)*90.0, 90.0, segments, true)...)
+	}
+	if radius != 0.0 {
+		for i := range points {
+			points[i] = points[i].RotateCenter(_rotation).Add(_center)
+		}
+	}
+	return points
+}
+
+func capsulePoints(_from, _to Vector2f, _radius float32) []Vector2f {
+	direction := _to.Subtract(_from)
+	angle := float32(0.0)
+	if direction.LengthSquared() > 0.0 {
+		angle = direction.Angle() * 180.0 / PI
+	}
+	segments := segmentsForRadius(_radius, 180.0)
+	points := ellipsePoints(_to, NewVector2f(_radius, _radius), 0.0, angle-90.0, 180.0, segments, true)
+	return append(points, ellipsePoints(_from, NewVector2f(_radius, _radius), 0.0, angle+90.0, 180.0, segments, true)...)
+}
+
+func removeDuplicatePoints(_points []Vector2f, _closed bool) []Vector2f {
+	points := make([]Vector2f, 0, len(_points))
+	for _, point := range _points {
+		if len(points) > 0 && points[len(points)-1].NearlyEqual(point) {
+			continue
+		}
+		points = append(points, point)
+	}
+	if _closed && len(points) > 1 && points[0].NearlyEqual(points[len(points)-1]) {
+		points = points[:len(points)-1]
+	}
+	return points
+}
+
+/* ####### Triangulation ####### */
+
+// Ear clipping triangulation of a simple polygon without holes, in either winding order.
+// Returns three indices into _points per triangle
+func TriangulatePolygon(_points []Vector2f) []int32 {
+	count := len(_points)
+	if count < 3 {
+		return nil
+	}
+
+	// Work on a counter-clockwise list of the remaining vertices
+	remaining := make([]int32, count)
+	counterClockwise := polygonSignedArea(_points) > 0.0
+	for i := range remaining {
+		if counterClockwise {
+			remaining[i] = int32(i)
+		} else {
+			remaining[i] = int32(count - 1 - i)
+		}
+	}
+
+	indices := make([]int32, 0, (count-2)*3)
+	for len(remaining) > 3 {
+		earFound := false
+		for i := 0; i < len(remaining); i++ {
+			previous := remaining[(i-1+len(remaining))%len(remaining)]
+			current := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			if !isEar(_points, remaining, previous, current, next) {
+				continue
+			}
+			indices = append(indices, previous, current, next)
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			earFound = true
+			break
+		}
+		if !earFound {
+			// Self-intersecting or degenerate input, fan the rest so something is still drawn
+			for i := 1; i < len(remaining)-1; i++ {
+				indices = append(indices, remaining[0], remaining[i], remaining[i+1])
+			}
+			return indices
+		}
+	}
+	return append(indices, remaining[0], remaining[1], remaining[2])
+}
+
+func isEar(_points []Vector2f, _remaining []int32, _previous, _current, _next int32) bool {
+	a, b, c := _points[_previous], _points[_current], _points[_next]
+	if CrossProduct(b.Subtract(a), c.Subtract(b)) <= 0.0 {
+		return false
+	}
+	for _, index := range _remaining {
+		if index == _previous || index == _current || index == _next {
+			continue
+		}
+		if pointInTriangle(_points[index], a, b, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func pointInTriangle(_point, _a, _b, _c Vector2f) bool {
+	d1 := CrossProduct(_b.Subtract(_a), _point.Subtract(_a))
+	d2 := CrossProduct(_c.Subtract(_b), _point.Subtract(_b))
+	d3 := CrossProduct(_a.Subtract(_c), _point.Subtract(_c))
+	return d1 >= 0.0 && d2 >= 0.0 && d3 >= 0.0
+}
+
+// Positive for counter-clockwise polygons
+func polygonSignedArea(_points []Vector2f) float32 {
+	area := float32(0.0)
+	for i := range _points {
+		area += CrossProduct(_points[i], _points[(i+1)%len(_points)])
+	}
+	return area / 2.0
+}
+
+/* ####### Anti-aliasing ####### */
+
+const SHAPE_DEFAULT_SAMPLES = 4
+
+// A multisampled color buffer the anti-aliased shapes are drawn into, resolved into a texture before being composited
+type multisampleTarget struct {
+	width, height int
+	samples       int
+	framebuffer   js.Value
+	colorBuffer   js.Value
+	resolve       RenderTarget
+	created       bool
+	generation    uint32
+}
+
+func (mt *multisampleTarget) ensure(_width, _height, _samples int) {
+	maxSamples := canvasContext.Call("getParameter", canvasContext.Get("MAX_SAMPLES")).Int()
+	if _samples > maxSamples {
+		_samples = maxSamples
+	}
+	restored := mt.created && mt.generation != contextGeneration
+	if mt.created && !restored && mt.width == _width && mt.height == _height && mt.samples == _samples {
+		return
+	}
+	if !mt.created || restored {
+		mt.framebuffer = canvasContext.Call("createFramebuffer")
+		mt.colorBuffer = canvasContext.Call("createRenderbuffer")
+		mt.generation = contextGeneration
+	}
+	if !mt.created {
+		mt.resolve = NewRenderTarget(_width, _height, false)
+		mt.created = true
+	} else {
+		mt.resolve.ensureContext()
+		mt.resolve.Resize(_width, _height)
+	}
+	mt.width, mt.height, mt.samples = _width, _height, _samples
+
+	canvasContext.Call("bindRenderbuffer", canvasContext.Get("RENDERBUFFER"), mt.colorBuffer)
+	canvasContext.Call("renderbufferStorageMultisample", canvasContext.Get("RENDERBUFFER"), _samples, canvasContext.Get("RGBA8"), _width, _height)
+	canvasContext.Call("bindRenderbuffer", canvasContext.Get("RENDERBUFFER"), js.Null())
+
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("FRAMEBUFFER"), mt.framebuffer)
+	canvasContext.Call("framebufferRenderbuffer", canvasContext.Get("FRAMEBUFFER"), canvasContext.Get("COLOR_ATTACHMENT0"), canvasContext.Get("RENDERBUFFER"), mt.colorBuffer)
+	status := canvasContext.Call("checkFramebufferStatus", canvasContext.Get("FRAMEBUFFER"))
+	if !status.Equal(canvasContext.Get("FRAMEBUFFER_COMPLETE")) {
+		WarningF("[SHAPES]: multisampled framebuffer is incomplete (%v)", status.Int())
+	}
+	rebindCurrentFramebuffer()
+}
+
+// Copies the resolve texture over the current framebuffer with the blend function of the shapeBlendPass
+type shapeCompositePass struct{}
+
+func (p *shapeCompositePass) fragmentSource() string {
+	return `
+void main(void) {
+	fragColor = texture(screenTexture, vertex_UV);
+}
+`
+}
+
+func (p *shapeCompositePass) setUniforms(_shader *ShaderProgram) {}
+
+// How the shapes of one blend mode are accumulated in the multisampled buffer, cleared to clearColor, and
+// how the resolved buffer is composited so the result matches the mode without anti-aliasing. The factors are
+// the source and destination ones for the colour, then for the alpha
+type shapeBlendPass struct {
+	clearColor         [4]float32
+	accumulate         [4]string
+	composite          [4]string
+	compositeSubtracts bool
+}
+
+func shapeBlendPassFor(_mode BlendMode) shapeBlendPass {
+	pass := shapeBlendPass{
+		// Premultiplied colours blended over each other, then over the framebuffer
+		accumulate: [4]string{"SRC_ALPHA", "ONE_MINUS_SRC_ALPHA", "ONE", "ONE_MINUS_SRC_ALPHA"},
+		composite:  [4]string{"ONE", "ONE_MINUS_SRC_ALPHA", "ONE", "ONE_MINUS_SRC_ALPHA"},
+	}
+	switch _mode {
+	case BLEND_PREMULTIPLIED:
+		pass.accumulate = [4]string{"ONE", "ONE_MINUS_SRC_ALPHA", "ONE", "ONE_MINUS_SRC_ALPHA"}
+	case BLEND_ADDITIVE, BLEND_SUBTRACT:
+		// The sum of the premultiplied colours, added to or subtracted from the framebuffer
+		pass.accumulate = [4]string{"SRC_ALPHA", "ONE", "ONE", "ONE"}
+		pass.composite = [4]string{"ONE", "ONE", "ZERO", "ONE"}
+		pass.compositeSubtracts = _mode == BLEND_SUBTRACT
+	case BLEND_MULTIPLY:
+		// The product of the factors of every shape over white, the framebuffer is multiplied by it
+		pass.clearColor = [4]float32{1.0, 1.0, 1.0, 1.0}
+		pass.accumulate = [4]string{"DST_COLOR", "ONE_MINUS_SRC_ALPHA", "ZERO", "ONE"}
+		pass.composite = [4]string{"DST_COLOR", "ZERO", "ZERO", "ONE"}
+	case BLEND_SCREEN:
+		pass.accumulate = [4]string{"ONE", "ONE_MINUS_SRC_COLOR", "ONE", "ONE_MINUS_SRC_ALPHA"}
+		pass.composite = [4]string{"ONE", "ONE_MINUS_SRC_COLOR", "ZERO", "ONE"}
+	case BLEND_OPAQUE:
+		// The edges can't tell coverage from alpha, translucent opaque shapes blend like alpha ones
+		pass.accumulate = [4]string{"ONE", "ZERO", "ONE", "ZERO"}
+	}
+	return pass
+}
+
+func blendFuncSeparate(_factors [4]string) {
+	canvasContext.Call("blendFuncSeparate", canvasContext.Get(_factors[0]), canvasContext.Get(_factors[1]), canvasContext.Get(_factors[2]), canvasContext.Get(_factors[3]))
+}
+
+// Draws every run of segments with the same blend mode through the multisampled buffer
+func (_sp *ShapeBatch) renderMultisampled(segments []shapeSegment, cam *Camera2D) {
+	width, height := currentFramebufferSize()
+	samples := _sp.Samples
+	if samples <= 0 {
+		samples = SHAPE_DEFAULT_SAMPLES
+	}
+	// The camera viewport (split-screen, minimaps) is kept while drawing into the multisampled buffer. It is read
+	// first because resizing the buffer resets it
+	viewport := canvasContext.Call("getParameter", canvasContext.Get("VIEWPORT"))
+	_sp.msaa.ensure(width, height, samples)
+
+	for start := 0; start < len(segments); {
+		blendMode := _sp.segmentBlendMode(&segments[start])
+		end := start + 1
+		for end < len(segments) && _sp.segmentBlendMode(&segments[end]) == blendMode {
+			end++
+		}
+		_sp.renderMultisampledRun(segments[start:end], shapeBlendPassFor(blendMode), cam, viewport, width, height)
+		start = end
+	}
+
+	applyBlendMode(BLEND_ALPHA)
+	canvasContext.Call("viewport", viewport.Index(0).Int(), viewport.Index(1).Int(), viewport.Index(2).Int(), viewport.Index(3).Int())
+}
+
+func (_sp *ShapeBatch) renderMultisampledRun(segments []shapeSegment, _pass shapeBlendPass, cam *Camera2D, _viewport js.Value, _width, _height int) {
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("FRAMEBUFFER"), _sp.msaa.framebuffer)
+	canvasContext.Call("viewport", _viewport.Index(0).Int(), _viewport.Index(1).Int(), _viewport.Index(2).Int(), _viewport.Index(3).Int())
+	canvasContext.Call("clearColor", _pass.clearColor[0], _pass.clearColor[1], _pass.clearColor[2], _pass.clearColor[3])
+	canvasContext.Call("clear", canvasContext.Get("COLOR_BUFFER_BIT"))
+
+	blendFuncSeparate(_pass.accumulate)
+	_sp.renderSegments(segments, cam, false)
+
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("READ_FRAMEBUFFER"), _sp.msaa.framebuffer)
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("DRAW_FRAMEBUFFER"), _sp.msaa.resolve.gl.framebuffer)
+	canvasContext.Call("blitFramebuffer", 0, 0, _width, _height, 0, 0, _width, _height, canvasContext.Get("COLOR_BUFFER_BIT"), canvasContext.Get("NEAREST"))
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("READ_FRAMEBUFFER"), js.Null())
+	canvasContext.Call("bindFramebuffer", canvasContext.Get("DRAW_FRAMEBUFFER"), js.Null())
+
+	rebindCurrentFramebuffer()
+	blendFuncSeparate(_pass.composite)
+	if _pass.compositeSubtracts {
+		canvasContext.Call("blendEquation", canvasContext.Get("FUNC_REVERSE_SUBTRACT"))
+	}
+	applyPostProcessPass(&shapeCompositePass{}, _sp.msaa.resolve.GetTexture(), _width, _height)
+	if _pass.compositeSubtracts {
+		canvasContext.Call("blendEquation", canvasContext.Get("FUNC_ADD"))
+	}
+}
+
+/* ####### Curves & Dashes ####### */
+
+// On-screen pixels covered by one segment of a curve
+const SHAPE_CURVE_SEGMENT_PIXELS float32 = 6.0
+
+func (_sp *ShapeBatch) DrawQuadraticBezier(_p0, _p1, _p2 Vector2f, _color RGBA8) {
+	path := NewQuadraticBezierPath(_p0, _p1, _p2, segmentsForLength(polylineLength([]Vector2f{_p0, _p1, _p2})))
+	_sp.strokePolyline(path.Points, _sp.strokeWidth(), false, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawCubicBezier(_p0, _p1, _p2, _p3 Vector2f, _color RGBA8) {
+	path := NewCubicBezierPath(_p0, _p1, _p2, _p3, segmentsForLength(polylineLength([]Vector2f{_p0, _p1, _p2, _p3})))
+	_sp.strokePolyline(path.Points, _sp.strokeWidth(), false, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+// Smooth curve passing through every point
+func (_sp *ShapeBatch) DrawCatmullRom(_points []Vector2f, _closed bool, _color RGBA8) {
+	if len(_points) < 2 {
+		return
+	}
+	segments := segmentsForLength(polylineLength(_points) / float32(len(_points)-1))
+	_sp.strokePolyline(CatmullRomPoints(_points, segments, _closed), _sp.strokeWidth(), _closed, _sp.LineJoin, _sp.LineCap, _color)
+}
+
+func (_sp *ShapeBatch) DrawDashedLine(_from, _to Vector2f, _dashLength, _gapLength float32, _color RGBA8) {
+	_sp.DrawDashedPolyline([]Vector2f{_from, _to}, []float32{_dashLength, _gapLength}, 0.0, false, _color)
+}
+
+// Draws a polyline as dashes, _pattern alternates dash and gap lengths in world units.
+// Increasing _offset over time makes the dashes march along the line
+func (_sp *ShapeBatch) DrawDashedPolyline(_points []Vector2f, _pattern []float32, _offset float32, _closed bool, _color RGBA8) {
+	path := NewCurvePath(_points, _closed)
+	_sp.drawDashedPath(&path, _pattern, _offset, _color)
+}
+
+// Draws any CurvePath (Bezier, Catmull-Rom) as dashes
+func (_sp *ShapeBatch) DrawDashedPath(_path *CurvePath, _pattern []float32, _offset float32, _color RGBA8) {
+	_sp.drawDashedPath(_path, _pattern, _offset, _color)
+}
+
+// Round dots of _radius every _spacing world units
+func (_sp *ShapeBatch) DrawDottedLine(_from, _to Vector2f, _spacing, _radius float32, _color RGBA8) {
+	if _spacing <= 0.0 {
+		return
+	}
+	path := NewCurvePath([]Vector2f{_from, _to}, false)
+	for distance := float32(0.0); distance <= path.Length(); distance += _spacing {
+		_sp.DrawFillCircle(path.PointAtDistance(distance), _radius, _color)
+	}
+}
+
+func (_sp *ShapeBatch) drawDashedPath(_path *CurvePath, _pattern []float32, _offset float32, _color RGBA8) {
+	if len(_pattern)%2 == 1 {
+		// An odd pattern is repeated so dashes and gaps alternate, like SVG stroke-dasharray
+		_pattern = append(append([]float32{}, _pattern...), _pattern...)
+	}
+	patternLength := float32(0.0)
+	for _, length := range _pattern {
+		patternLength += length
+	}
+	total := _path.Length()
+	if patternLength <= 0.0 || total <= 0.0 {
+		return
+	}
+
+	// Start one pattern early so a positive offset still begins with a full pattern
+	distance := -float32(math.Mod(float64(_offset), float64(patternLength)))
+	if distance > 0.0 {
+		distance -= patternLength
+	}
+	for index := 0; distance < total; index = (index + 1) % len(_pattern) {
+		next := distance + _pattern[index]
+		if index%2 == 0 {
+			from := MaxFloat32(distance, 0.0)
+			to := MinFloat32(next, total)
+			if to > from {
+				_sp.strokePolyline(_path.SubPath(from, to), _sp.strokeWidth(), false, _sp.LineJoin, _sp.LineCap, _color)
+			}
+		}
+		distance = next
+	}
+}
+
+// Segments needed for a curve of _length world units with the current render camera
+func segmentsForLength(_length float32) int {
+	segments := int(math.Ceil(float64(_length * GetRenderCamera().effectiveScale() / SHAPE_CURVE_SEGMENT_PIXELS)))
+	if segments < 4 {
+		return 4
+	}
+	if segments > SHAPE_MAX_SEGMENTS {
+		return SHAPE_MAX_SEGMENTS
+	}
+	return segments
+}
+
+func polylineLength(_points []Vector2f) float32 {
+	length := float32(0.0)
+	for i := 1; i < len(_points); i++ {
+		segment := _points[i].Subtract(_points[i-1])
+		length += segment.Length()
+	}
+	return length
+}
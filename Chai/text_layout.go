@@ -0,0 +1,348 @@
+package chai
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+)
+
+type TextAlign uint8
+
+const (
+	// Lines start at the position
+	TEXT_ALIGN_LEFT TextAlign = iota
+	// Lines are centred on the position
+	TEXT_ALIGN_CENTER
+	// Lines end at the position
+	TEXT_ALIGN_RIGHT
+)
+
+type TextVerticalAlign uint8
+
+const (
+	// The position is on the baseline of the first line
+	TEXT_VALIGN_BASELINE TextVerticalAlign = iota
+	// The position is at the ascent of the first line
+	TEXT_VALIGN_TOP
+	// The position is halfway between the ascent of the first line and the descent of the last
+	TEXT_VALIGN_MIDDLE
+	// The position is at the descent of the last line
+	TEXT_VALIGN_BOTTOM
+)
+
+// How text is broken into lines and placed around the draw position. Lengths are in world units, the
+// font size times the draw scale
+type TextStyle struct {
+	// Lines are wrapped between words to stay under this width, 0 never wraps
+	MaxWidth        float32
+	HorizontalAlign TextAlign
+	VerticalAlign   TextVerticalAlign
+	// Multiplies the line height of the font, 1 when 0
+	LineSpacing float32
+	// Added between every two glyphs of a line
+	LetterSpacing float32
+	// Moves pairs such as "AV" closer with the kerning table of the font
+	Kerning bool
+}
+
+// Left aligned on the baseline, kerned, without wrapping. What DrawString uses for fonts that aren't Arabic
+func DefaultTextStyle() TextStyle {
+	return TextStyle{LineSpacing: 1.0, Kerning: true}
+}
+
+type PositionedGlyph struct {
+	Rune rune
+	// Pen position on the baseline, from the draw position
+	Position Vector2f
+	// Byte offset of the rune in the laid out string, the shaped one for Arabic fonts
+	Index int
+	Line  int
+}
+
+type TextLine struct {
+	// The glyphs of the line are TextLayout.Glyphs[Start:End]
+	Start, End int
+	// Without the trailing spaces
+	Width float32
+	// Height of the baseline from the draw position
+	Baseline float32
+}
+
+type TextLayout struct {
+	Glyphs []PositionedGlyph
+	Lines  []TextLine
+	// Covers every line from its ascent to its descent, relative to the draw position
+	Bounds Rect
+	// Draw scale the layout was made for
+	Scale float32
+}
+
+/* ####### Layout ####### */
+
+// The metrics of the font and its fallbacks at a draw scale, nothing here touches the GPU
+type textMetrics struct {
+	chain      *fontChain
+	scale      float32
+	lineHeight float32
+	ascent     float32
+	descent    float32
+}
+
+func newTextMetrics(_chain *fontChain, _lineHeight, _scale float32) textMetrics {
+	metrics := textMetrics{chain: _chain, scale: _scale}
+	if len(_chain.faces) == 0 {
+		return metrics
+	}
+	faceMetrics := _chain.faces[0].face.Metrics()
+	metrics.ascent = float32(faceMetrics.Ascent) / 64.0 * _scale
+	metrics.descent = float32(faceMetrics.Descent) / 64.0 * _scale
+	metrics.lineHeight = float32(faceMetrics.Height) / 64.0 * _scale
+	if _lineHeight > 0.0 {
+		metrics.lineHeight = _lineHeight * _scale
+	}
+	return metrics
+}
+
+// The face _rune is drawn with and its advance, control characters take no room
+func (tm *textMetrics) advance(_rune rune) (font.Face, float32) {
+	face, drawn := tm.chain.resolve(_rune)
+	if face == nil || unicode.IsControl(_rune) {
+		return nil, 0.0
+	}
+	advance, _ := face.face.GlyphAdvance(drawn)
+	return face.face, float32(advance) / 64.0 * tm.scale
+}
+
+// Kerning between two runes drawn next to each other with the same face
+func (tm *textMetrics) kern(_face font.Face, _left, _right rune) float32 {
+	return float32(_face.Kern(_left, _right)) / 64.0 * tm.scale
+}
+
+type layoutRune struct {
+	char  rune
+	index int
+}
+
+// Breaks _text into lines and places every rune, _rightToLeft lays each line out from its end
+func layoutText(_metrics textMetrics, _text string, _style *TextStyle, _rightToLeft bool) TextLayout {
+	layout := TextLayout{Scale: _metrics.scale}
+	lineSpacing := _style.LineSpacing
+	if lineSpacing == 0.0 {
+		lineSpacing = 1.0
+	}
+	lineHeight := _metrics.lineHeight * lineSpacing
+
+	var runes []layoutRune
+	for index, char := range _text {
+		// "\r\n" breaks the line once, at its '\n'
+		if char == '\r' && strings.HasPrefix(_text[index+1:], "\n") {
+			continue
+		}
+		runes = append(runes, layoutRune{char, index})
+	}
+
+	paragraphStart := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && runes[i].char != '\n' {
+			continue
+		}
+		for _, line := range breakParagraph(&_metrics, runes[paragraphStart:i], _style) {
+			layout.placeLine(&_metrics, line, _style, _rightToLeft, -float32(len(layout.Lines))*lineHeight)
+		}
+		paragraphStart = i + 1
+	}
+
+	layout.align(&_metrics, _style)
+	return layout
+}
+
+// Splits a paragraph into the lines that fit in MaxWidth, between words when it can and inside a word wider than
+// a whole line when it can't. The spaces a line is broken at are dropped
+func breakParagraph(_metrics *textMetrics, _runes []layoutRune, _style *TextStyle) [][]layoutRune {
+	if _style.MaxWidth <= 0.0 || len(_runes) == 0 {
+		return [][]layoutRune{_runes}
+	}
+	var lines [][]layoutRune
+	lineStart, lastSpace := 0, -1
+	var penX float32
+	var previous rune
+	var previousFace font.Face
+	for i := 0; i < len(_runes); i++ {
+		char := _runes[i].char
+		if char == ' ' {
+			lastSpace = i
+		}
+		face, advance := _metrics.advance(char)
+		if _style.Kerning && face != nil && face == previousFace {
+			penX += _metrics.kern(face, previous, char)
+		}
+		if char != ' ' && i > lineStart && penX+advance > _style.MaxWidth {
+			end, next := i, i
+			if lastSpace > lineStart {
+				end, next = lastSpace, lastSpace+1
+				for end > lineStart && _runes[end-1].char == ' ' {
+					end--
+				}
+			}
+			lines = append(lines, _runes[lineStart:end])
+			for next < len(_runes) && _runes[next].char == ' ' {
+				next++
+			}
+			// The rest of the paragraph is measured again from the start of the new line
+			lineStart, lastSpace, i = next, -1, next-1
+			penX, previousFace = 0.0, nil
+			continue
+		}
+		penX += advance + _style.LetterSpacing
+		previous, previousFace = char, face
+	}
+	if lineStart < len(_runes) {
+		lines = append(lines, _runes[lineStart:])
+	}
+	return lines
+}
+
+func (tl *TextLayout) placeLine(_metrics *textMetrics, _line []layoutRune, _style *TextStyle, _rightToLeft bool, _baseline float32) {
+	lineIndex := len(tl.Lines)
+	line := TextLine{Start: len(tl.Glyphs), Baseline: _baseline}
+	var penX float32
+	var previous rune
+	var previousFace font.Face
+	if _rightToLeft {
+		_line = visualOrder(_line)
+	}
+	for _, r := range _line {
+		face, advance := _metrics.advance(r.char)
+		if _style.Kerning && face != nil && face == previousFace {
+			penX += _metrics.kern(face, previous, r.char)
+		}
+		tl.Glyphs = append(tl.Glyphs, PositionedGlyph{Rune: r.char, Position: NewVector2f(penX, _baseline), Index: r.index, Line: lineIndex})
+		penX += advance
+		if r.char != ' ' {
+			line.Width = penX
+		}
+		penX += _style.LetterSpacing
+		previous, previousFace = r.char, face
+	}
+	line.End = len(tl.Glyphs)
+	tl.Lines = append(tl.Lines, line)
+}
+
+// Arabic letters, in the basic block or as the presentation forms ShapeArabic joins them into
+func isRightToLeftRune(_rune rune) bool {
+	if unicode.IsDigit(_rune) {
+		return false
+	}
+	return IsArabicLetter(_rune) || (_rune >= 0xFB50 && _rune <= 0xFDFF) || (_rune >= 0xFE70 && _rune <= 0xFEFF)
+}
+
+func isLeftToRightRune(_rune rune) bool {
+	return (unicode.IsLetter(_rune) || unicode.IsDigit(_rune)) && !isRightToLeftRune(_rune)
+}
+
+// The runes of a right to left line in drawing order. Runs of other letters and digits, with the spaces and
+// punctuation between them, keep their reading order as they do with ShapeArabic
+func visualOrder(_line []layoutRune) []layoutRune {
+	ordered := make([]layoutRune, len(_line))
+	for i := range _line {
+		ordered[i] = _line[len(_line)-1-i]
+	}
+	for start := 0; start < len(ordered); {
+		if !isLeftToRightRune(ordered[start].char) {
+			start++
+			continue
+		}
+		last := start
+		for end := start; end < len(ordered) && !isRightToLeftRune(ordered[end].char); end++ {
+			if isLeftToRightRune(ordered[end].char) {
+				last = end
+			}
+		}
+		for i, j := start, last; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+		start = last + 1
+	}
+	return ordered
+}
+
+// Moves the lines to their horizontal alignment and the whole block to the vertical one, then sets the bounds
+func (tl *TextLayout) align(_metrics *textMetrics, _style *TextStyle) {
+	if len(tl.Lines) == 0 {
+		return
+	}
+	top := _metrics.ascent
+	bottom := tl.Lines[len(tl.Lines)-1].Baseline - _metrics.descent
+	var shiftY float32
+	switch _style.VerticalAlign {
+	case TEXT_VALIGN_TOP:
+		shiftY = -top
+	case TEXT_VALIGN_MIDDLE:
+		shiftY = -(top + bottom) * 0.5
+	case TEXT_VALIGN_BOTTOM:
+		shiftY = -bottom
+	}
+
+	minX, maxX := float32(0.0), float32(0.0)
+	for i := range tl.Lines {
+		line := &tl.Lines[i]
+		var shiftX float32
+		switch _style.HorizontalAlign {
+		case TEXT_ALIGN_CENTER:
+			shiftX = -line.Width * 0.5
+		case TEXT_ALIGN_RIGHT:
+			shiftX = -line.Width
+		}
+		line.Baseline += shiftY
+		for g := line.Start; g < line.End; g++ {
+			tl.Glyphs[g].Position = tl.Glyphs[g].Position.AddXY(shiftX, shiftY)
+		}
+		if i == 0 || shiftX < minX {
+			minX = shiftX
+		}
+		if i == 0 || shiftX+line.Width > maxX {
+			maxX = shiftX + line.Width
+		}
+	}
+	tl.Bounds = NewRect(minX, bottom+shiftY, maxX-minX, top-bottom)
+}
+
+/* ####### FontBatchAtlas ####### */
+
+// Breaks _text into lines and places its glyphs for drawing at _scale. Arabic fonts join the letters of the
+// text and lay every line out from right to left
+func (self *FontBatchAtlas) Layout(_text string, _scale float32, _style *TextStyle) TextLayout {
+	if self.cache == nil {
+		return TextLayout{Scale: _scale}
+	}
+	if self.fontSettings.Arabic {
+		// ShapeArabic reorders the text for drawing, layoutText wants it in reading order to wrap it
+		_text = shapeArabicWords(_text)
+	}
+	metrics := newTextMetrics(&self.cache.fontChain, self.fontSettings.LineHeight, _scale)
+	return layoutText(metrics, _text, _style, self.fontSettings.Arabic)
+}
+
+// The area _text covers when drawn at _scale with _style, relative to the draw position
+func (self *FontBatchAtlas) MeasureString(_text string, _scale float32, _style *TextStyle) Rect {
+	return self.Layout(_text, _scale, _style).Bounds
+}
+
+func (self *FontBatchAtlas) DrawStringStyled(_text string, _position Vector2f, _scale float32, _style *TextStyle, _tint RGBA8) {
+	layout := self.Layout(_text, _scale, _style)
+	self.DrawLayout(&layout, _position, _tint)
+}
+
+// Draws a layout made by Layout, which can be kept between frames for text that doesn't change
+func (self *FontBatchAtlas) DrawLayout(_layout *TextLayout, _position Vector2f, _tint RGBA8) {
+	for i := range _layout.Glyphs {
+		positioned := &_layout.Glyphs[i]
+		charglyph := self.getGlyph(positioned.Rune)
+		if charglyph.page < 0 || charglyph.size == Vector2fZero {
+			continue
+		}
+		bottomLeft := _position.Add(positioned.Position).Add(charglyph.offset.Scale(_layout.Scale))
+		self.sPatch.DrawSpriteBottomLeft(bottomLeft, charglyph.size.Scale(_layout.Scale), charglyph.uv1, charglyph.uv2, &self.cache.pages[charglyph.page].texture, _tint)
+	}
+}
@@ -0,0 +1,269 @@
+package chai
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// The Go fonts have no kerning table, this face gives a few pairs one
+type kernedFace struct {
+	font.Face
+	pairs map[[2]rune]fixed.Int26_6
+}
+
+func (kf *kernedFace) Kern(_left, _right rune) fixed.Int26_6 {
+	return kf.pairs[[2]rune{_left, _right}]
+}
+
+func newTestTextMetrics(t *testing.T, _scale float32) textMetrics {
+	t.Helper()
+	chain := &fontChain{faces: []fontFace{newTestFontFace(t, 32)}}
+	return newTextMetrics(chain, 0.0, _scale)
+}
+
+// Sum of the advances of _text, without kerning or letter spacing
+func textWidth(_metrics *textMetrics, _text string) float32 {
+	var width float32
+	for _, char := range _text {
+		_, advance := _metrics.advance(char)
+		width += advance
+	}
+	return width
+}
+
+func lineText(_layout *TextLayout, _line int) string {
+	var builder strings.Builder
+	line := _layout.Lines[_line]
+	for _, glyph := range _layout.Glyphs[line.Start:line.End] {
+		builder.WriteRune(glyph.Rune)
+	}
+	return builder.String()
+}
+
+func checkLines(t *testing.T, _layout *TextLayout, _want ...string) {
+	t.Helper()
+	if len(_layout.Lines) != len(_want) {
+		t.Fatalf("got %v lines, want %q", len(_layout.Lines), _want)
+	}
+	for i := range _want {
+		if got := lineText(_layout, i); got != _want[i] {
+			t.Errorf("line %v is %q, want %q", i, got, _want[i])
+		}
+	}
+}
+
+func approxEqual(_a, _b float32) bool {
+	return AbsFloat32(_a-_b) < 1e-3
+}
+
+func TestLayoutTextWrapsAtSpaces(t *testing.T) {
+	metrics := newTestTextMetrics(t, 1.0)
+	style := DefaultTextStyle()
+	style.MaxWidth = textWidth(&metrics, "aaa bbb") + 1.0
+
+	layout := layoutText(metrics, "aaa bbb ccc ddd", &style, false)
+	checkLines(t, &layout, "aaa bbb", "ccc ddd")
+
+	// The run of spaces a line is broken at is dropped, the ones inside a line are kept
+	layout = layoutText(metrics, "aaa bbb    ccc  d", &style, false)
+	checkLines(t, &layout, "aaa bbb", "ccc  d")
+
+	for i, line := range layout.Lines {
+		if line.Width > style.MaxWidth {
+			t.Errorf("line %v is %v wide, more than %v", i, line.Width, style.MaxWidth)
+		}
+	}
+	if !approxEqual(layout.Lines[0].Width, textWidth(&metrics, "aaa bbb")) {
+		t.Errorf("the first line is %v wide, want %v", layout.Lines[0].Width, textWidth(&metrics, "aaa bbb"))
+	}
+}
+
+func TestLayoutTextBreaksLongWords(t *testing.T) {
+	metrics := newTestTextMetrics(t, 1.0)
+	style := DefaultTextStyle()
+	style.MaxWidth = textWidth(&metrics, "abc") + 0.5
+
+	layout := layoutText(metrics, "hi abcdefghij", &style, false)
+	if len(layout.Lines) < 3 {
+		t.Fatalf("got %v lines, want the word broken over several", len(layout.Lines))
+	}
+	if got := lineText(&layout, 0); got != "hi" {
+		t.Errorf("the first line is %q, want the word to start a new line", got)
+	}
+	var word string
+	for i, line := range layout.Lines {
+		if line.End == line.Start {
+			t.Errorf("line %v is empty", i)
+		}
+		if line.Width > style.MaxWidth {
+			t.Errorf("line %v %q is %v wide, more than %v", i, lineText(&layout, i), line.Width, style.MaxWidth)
+		}
+		if i > 0 {
+			word += lineText(&layout, i)
+		}
+	}
+	if word != "abcdefghij" {
+		t.Errorf("the broken word reads %q", word)
+	}
+
+	// A glyph wider than the line still gets a line of its own instead of looping forever
+	style.MaxWidth = 1.0
+	layout = layoutText(metrics, "WW", &style, false)
+	checkLines(t, &layout, "W", "W")
+}
+
+func TestLayoutTextNewlines(t *testing.T) {
+	metrics := newTestTextMetrics(t, 1.0)
+	style := DefaultTextStyle()
+
+	text := "ab\ncd\r\nef"
+	layout := layoutText(metrics, text, &style, false)
+	checkLines(t, &layout, "ab", "cd", "ef")
+	for i, line := range layout.Lines {
+		if want := -float32(i) * metrics.lineHeight; !approxEqual(line.Baseline, want) {
+			t.Errorf("line %v has its baseline at %v, want %v", i, line.Baseline, want)
+		}
+	}
+	for _, glyph := range layout.Glyphs {
+		if text[glyph.Index] != byte(glyph.Rune) {
+			t.Errorf("%q has index %v, which is %q in the text", glyph.Rune, glyph.Index, text[glyph.Index])
+		}
+		if glyph.Position.Y != layout.Lines[glyph.Line].Baseline {
+			t.Errorf("%q is at %v, not on the baseline of line %v", glyph.Rune, glyph.Position, glyph.Line)
+		}
+	}
+
+	// Empty paragraphs still take a line
+	layout = layoutText(metrics, "a\n\nb\n", &style, false)
+	checkLines(t, &layout, "a", "", "b", "")
+
+	style.LineSpacing = 1.5
+	layout = layoutText(metrics, "a\nb", &style, false)
+	if want := -metrics.lineHeight * 1.5; !approxEqual(layout.Lines[1].Baseline, want) {
+		t.Errorf("the second line has its baseline at %v, want %v", layout.Lines[1].Baseline, want)
+	}
+}
+
+func TestLayoutTextAlignment(t *testing.T) {
+	metrics := newTestTextMetrics(t, 1.0)
+	short, long := textWidth(&metrics, "ab"), textWidth(&metrics, "abcdef")
+	tests := []struct {
+		name       string
+		align      TextAlign
+		shortStart float32
+		longStart  float32
+	}{
+		{"left", TEXT_ALIGN_LEFT, 0.0, 0.0},
+		{"center", TEXT_ALIGN_CENTER, -short * 0.5, -long * 0.5},
+		{"right", TEXT_ALIGN_RIGHT, -short, -long},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			style := DefaultTextStyle()
+			style.HorizontalAlign = test.align
+			layout := layoutText(metrics, "ab\nabcdef", &style, false)
+			checkLines(t, &layout, "ab", "abcdef")
+
+			starts := []float32{test.shortStart, test.longStart}
+			for i, line := range layout.Lines {
+				if got := layout.Glyphs[line.Start].Position.X; !approxEqual(got, starts[i]) {
+					t.Errorf("line %v starts at %v, want %v", i, got, starts[i])
+				}
+			}
+			if !approxEqual(layout.Bounds.Position.X, test.longStart) || !approxEqual(layout.Bounds.Size.X, long) {
+				t.Errorf("the bounds are %+v, want %v wide from %v", layout.Bounds, long, test.longStart)
+			}
+		})
+	}
+}
+
+func TestLayoutTextKerning(t *testing.T) {
+	face := newTestFontFace(t, 32)
+	face.face = &kernedFace{Face: face.face, pairs: map[[2]rune]fixed.Int26_6{{'A', 'V'}: fixed.I(-3)}}
+	metrics := newTextMetrics(&fontChain{faces: []fontFace{face}}, 0.0, 2.0)
+	advanceA := textWidth(&metrics, "A")
+
+	style := DefaultTextStyle()
+	layout := layoutText(metrics, "AV", &style, false)
+	if got, want := layout.Glyphs[1].Position.X, advanceA-6.0; !approxEqual(got, want) {
+		t.Errorf("kerned V is at %v, want %v", got, want)
+	}
+	if got, want := layout.Lines[0].Width, textWidth(&metrics, "AV")-6.0; !approxEqual(got, want) {
+		t.Errorf("the kerned line is %v wide, want %v", got, want)
+	}
+
+	style.Kerning = false
+	layout = layoutText(metrics, "AV", &style, false)
+	if got := layout.Glyphs[1].Position.X; !approxEqual(got, advanceA) {
+		t.Errorf("unkerned V is at %v, want %v", got, advanceA)
+	}
+
+	// Letter spacing goes between the glyphs, not after the last one
+	style.LetterSpacing = 4.0
+	layout = layoutText(metrics, "AV", &style, false)
+	if got, want := layout.Lines[0].Width, textWidth(&metrics, "AV")+4.0; !approxEqual(got, want) {
+		t.Errorf("the spaced line is %v wide, want %v", got, want)
+	}
+}
+
+func TestMeasureString(t *testing.T) {
+	face := newTestFontFace(t, 32)
+	atlas := FontBatchAtlas{cache: &glyphCache{fontChain: fontChain{faces: []fontFace{face}}}}
+	const scale = 2.0
+	metrics := newTestTextMetrics(t, scale)
+	width := textWidth(&metrics, "abcdef")
+	height := metrics.ascent + metrics.lineHeight + metrics.descent
+
+	tests := []struct {
+		name   string
+		valign TextVerticalAlign
+		bottom float32
+	}{
+		{"baseline", TEXT_VALIGN_BASELINE, -metrics.lineHeight - metrics.descent},
+		{"top", TEXT_VALIGN_TOP, -height},
+		{"middle", TEXT_VALIGN_MIDDLE, -height * 0.5},
+		{"bottom", TEXT_VALIGN_BOTTOM, 0.0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			style := DefaultTextStyle()
+			style.VerticalAlign = test.valign
+			bounds := atlas.MeasureString("ab\nabcdef", scale, &style)
+			want := NewRect(0.0, test.bottom, width, height)
+			if !approxEqual(bounds.Position.X, want.Position.X) || !approxEqual(bounds.Position.Y, want.Position.Y) ||
+				!approxEqual(bounds.Size.X, want.Size.X) || !approxEqual(bounds.Size.Y, want.Size.Y) {
+				t.Errorf("got %+v, want %+v", bounds, want)
+			}
+		})
+	}
+
+	if bounds := atlas.MeasureString("", scale, &TextStyle{}); bounds.Size.X != 0.0 {
+		t.Errorf("empty text is %v wide", bounds.Size.X)
+	}
+}
+
+func TestLayoutTextRightToLeft(t *testing.T) {
+	face := newTestFontFace(t, 32)
+	atlas := FontBatchAtlas{fontSettings: FontBatchSettings{Arabic: true}, cache: &glyphCache{fontChain: fontChain{faces: []fontFace{face}}}}
+	style := atlas.DrawStyle()
+
+	// The first word read is drawn rightmost, the letters are joined and the lines keep their order
+	layout := atlas.Layout("\u0628\u062A \u0628\u062A\n\u062A", 1.0, &style)
+	checkLines(t, &layout, "\uFE96\uFE91 \uFE96\uFE91", "\uFE95")
+	if glyph := layout.Glyphs[len(layout.Glyphs)-1]; glyph.Line != 1 || glyph.Position.Y >= layout.Glyphs[0].Position.Y {
+		t.Errorf("the second line is at %v, above the first", glyph.Position)
+	}
+
+	// Latin words and numbers inside the line keep reading left to right
+	layout = atlas.Layout("\u0628\u062A go 42", 1.0, &style)
+	checkLines(t, &layout, "go 42 \uFE96\uFE91")
+
+	// DrawString runs leftward from the position, the measured bounds end there
+	bounds := atlas.MeasureString("\u0628\u062A\nab", 1.0, &style)
+	if !approxEqual(bounds.Max().X, 0.0) || bounds.Size.X <= 0.0 {
+		t.Errorf("got %+v, want bounds that end at the draw position", bounds)
+	}
+}
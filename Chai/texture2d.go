@@ -2,6 +2,7 @@ package chai
 
 import (
 	"image"
+	"image/color"
 	"image/png"
 	"net/http"
 	"syscall/js"
@@ -12,6 +13,8 @@ type TileSet struct {
 	totalRows, totalColumns   int
 	spriteWidth, spriteHeight int
 	startPosition             Vector2f
+	// Pixels around the whole image and between two tiles
+	margin, spacing int
 }
 
 func NewTileSet(_startPosition Vector2f, _texture Texture2D, _columns, _rows int) TileSet {
@@ -25,9 +28,94 @@ func NewTileSet(_startPosition Vector2f, _texture Texture2D, _columns, _rows int
 	}
 }
 
+// A tile set made of _tileWidth x _tileHeight tiles, laid out like the Tiled editor does with margin and spacing
+func NewTileSetSpaced(_texture Texture2D, _tileWidth, _tileHeight, _margin, _spacing int) TileSet {
+	columns := (_texture.Width - 2*_margin + _spacing) / (_tileWidth + _spacing)
+	rows := (_texture.Height - 2*_margin + _spacing) / (_tileHeight + _spacing)
+	return TileSet{
+		texture:      _texture,
+		totalRows:    MaxInt(rows, 1),
+		totalColumns: MaxInt(columns, 1),
+		spriteWidth:  _tileWidth,
+		spriteHeight: _tileHeight,
+		margin:       _margin,
+		spacing:      _spacing,
+	}
+}
+
+func (ts *TileSet) GetTexture() *Texture2D {
+	return &ts.texture
+}
+
+func (ts *TileSet) GetTileCount() int {
+	return ts.totalRows * ts.totalColumns
+}
+
+func (ts *TileSet) GetTileSize() Vector2i {
+	return NewVector2i(ts.spriteWidth, ts.spriteHeight)
+}
+
+// Top-left and bottom-right uvs of a tile, tiles are numbered row by row from the top-left of the image
+func (ts *TileSet) TileUV(_index int) (Vector2f, Vector2f) {
+	column := _index % ts.totalColumns
+	row := _index / ts.totalColumns
+	x := float32(ts.margin + column*(ts.spriteWidth+ts.spacing))
+	y := float32(ts.margin + row*(ts.spriteHeight+ts.spacing))
+	width, height := float32(ts.texture.Width), float32(ts.texture.Height)
+	return NewVector2f(x/width, y/height), NewVector2f((x+float32(ts.spriteWidth))/width, (y+float32(ts.spriteHeight))/height)
+}
+
 type Texture2D struct {
 	Width, Height, bpp int
-	textureId          js.Value
+	handle             *textureHandle
+	// Unique per loaded texture, used to order sprites by texture
+	uid uint32
+}
+
+// The GL texture, shared by every copy of a Texture2D so they all see it recreated after a context loss
+type textureHandle struct {
+	id         js.Value
+	generation uint32
+	options    TextureOptions
+	// Uploaded again when the context is restored, undefined for render targets whose storage is only allocated
+	pixels        js.Value
+	width, height int
+}
+
+func newTextureHandle(_width, _height int, _options TextureOptions) *textureHandle {
+	return &textureHandle{pixels: js.Undefined(), width: _width, height: _height, options: _options}
+}
+
+// The GL texture, recreated first if the context was restored since it was uploaded
+func (t *Texture2D) glTexture() js.Value {
+	if t.handle == nil {
+		return js.Null()
+	}
+	if t.handle.generation != contextGeneration || t.handle.id.IsUndefined() {
+		t.handle.upload()
+	}
+	return t.handle.id
+}
+
+// Creates the texture and uploads the pixels, it is left bound to TEXTURE_2D
+func (h *textureHandle) upload() {
+	h.id = canvasContext.Call("createTexture")
+	h.generation = contextGeneration
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), h.id)
+	canvasContext.Call("pixelStorei", canvasContext.Get("UNPACK_ALIGNMENT"), 1)
+	pixels := js.Null()
+	if !h.pixels.IsUndefined() {
+		pixels = h.pixels
+	}
+	canvasContext.Call("texImage2D", canvasContext.Get("TEXTURE_2D"), 0, canvasContext.Get("RGBA8"), h.width, h.height, 0, canvasContext.Get("RGBA"), canvasContext.Get("UNSIGNED_BYTE"), pixels)
+	h.applyOptions()
+}
+
+var textureUidCounter uint32
+
+func nextTextureUid() uint32 {
+	textureUidCounter++
+	return textureUidCounter
 }
 
 type Pixel struct {
@@ -40,51 +128,198 @@ func New(r, g, b, a uint8) Pixel {
 	return pixel
 }
 
-func LoadPng(_filePath string) Texture2D {
+type TextureFilter uint8
 
-	var tempTexture Texture2D
+const (
+	TEXTURE_FILTER_LINEAR TextureFilter = iota
+	TEXTURE_FILTER_NEAREST
+)
 
-	resp, err := http.Get(app_url + "/" + _filePath)
-	if err != nil {
-		LogF("%v", err.Error())
+type TextureWrap uint8
+
+const (
+	TEXTURE_WRAP_CLAMP TextureWrap = iota
+	TEXTURE_WRAP_REPEAT
+	TEXTURE_WRAP_MIRRORED_REPEAT
+)
+
+// How a texture is sampled and uploaded
+type TextureOptions struct {
+	MinFilter, MagFilter TextureFilter
+	WrapS, WrapT         TextureWrap
+	// Generates mipmaps, minification then blends between them with MinFilter
+	Mipmaps bool
+	// Stores the colours multiplied by their alpha, as LoadPng always did. Turning it off keeps the
+	// straight colours, which avoids dark fringes around soft edges. Only used when the pixels are uploaded
+	PremultipliedAlpha bool
+}
+
+// What LoadPng uses
+func DefaultTextureOptions() TextureOptions {
+	return TextureOptions{MinFilter: TEXTURE_FILTER_LINEAR, MagFilter: TEXTURE_FILTER_LINEAR, PremultipliedAlpha: true}
+}
+
+// Sharp pixels when scaled up, no mipmaps
+func PixelArtTextureOptions() TextureOptions {
+	return TextureOptions{MinFilter: TEXTURE_FILTER_NEAREST, MagFilter: TEXTURE_FILTER_NEAREST, PremultipliedAlpha: true}
+}
+
+func (f TextureFilter) glFilter(_mipmaps bool) js.Value {
+	switch {
+	case f == TEXTURE_FILTER_NEAREST && _mipmaps:
+		return canvasContext.Get("NEAREST_MIPMAP_NEAREST")
+	case f == TEXTURE_FILTER_NEAREST:
+		return canvasContext.Get("NEAREST")
+	case _mipmaps:
+		return canvasContext.Get("LINEAR_MIPMAP_LINEAR")
 	}
-	img, err := png.Decode(resp.Body)
-	if err != nil {
-		LogF("%v", err.Error())
+	return canvasContext.Get("LINEAR")
+}
+
+func (w TextureWrap) glWrap() js.Value {
+	switch w {
+	case TEXTURE_WRAP_REPEAT:
+		return canvasContext.Get("REPEAT")
+	case TEXTURE_WRAP_MIRRORED_REPEAT:
+		return canvasContext.Get("MIRRORED_REPEAT")
 	}
+	return canvasContext.Get("CLAMP_TO_EDGE")
+}
 
-	resp.Body.Close()
+func (t *Texture2D) GetOptions() TextureOptions {
+	if t.handle == nil {
+		return TextureOptions{}
+	}
+	return t.handle.options
+}
 
-	tempTexture.Width = img.Bounds().Dx()
-	tempTexture.Height = img.Bounds().Dy()
+// Changes the filtering, wrapping and mipmaps of a loaded texture, and of every copy of it. PremultipliedAlpha
+// can't change after upload, reload the texture for that
+func (t *Texture2D) SetOptions(_options TextureOptions) {
+	if t.handle == nil {
+		WarningF("[TEXTURE]: SetOptions called on a texture that was never loaded")
+		return
+	}
+	if _options.PremultipliedAlpha != t.handle.options.PremultipliedAlpha {
+		WarningF("[TEXTURE]: premultiplied alpha only applies when loading, it is left as %v", t.handle.options.PremultipliedAlpha)
+		_options.PremultipliedAlpha = t.handle.options.PremultipliedAlpha
+	}
+	t.handle.options = _options
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), t.glTexture())
+	t.handle.applyOptions()
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), js.Null())
+}
 
-	pixels := make([]Pixel, tempTexture.Height*tempTexture.Width)
+// Applies the options to the texture bound to TEXTURE_2D
+func (h *textureHandle) applyOptions() {
+	if h.options.Mipmaps {
+		canvasContext.Call("generateMipmap", canvasContext.Get("TEXTURE_2D"))
+	}
+	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_MIN_FILTER"), h.options.MinFilter.glFilter(h.options.Mipmaps))
+	// Magnification never uses mipmaps
+	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_MAG_FILTER"), h.options.MagFilter.glFilter(false))
+	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_WRAP_S"), h.options.WrapS.glWrap())
+	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_WRAP_T"), h.options.WrapT.glWrap())
+}
 
-	for y := 0; y < tempTexture.Height; y++ {
-		for x := 0; x < tempTexture.Width; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			pixels[y*tempTexture.Width+x] = New(uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a))
-		}
+// Frees the GL texture of this texture and all its copies
+func (t *Texture2D) Delete() {
+	if t.handle == nil {
+		return
 	}
+	canvasContext.Call("deleteTexture", t.handle.id)
+	t.handle.id = js.Null()
+	t.handle.pixels = js.Undefined()
+}
+
+var maxTextureSize int
 
-	tempTexture.textureId = canvasContext.Call("createTexture")
+// Largest width and height of a texture the GPU accepts
+func GetMaxTextureSize() int {
+	if maxTextureSize == 0 {
+		maxTextureSize = canvasContext.Call("getParameter", canvasContext.Get("MAX_TEXTURE_SIZE")).Int()
+	}
+	return maxTextureSize
+}
+
+// A transparent texture whose pixels are filled later with updateRegion
+func newBlankTexture(_width, _height int, _options TextureOptions) Texture2D {
+	var tempTexture Texture2D
+	tempTexture.Width, tempTexture.Height = _width, _height
+	tempTexture.uid = nextTextureUid()
+	tempTexture.handle = newTextureHandle(_width, _height, _options)
+	tempTexture.handle.pixels = js.Global().Get("Uint8Array").New(_width * _height * 4)
 	canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0"))
-	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), tempTexture.textureId)
+	tempTexture.handle.upload()
+	return tempTexture
+}
+
+// Replaces the pixels under _img, whose top-left goes at _x, _y, on the GPU and in the copy kept for context
+// losses. _img is uploaded as is, its colours must already be premultiplied if the texture is
+func (t *Texture2D) updateRegion(_x, _y int, _img *image.RGBA) {
+	width, height := _img.Bounds().Dx(), _img.Bounds().Dy()
+	rowSize := width * 4
+	rows := make([]byte, rowSize*height)
+	for y := 0; y < height; y++ {
+		start := _img.PixOffset(_img.Bounds().Min.X, _img.Bounds().Min.Y+y)
+		copy(rows[y*rowSize:(y+1)*rowSize], _img.Pix[start:start+rowSize])
+	}
+	jsRows := js.Global().Get("Uint8Array").New(len(rows))
+	js.CopyBytesToJS(jsRows, rows)
+
+	if !t.handle.pixels.IsUndefined() {
+		for y := 0; y < height; y++ {
+			t.handle.pixels.Call("set", jsRows.Call("subarray", y*rowSize, (y+1)*rowSize), ((_y+y)*t.handle.width+_x)*4)
+		}
+	}
+
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), t.glTexture())
+	canvasContext.Call("pixelStorei", canvasContext.Get("UNPACK_ALIGNMENT"), 1)
+	canvasContext.Call("texSubImage2D", canvasContext.Get("TEXTURE_2D"), 0, _x, _y, width, height, canvasContext.Get("RGBA"), canvasContext.Get("UNSIGNED_BYTE"), jsRows)
+	if t.handle.options.Mipmaps {
+		canvasContext.Call("generateMipmap", canvasContext.Get("TEXTURE_2D"))
+	}
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), js.Null())
+}
 
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_MIN_FILTER"), canvasContext.Get("LINEAR"))
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_MAG_FILTER"), canvasContext.Get("LINEAR"))
+// Makes every pixel transparent
+func (t *Texture2D) clear() {
+	if !t.handle.pixels.IsUndefined() {
+		t.handle.pixels.Call("fill", 0)
+	}
+	// Uploading afresh is cheaper than a texSubImage2D of zeros built on the Go side
+	if !t.handle.id.IsUndefined() && !t.handle.id.IsNull() {
+		canvasContext.Call("deleteTexture", t.handle.id)
+	}
+	t.handle.upload()
+	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), js.Null())
+}
 
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_WRAP_S"), canvasContext.Get("CLAMP_TO_EDGE"))
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_WRAP_T"), canvasContext.Get("CLAMP_TO_EDGE"))
+func LoadPng(_filePath string) Texture2D {
+	return LoadPngWithOptions(_filePath, DefaultTextureOptions())
+}
 
-	jsPixels := pixelBufferToJsPixelBubffer(pixels)
+func LoadPngWithOptions(_filePath string, _options TextureOptions) Texture2D {
+	resp, err := http.Get(app_url + "/" + _filePath)
+	if err != nil {
+		LogF("%v", err.Error())
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		LogF("%v", err.Error())
+	}
 
-	canvasContext.Call("texImage2D", canvasContext.Get("TEXTURE_2D"), 0, canvasContext.Get("RGBA8"), tempTexture.Width, tempTexture.Height, 0, canvasContext.Get("RGBA"), canvasContext.Get("UNSIGNED_BYTE"), jsPixels)
+	resp.Body.Close()
 
-	return tempTexture
+	return LoadTextureFromImgWithOptions(img, _options)
 }
 
+// Uploaded with nearest filtering, see LoadTextureFromImgWithOptions
 func LoadTextureFromImg(img image.Image) Texture2D {
+	return LoadTextureFromImgWithOptions(img, PixelArtTextureOptions())
+}
+
+func LoadTextureFromImgWithOptions(img image.Image, _options TextureOptions) Texture2D {
 	var tempTexture Texture2D
 
 	tempTexture.Width = img.Bounds().Dx()
@@ -96,27 +331,26 @@ func LoadTextureFromImg(img image.Image) Texture2D {
 
 	pixels := make([]Pixel, tempTexture.Height*tempTexture.Width)
 
+	bounds := img.Bounds()
 	for y := 0; y < tempTexture.Height; y++ {
 		for x := 0; x < tempTexture.Width; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			pixels[y*tempTexture.Width+x] = New(uint8(r), uint8(g), uint8(b), uint8(a))
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			if _options.PremultipliedAlpha {
+				r, g, b, a := c.RGBA()
+				pixels[y*tempTexture.Width+x] = New(uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8))
+			} else {
+				straight := color.NRGBAModel.Convert(c).(color.NRGBA)
+				pixels[y*tempTexture.Width+x] = New(straight.R, straight.G, straight.B, straight.A)
+			}
 		}
 	}
 
-	tempTexture.textureId = canvasContext.Call("createTexture")
+	tempTexture.uid = nextTextureUid()
+	tempTexture.handle = newTextureHandle(tempTexture.Width, tempTexture.Height, _options)
+	// Kept on the CPU to upload it again after a context loss
+	tempTexture.handle.pixels = pixelBufferToJsPixelBubffer(pixels)
 	canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0"))
-	canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), tempTexture.textureId)
-
-	canvasContext.Call("pixelStorei", canvasContext.Get("UNPACK_ALIGNMENT"), 1)
-
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_MIN_FILTER"), canvasContext.Get("NEAREST"))
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_MAG_FILTER"), canvasContext.Get("NEAREST"))
-
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_WRAP_S"), canvasContext.Get("CLAMP_TO_EDGE"))
-	canvasContext.Call("texParameteri", canvasContext.Get("TEXTURE_2D"), canvasContext.Get("TEXTURE_WRAP_T"), canvasContext.Get("CLAMP_TO_EDGE"))
-
-	jsPixels := pixelBufferToJsPixelBubffer(pixels)
-	canvasContext.Call("texImage2D", canvasContext.Get("TEXTURE_2D"), 0, canvasContext.Get("RGBA8"), tempTexture.Width, tempTexture.Height, 0, canvasContext.Get("RGBA"), canvasContext.Get("UNSIGNED_BYTE"), jsPixels)
+	tempTexture.handle.upload()
 
 	return tempTexture
 }
@@ -0,0 +1,503 @@
+package chai
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+/* ####### Map Model ####### */
+
+// Layer types, as named by the Tiled JSON format
+const (
+	TILED_LAYER_TILES   = "tilelayer"
+	TILED_LAYER_OBJECTS = "objectgroup"
+	TILED_LAYER_GROUP   = "group"
+	TILED_LAYER_IMAGE   = "imagelayer"
+)
+
+// Custom properties, every value is kept as text whatever its type in Tiled
+type TiledProperties map[string]string
+
+func (p TiledProperties) GetString(_name, _default string) string {
+	if value, ok := p[_name]; ok {
+		return value
+	}
+	return _default
+}
+
+func (p TiledProperties) GetInt(_name string, _default int) int {
+	if value, err := strconv.Atoi(p[_name]); err == nil {
+		return value
+	}
+	return _default
+}
+
+func (p TiledProperties) GetFloat(_name string, _default float32) float32 {
+	if value, err := strconv.ParseFloat(p[_name], 32); err == nil {
+		return float32(value)
+	}
+	return _default
+}
+
+func (p TiledProperties) GetBool(_name string, _default bool) bool {
+	if value, err := strconv.ParseBool(p[_name]); err == nil {
+		return value
+	}
+	return _default
+}
+
+// A map loaded from a Tiled JSON (.tmj, .json) or TMX file, sizes and positions are in pixels with y pointing down
+type TiledMap struct {
+	// Size of the grid in tiles
+	Width, Height         int
+	TileWidth, TileHeight int
+	Orientation           string
+	Properties            TiledProperties
+	TileSets              []TiledTileSet
+	Layers                []TiledLayer
+}
+
+type TiledTileSet struct {
+	FirstGID uint32
+	Name     string
+	// Path of the image relative to the page, empty for image collection tile sets
+	Image                 string
+	TileWidth, TileHeight int
+	Margin, Spacing       int
+	Columns, TileCount    int
+	Properties            TiledProperties
+	// Only the tiles with a type, properties, an animation or colliders are listed
+	Tiles map[int32]*TiledTile
+}
+
+type TiledTile struct {
+	ID         int32
+	Type       string
+	Properties TiledProperties
+	Animation  []TiledFrame
+	// Collision shapes drawn in the tile collision editor, relative to the top-left of the tile
+	Colliders []TiledObject
+}
+
+type TiledFrame struct {
+	TileID int32
+	// In seconds
+	Duration float32
+}
+
+type TiledLayer struct {
+	Name string
+	// One of TILED_LAYER_TILES, TILED_LAYER_OBJECTS, TILED_LAYER_GROUP or TILED_LAYER_IMAGE
+	Type      string
+	Visible   bool
+	Opacity   float32
+	Offset    Vector2f
+	TintColor RGBA8
+	// Global tile ids row by row from the top-left, flip flags included
+	Data       []uint32
+	Objects    []TiledObject
+	Layers     []TiledLayer
+	Properties TiledProperties
+}
+
+type TiledObject struct {
+	ID   int
+	Name string
+	// Used to pick the prefab, the "class" of newer Tiled versions is read here too
+	Type     string
+	Position Vector2f
+	Size     Vector2f
+	// Clockwise, in degrees
+	Rotation float32
+	// Global tile id of tile objects, 0 otherwise
+	GID      uint32
+	Visible  bool
+	Ellipse  bool
+	Point    bool
+	Polygon  []Vector2f
+	Polyline []Vector2f
+
+	Properties TiledProperties
+}
+
+// Loads a Tiled map and its external tile sets, .tmx files are read as XML and anything else as JSON.
+// Returns nil when the map can't be fetched or parsed
+func LoadTiledMap(_filePath string) *TiledMap {
+	data, err := fetchAsset(_filePath)
+	if err != nil {
+		LogF("[TILED]: %v", err.Error())
+		return nil
+	}
+
+	var tiledMap *TiledMap
+	if isTiledXml(_filePath) {
+		tiledMap, err = parseTiledTmx(data, _filePath)
+	} else {
+		tiledMap, err = parseTiledJson(data, _filePath)
+	}
+	if err != nil {
+		LogF("[TILED]: %v: %v", _filePath, err.Error())
+		return nil
+	}
+	if tiledMap.Orientation != "" && tiledMap.Orientation != "orthogonal" {
+		WarningF("[TILED]: %v: only orthogonal maps are supported, got %v", _filePath, tiledMap.Orientation)
+	}
+	return tiledMap
+}
+
+// Splits a global tile id into the index of its tile set, the tile index inside it and the flip flags
+func (m *TiledMap) ResolveGID(_gid uint32) (int, int32, TileFlags, bool) {
+	id := _gid & tiledGidMask
+	if id == 0 {
+		return -1, TILE_EMPTY, 0, false
+	}
+	var flags TileFlags
+	if _gid&tiledFlipHorizontal != 0 {
+		flags |= TILE_FLIP_X
+	}
+	if _gid&tiledFlipVertical != 0 {
+		flags |= TILE_FLIP_Y
+	}
+	if _gid&tiledFlipDiagonal != 0 {
+		flags |= TILE_FLIP_DIAGONAL
+	}
+	// Tile sets are sorted by FirstGID, the last one starting at or before the id owns it
+	for i := len(m.TileSets) - 1; i >= 0; i-- {
+		if m.TileSets[i].FirstGID <= id {
+			return i, int32(id - m.TileSets[i].FirstGID), flags, true
+		}
+	}
+	return -1, TILE_EMPTY, 0, false
+}
+
+func (m *TiledMap) GetTile(_gid uint32) *TiledTile {
+	set, id, _, ok := m.ResolveGID(_gid)
+	if !ok {
+		return nil
+	}
+	return m.TileSets[set].Tiles[id]
+}
+
+// Calls _func for every layer, the layers of groups included, with the summed offset of their parents
+func (m *TiledMap) EachLayer(_func func(_layer *TiledLayer, _offset Vector2f)) {
+	var walk func(_layers []TiledLayer, _offset Vector2f)
+	walk = func(_layers []TiledLayer, _offset Vector2f) {
+		for i := range _layers {
+			layer := &_layers[i]
+			offset := _offset.Add(layer.Offset)
+			_func(layer, offset)
+			walk(layer.Layers, offset)
+		}
+	}
+	walk(m.Layers, Vector2fZero)
+}
+
+/* ####### Building ####### */
+
+// Creates the entity of a Tiled object, the entity already has its position, size and rotation
+type TiledPrefab func(_scene *Scene, _entity *EcsEntity, _object *TiledObject)
+
+type TiledLoadOptions struct {
+	// Pixels per world unit, 1 when zero
+	PixelsPerUnit float32
+	// World position of the bottom-left corner of the map
+	Origin Vector2f
+	// Keyed by object type
+	Prefabs map[string]TiledPrefab
+	// Every object of these layers becomes a static body, objects can also opt in with a "collision" bool property
+	CollisionLayers []string
+	Friction        float32
+}
+
+type TiledLevel struct {
+	Map           *TiledMap
+	Tilemap       *Tilemap
+	TilemapEntity *EcsEntity
+	// Entities created for objects, keyed by object id
+	Objects map[int]*EcsEntity
+}
+
+// Builds the map into _scene: tile layers go into one Tilemap, objects with a prefab or a collision
+// shape become entities, and tiles with colliders get static bodies
+func (m *TiledMap) Build(_scene *Scene, _options TiledLoadOptions) *TiledLevel {
+	builder := tiledBuilder{
+		tiledMap: m,
+		scene:    _scene,
+		options:  _options,
+		level:    &TiledLevel{Map: m, Objects: make(map[int]*EcsEntity)},
+	}
+	if builder.options.PixelsPerUnit <= 0 {
+		builder.options.PixelsPerUnit = 1.0
+	}
+	ppu := builder.options.PixelsPerUnit
+	builder.mapPixelHeight = float32(m.Height * m.TileHeight)
+
+	builder.loadTileSets()
+	tilemap := NewTilemap(m.Width, m.Height, NewVector2f(float32(m.TileWidth)/ppu, float32(m.TileHeight)/ppu), builder.tileSets...)
+	builder.level.Tilemap = tilemap
+	builder.addTileAnimations()
+
+	m.EachLayer(func(_layer *TiledLayer, _offset Vector2f) {
+		switch _layer.Type {
+		case TILED_LAYER_TILES:
+			builder.buildTileLayer(_layer)
+		case TILED_LAYER_OBJECTS:
+			collision := builder.isCollisionLayer(_layer)
+			for i := range _layer.Objects {
+				builder.buildObject(&_layer.Objects[i], _offset, collision)
+			}
+		case TILED_LAYER_IMAGE:
+			WarningF("[TILED]: image layer %v is not supported", _layer.Name)
+		}
+	})
+
+	builder.level.TilemapEntity = _scene.NewEntity(_options.Origin, tilemap.GetBounds().Size, 0.0)
+	WriteComponent(&_scene.Ecs_engine, builder.level.TilemapEntity, TilemapComponent{Tilemap: tilemap})
+	return builder.level
+}
+
+type tiledBuilder struct {
+	tiledMap       *TiledMap
+	scene          *Scene
+	options        TiledLoadOptions
+	level          *TiledLevel
+	mapPixelHeight float32
+	tileSets       []TileSet
+	// Index in tileSets of every Tiled tile set, -1 for the ones that couldn't be loaded
+	tileSetIndices []int
+}
+
+func (b *tiledBuilder) loadTileSets() {
+	b.tileSetIndices = make([]int, len(b.tiledMap.TileSets))
+	for i := range b.tiledMap.TileSets {
+		tileSet := &b.tiledMap.TileSets[i]
+		b.tileSetIndices[i] = -1
+		if tileSet.Image == "" {
+			WarningF("[TILED]: tile set %v has no image, image collections are not supported", tileSet.Name)
+			continue
+		}
+		if strings.ToLower(path.Ext(tileSet.Image)) != ".png" {
+			WarningF("[TILED]: tile set %v uses %v, only png images are supported", tileSet.Name, tileSet.Image)
+			continue
+		}
+		if len(b.tileSets) > 255 {
+			WarningF("[TILED]: too many tile sets, %v is skipped", tileSet.Name)
+			continue
+		}
+		texture := LoadPng(tileSet.Image)
+		b.tileSetIndices[i] = len(b.tileSets)
+		b.tileSets = append(b.tileSets, NewTileSetSpaced(texture, tileSet.TileWidth, tileSet.TileHeight, tileSet.Margin, tileSet.Spacing))
+	}
+}
+
+func (b *tiledBuilder) addTileAnimations() {
+	for i := range b.tiledMap.TileSets {
+		if b.tileSetIndices[i] < 0 {
+			continue
+		}
+		for id, tile := range b.tiledMap.TileSets[i].Tiles {
+			if len(tile.Animation) == 0 {
+				continue
+			}
+			animation := TileAnimation{FrameDuration: tile.Animation[0].Duration}
+			uneven := false
+			for _, frame := range tile.Animation {
+				animation.Frames = append(animation.Frames, frame.TileID)
+				animation.FrameDurations = append(animation.FrameDurations, frame.Duration)
+				uneven = uneven || frame.Duration != animation.FrameDuration
+			}
+			if !uneven {
+				animation.FrameDurations = nil
+			}
+			b.level.Tilemap.AddTileAnimation(uint8(b.tileSetIndices[i]), id, animation)
+		}
+	}
+}
+
+func (b *tiledBuilder) buildTileLayer(_layer *TiledLayer) {
+	m := b.tiledMap
+	layer := b.level.Tilemap.AddLayer(_layer.Name)
+	layer.Visible = _layer.Visible
+	tint := _layer.TintColor
+	tint.a = uint8(float32(tint.a) * ClampFloat32(_layer.Opacity, 0.0, 1.0))
+	layer.SetTint(tint)
+
+	if len(_layer.Data) < m.Width*m.Height {
+		WarningF("[TILED]: layer %v has %v tiles, expected %v", _layer.Name, len(_layer.Data), m.Width*m.Height)
+	}
+	for i, gid := range _layer.Data {
+		if i >= m.Width*m.Height {
+			break
+		}
+		set, id, flags, ok := m.ResolveGID(gid)
+		if !ok || b.tileSetIndices[set] < 0 {
+			continue
+		}
+		// Tiled rows go down from the top, Chai rows go up from the bottom
+		x, y := i%m.Width, m.Height-1-i/m.Width
+		tile := NewTile(uint8(b.tileSetIndices[set]), id)
+		tile.Flags = flags
+		layer.SetTile(x, y, tile)
+
+		if tiledTile := m.TileSets[set].Tiles[id]; tiledTile != nil {
+			tileSize := NewVector2f(float32(m.TileSets[set].TileWidth), float32(m.TileSets[set].TileHeight))
+			for j := range tiledTile.Colliders {
+				// Collider positions are relative to the top-left of the tile
+				cellTopLeft := NewVector2f(float32(i%m.Width*m.TileWidth), float32(i/m.Width*m.TileHeight))
+				collider := flipTileCollider(tiledTile.Colliders[j], tileSize, flags)
+				b.buildCollider(&collider, cellTopLeft)
+			}
+		}
+	}
+}
+
+func (b *tiledBuilder) isCollisionLayer(_layer *TiledLayer) bool {
+	if _layer.Properties.GetBool("collision", false) {
+		return true
+	}
+	for _, name := range b.options.CollisionLayers {
+		if name == _layer.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Converts a position in map pixels into world units
+func (b *tiledBuilder) toWorld(_pixel Vector2f) Vector2f {
+	ppu := b.options.PixelsPerUnit
+	return b.options.Origin.AddXY(_pixel.X/ppu, (b.mapPixelHeight-_pixel.Y)/ppu)
+}
+
+// Entity transform of an object: its centre, size in world units and counter-clockwise rotation
+func (b *tiledBuilder) objectTransform(_object *TiledObject, _offset Vector2f) (Vector2f, Vector2f, float32) {
+	ppu := b.options.PixelsPerUnit
+	anchor := b.toWorld(_object.Position.Add(_offset))
+	size := _object.Size.Scale(1.0 / ppu)
+	rotation := -_object.Rotation
+	if _object.Point || len(_object.Polygon) > 0 || len(_object.Polyline) > 0 {
+		return anchor, size, rotation
+	}
+	// Rectangles and ellipses hang from their top-left corner, tile objects stand on their bottom-left one
+	halfExtent := NewVector2f(size.X/2.0, -size.Y/2.0)
+	if _object.GID != 0 {
+		halfExtent.Y = size.Y / 2.0
+	}
+	return anchor.Add(halfExtent).Rotate(rotation, anchor), size, rotation
+}
+
+func (b *tiledBuilder) buildObject(_object *TiledObject, _offset Vector2f, _collisionLayer bool) {
+	prefab := b.options.Prefabs[_object.Type]
+	collision := _object.Properties.GetBool("collision", _collisionLayer)
+	if prefab == nil && !collision {
+		return
+	}
+
+	pos, size, rotation := b.objectTransform(_object, _offset)
+	entity := b.scene.NewEntity(pos, size, rotation)
+	if collision {
+		b.addCollider(entity, _object)
+	}
+	if prefab != nil {
+		prefab(b.scene, entity, _object)
+	}
+	b.level.Objects[_object.ID] = entity
+}
+
+// Collision shape of a tile, _origin is the top-left of the cell in map pixels
+func (b *tiledBuilder) buildCollider(_object *TiledObject, _origin Vector2f) {
+	pos, size, rotation := b.objectTransform(_object, _origin)
+	entity := b.scene.NewEntity(pos, size, rotation)
+	b.addCollider(entity, _object)
+}
+
+// Where a point of a tile, in pixels from its top-left, is drawn in a cell with _flags. Undoes the texture
+// mapping of tilemapMesh.addQuad
+func flipTilePoint(_point, _tileSize Vector2f, _flags TileFlags) Vector2f {
+	u, v := _point.X/_tileSize.X, _point.Y/_tileSize.Y
+	if _flags&TILE_FLIP_DIAGONAL != 0 {
+		u, v = v, u
+	}
+	if _flags&TILE_FLIP_X != 0 {
+		u = 1.0 - u
+	}
+	if _flags&TILE_FLIP_Y != 0 {
+		v = 1.0 - v
+	}
+	return NewVector2f(u*_tileSize.X, v*_tileSize.Y)
+}
+
+// The collider of a tile as it lies in a cell drawn with _flags. Rotated rectangles become polygons, the
+// flipped shapes are left unrotated
+func flipTileCollider(_object TiledObject, _tileSize Vector2f, _flags TileFlags) TiledObject {
+	if _flags == 0 {
+		return _object
+	}
+	flipped := _object
+	flipped.Rotation = 0.0
+	switch {
+	case _object.Point:
+		flipped.Position = flipTilePoint(_object.Position, _tileSize, _flags)
+	case _object.Ellipse:
+		center := _object.Position.Add(_object.Size.Scale(0.5).RotateCenter(_object.Rotation))
+		if _flags&TILE_FLIP_DIAGONAL != 0 {
+			flipped.Size = NewVector2f(_object.Size.Y, _object.Size.X)
+		}
+		flipped.Position = flipTilePoint(center, _tileSize, _flags).Subtract(flipped.Size.Scale(0.5))
+	case len(_object.Polygon) > 0 || len(_object.Polyline) > 0 || _object.Rotation != 0.0:
+		points := _object.Polyline
+		if len(_object.Polygon) > 0 {
+			points = _object.Polygon
+		} else if len(points) == 0 {
+			points = []Vector2f{{0, 0}, {_object.Size.X, 0}, _object.Size, {0, _object.Size.Y}}
+		}
+		flipped.Position = flipTilePoint(_object.Position, _tileSize, _flags)
+		flippedPoints := make([]Vector2f, len(points))
+		for i, point := range points {
+			absolute := _object.Position.Add(point.RotateCenter(_object.Rotation))
+			flippedPoints[i] = flipTilePoint(absolute, _tileSize, _flags).Subtract(flipped.Position)
+		}
+		if len(_object.Polyline) > 0 {
+			flipped.Polyline = flippedPoints
+		} else {
+			flipped.Polygon = flippedPoints
+		}
+	default:
+		corner := flipTilePoint(_object.Position, _tileSize, _flags)
+		opposite := flipTilePoint(_object.Position.Add(_object.Size), _tileSize, _flags)
+		flipped.Position = NewVector2f(MinFloat32(corner.X, opposite.X), MinFloat32(corner.Y, opposite.Y))
+		flipped.Size = NewVector2f(AbsFloat32(opposite.X-corner.X), AbsFloat32(opposite.Y-corner.Y))
+	}
+	return flipped
+}
+
+func (b *tiledBuilder) addCollider(_entity *EcsEntity, _object *TiledObject) {
+	world := GetPhysicsWorld()
+	friction := b.options.Friction
+	switch {
+	case len(_object.Polygon) > 0:
+		WriteComponent(&b.scene.Ecs_engine, _entity, NewStaticPolygonBody(_entity, b.localPoints(_object.Polygon), friction, world))
+	case len(_object.Polyline) > 0:
+		WriteComponent(&b.scene.Ecs_engine, _entity, NewStaticChainBody(_entity, b.localPoints(_object.Polyline), false, friction, world))
+	case _object.Point:
+		WarningF("[TILED]: point object %v can't have a collision shape", _object.ID)
+	case _object.Ellipse:
+		if AbsFloat32(_object.Size.X-_object.Size.Y) > 0.01 {
+			WarningF("[TILED]: ellipse %v is not a circle, its width is used as diameter", _object.ID)
+		}
+		// Circle bodies take their radius from the x of the size
+		WriteComponent(&b.scene.Ecs_engine, _entity, NewStaticBody(_entity, Shape_CircleCollider, _entity.Dimensions.Scale(0.5), friction, world))
+	default:
+		WriteComponent(&b.scene.Ecs_engine, _entity, NewStaticBody(_entity, Shape_RectCollider, _entity.Dimensions, friction, world))
+	}
+}
+
+// Polygon points are relative to the object position, with y pointing down
+func (b *tiledBuilder) localPoints(_points []Vector2f) []Vector2f {
+	points := make([]Vector2f, len(_points))
+	for i, point := range _points {
+		points[i] = NewVector2f(point.X, -point.Y).Scale(1.0 / b.options.PixelsPerUnit)
+	}
+	return points
+}
@@ -0,0 +1,667 @@
+package chai
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Tile ids stored in Tiled layers carry the flip flags in their highest bits
+const (
+	tiledFlipHorizontal uint32 = 0x80000000
+	tiledFlipVertical   uint32 = 0x40000000
+	tiledFlipDiagonal   uint32 = 0x20000000
+	tiledGidMask        uint32 = 0x0FFFFFFF
+)
+
+// Fetches a file next to the page, the same way LoadPng does
+func fetchAsset(_filePath string) ([]byte, error) {
+	resp, err := http.Get(app_url + "/" + _filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned %v", _filePath, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Paths inside asset files (maps, atlases) are relative to the file referencing them
+func resolveRelativePath(_base, _relative string) string {
+	if _relative == "" || strings.HasPrefix(_relative, "/") {
+		return strings.TrimPrefix(_relative, "/")
+	}
+	return path.Join(path.Dir(_base), _relative)
+}
+
+func isTiledXml(_filePath string) bool {
+	extension := strings.ToLower(path.Ext(_filePath))
+	return extension == ".tmx" || extension == ".tsx" || extension == ".xml"
+}
+
+func decodeTiledLayerData(_text, _encoding, _compression string) ([]uint32, error) {
+	switch _encoding {
+	case "csv":
+		fields := strings.FieldsFunc(_text, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t' })
+		data := make([]uint32, len(fields))
+		for i, field := range fields {
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			data[i] = uint32(gid)
+		}
+		return data, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(_text))
+		if err != nil {
+			return nil, err
+		}
+		var reader io.Reader = bytes.NewReader(raw)
+		switch _compression {
+		case "":
+		case "gzip":
+			if reader, err = gzip.NewReader(reader); err != nil {
+				return nil, err
+			}
+		case "zlib":
+			if reader, err = zlib.NewReader(reader); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported compression %q", _compression)
+		}
+		raw, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]uint32, len(raw)/4)
+		for i := range data {
+			data[i] = uint32(raw[i*4]) | uint32(raw[i*4+1])<<8 | uint32(raw[i*4+2])<<16 | uint32(raw[i*4+3])<<24
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", _encoding)
+}
+
+// "#RRGGBB" or "#AARRGGBB", white when empty
+func parseTiledColor(_color string) RGBA8 {
+	hex := strings.TrimPrefix(_color, "#")
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return WHITE
+	}
+	switch len(hex) {
+	case 6:
+		return NewRGBA8(uint8(value>>16), uint8(value>>8), uint8(value), 255)
+	case 8:
+		return NewRGBA8(uint8(value>>16), uint8(value>>8), uint8(value), uint8(value>>24))
+	}
+	return WHITE
+}
+
+/* ####### JSON ####### */
+
+type tiledJsonProperty struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type tiledJsonPoint struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+type tiledJsonObject struct {
+	ID         int                 `json:"id"`
+	Name       string              `json:"name"`
+	Type       string              `json:"type"`
+	Class      string              `json:"class"`
+	X          float32             `json:"x"`
+	Y          float32             `json:"y"`
+	Width      float32             `json:"width"`
+	Height     float32             `json:"height"`
+	Rotation   float32             `json:"rotation"`
+	GID        uint32              `json:"gid"`
+	Visible    *bool               `json:"visible"`
+	Ellipse    bool                `json:"ellipse"`
+	Point      bool                `json:"point"`
+	Polygon    []tiledJsonPoint    `json:"polygon"`
+	Polyline   []tiledJsonPoint    `json:"polyline"`
+	Properties []tiledJsonProperty `json:"properties"`
+}
+
+type tiledJsonLayer struct {
+	Name        string              `json:"name"`
+	Type        string              `json:"type"`
+	Visible     *bool               `json:"visible"`
+	Opacity     *float32            `json:"opacity"`
+	OffsetX     float32             `json:"offsetx"`
+	OffsetY     float32             `json:"offsety"`
+	TintColor   string              `json:"tintcolor"`
+	Data        json.RawMessage     `json:"data"`
+	Encoding    string              `json:"encoding"`
+	Compression string              `json:"compression"`
+	Objects     []tiledJsonObject   `json:"objects"`
+	Layers      []tiledJsonLayer    `json:"layers"`
+	Properties  []tiledJsonProperty `json:"properties"`
+}
+
+type tiledJsonTile struct {
+	ID        int32  `json:"id"`
+	Type      string `json:"type"`
+	Class     string `json:"class"`
+	Animation []struct {
+		TileID   int32 `json:"tileid"`
+		Duration int   `json:"duration"`
+	} `json:"animation"`
+	ObjectGroup *tiledJsonLayer     `json:"objectgroup"`
+	Properties  []tiledJsonProperty `json:"properties"`
+}
+
+type tiledJsonTileSet struct {
+	FirstGID    uint32              `json:"firstgid"`
+	Source      string              `json:"source"`
+	Name        string              `json:"name"`
+	TileWidth   int                 `json:"tilewidth"`
+	TileHeight  int                 `json:"tileheight"`
+	Margin      int                 `json:"margin"`
+	Spacing     int                 `json:"spacing"`
+	Columns     int                 `json:"columns"`
+	TileCount   int                 `json:"tilecount"`
+	Image       string              `json:"image"`
+	Tiles       []tiledJsonTile     `json:"tiles"`
+	Properties  []tiledJsonProperty `json:"properties"`
+	ImageWidth  int                 `json:"imagewidth"`
+	ImageHeight int                 `json:"imageheight"`
+}
+
+type tiledJsonMap struct {
+	Width       int                 `json:"width"`
+	Height      int                 `json:"height"`
+	TileWidth   int                 `json:"tilewidth"`
+	TileHeight  int                 `json:"tileheight"`
+	Orientation string              `json:"orientation"`
+	Infinite    bool                `json:"infinite"`
+	Layers      []tiledJsonLayer    `json:"layers"`
+	TileSets    []tiledJsonTileSet  `json:"tilesets"`
+	Properties  []tiledJsonProperty `json:"properties"`
+}
+
+func (p tiledJsonProperty) String() string {
+	switch value := p.Value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(p.Value)
+}
+
+func convertJsonProperties(_properties []tiledJsonProperty) TiledProperties {
+	properties := make(TiledProperties, len(_properties))
+	for _, property := range _properties {
+		properties[property.Name] = property.String()
+	}
+	return properties
+}
+
+func convertJsonObject(_object *tiledJsonObject) TiledObject {
+	object := TiledObject{
+		ID:         _object.ID,
+		Name:       _object.Name,
+		Type:       _object.Type,
+		Position:   NewVector2f(_object.X, _object.Y),
+		Size:       NewVector2f(_object.Width, _object.Height),
+		Rotation:   _object.Rotation,
+		GID:        _object.GID,
+		Visible:    _object.Visible == nil || *_object.Visible,
+		Ellipse:    _object.Ellipse,
+		Point:      _object.Point,
+		Properties: convertJsonProperties(_object.Properties),
+	}
+	if object.Type == "" {
+		object.Type = _object.Class
+	}
+	for _, point := range _object.Polygon {
+		object.Polygon = append(object.Polygon, NewVector2f(point.X, point.Y))
+	}
+	for _, point := range _object.Polyline {
+		object.Polyline = append(object.Polyline, NewVector2f(point.X, point.Y))
+	}
+	return object
+}
+
+func convertJsonLayer(_layer *tiledJsonLayer) (TiledLayer, error) {
+	layer := TiledLayer{
+		Name:       _layer.Name,
+		Type:       _layer.Type,
+		Visible:    _layer.Visible == nil || *_layer.Visible,
+		Opacity:    1.0,
+		Offset:     NewVector2f(_layer.OffsetX, _layer.OffsetY),
+		TintColor:  parseTiledColor(_layer.TintColor),
+		Properties: convertJsonProperties(_layer.Properties),
+	}
+	if _layer.Opacity != nil {
+		layer.Opacity = *_layer.Opacity
+	}
+
+	if len(_layer.Data) > 0 {
+		if _layer.Encoding == "base64" {
+			var text string
+			if err := json.Unmarshal(_layer.Data, &text); err != nil {
+				return layer, err
+			}
+			data, err := decodeTiledLayerData(text, _layer.Encoding, _layer.Compression)
+			if err != nil {
+				return layer, err
+			}
+			layer.Data = data
+		} else if err := json.Unmarshal(_layer.Data, &layer.Data); err != nil {
+			return layer, err
+		}
+	}
+	for i := range _layer.Objects {
+		layer.Objects = append(layer.Objects, convertJsonObject(&_layer.Objects[i]))
+	}
+	for i := range _layer.Layers {
+		child, err := convertJsonLayer(&_layer.Layers[i])
+		if err != nil {
+			return layer, err
+		}
+		layer.Layers = append(layer.Layers, child)
+	}
+	return layer, nil
+}
+
+func convertJsonTileSet(_tileSet *tiledJsonTileSet, _filePath string) TiledTileSet {
+	tileSet := TiledTileSet{
+		FirstGID:   _tileSet.FirstGID,
+		Name:       _tileSet.Name,
+		Image:      resolveRelativePath(_filePath, _tileSet.Image),
+		TileWidth:  _tileSet.TileWidth,
+		TileHeight: _tileSet.TileHeight,
+		Margin:     _tileSet.Margin,
+		Spacing:    _tileSet.Spacing,
+		Columns:    _tileSet.Columns,
+		TileCount:  _tileSet.TileCount,
+		Properties: convertJsonProperties(_tileSet.Properties),
+		Tiles:      make(map[int32]*TiledTile),
+	}
+	for i := range _tileSet.Tiles {
+		source := &_tileSet.Tiles[i]
+		tile := &TiledTile{ID: source.ID, Type: source.Type, Properties: convertJsonProperties(source.Properties)}
+		if tile.Type == "" {
+			tile.Type = source.Class
+		}
+		for _, frame := range source.Animation {
+			tile.Animation = append(tile.Animation, TiledFrame{TileID: frame.TileID, Duration: float32(frame.Duration) / 1000.0})
+		}
+		if source.ObjectGroup != nil {
+			for j := range source.ObjectGroup.Objects {
+				tile.Colliders = append(tile.Colliders, convertJsonObject(&source.ObjectGroup.Objects[j]))
+			}
+		}
+		tileSet.Tiles[tile.ID] = tile
+	}
+	return tileSet
+}
+
+func parseTiledJson(_data []byte, _filePath string) (*TiledMap, error) {
+	var source tiledJsonMap
+	if err := json.Unmarshal(_data, &source); err != nil {
+		return nil, err
+	}
+	if source.Infinite {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+
+	tiledMap := &TiledMap{
+		Width:       source.Width,
+		Height:      source.Height,
+		TileWidth:   source.TileWidth,
+		TileHeight:  source.TileHeight,
+		Orientation: source.Orientation,
+		Properties:  convertJsonProperties(source.Properties),
+	}
+	for i := range source.TileSets {
+		tileSet, err := loadTiledTileSetEntry(&source.TileSets[i], _filePath)
+		if err != nil {
+			return nil, err
+		}
+		tiledMap.TileSets = append(tiledMap.TileSets, tileSet)
+	}
+	for i := range source.Layers {
+		layer, err := convertJsonLayer(&source.Layers[i])
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %v", source.Layers[i].Name, err)
+		}
+		tiledMap.Layers = append(tiledMap.Layers, layer)
+	}
+	return tiledMap, nil
+}
+
+// Embedded tile sets are converted directly, external ones (.tsj, .json or .tsx) are fetched
+func loadTiledTileSetEntry(_entry *tiledJsonTileSet, _mapPath string) (TiledTileSet, error) {
+	if _entry.Source == "" {
+		return convertJsonTileSet(_entry, _mapPath), nil
+	}
+	tileSet, err := loadExternalTiledTileSet(resolveRelativePath(_mapPath, _entry.Source))
+	tileSet.FirstGID = _entry.FirstGID
+	return tileSet, err
+}
+
+func loadExternalTiledTileSet(_filePath string) (TiledTileSet, error) {
+	data, err := fetchAsset(_filePath)
+	if err != nil {
+		return TiledTileSet{}, err
+	}
+	if isTiledXml(_filePath) {
+		var source tmxTileSet
+		if err := xml.Unmarshal(data, &source); err != nil {
+			return TiledTileSet{}, fmt.Errorf("%v: %v", _filePath, err)
+		}
+		return convertTmxTileSet(&source, _filePath)
+	}
+	var source tiledJsonTileSet
+	if err := json.Unmarshal(data, &source); err != nil {
+		return TiledTileSet{}, fmt.Errorf("%v: %v", _filePath, err)
+	}
+	return convertJsonTileSet(&source, _filePath), nil
+}
+
+/* ####### TMX ####### */
+
+type tmxProperties struct {
+	List []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+		// Multi-line strings are stored as the element text
+		Text string `xml:",chardata"`
+	} `xml:"property"`
+}
+
+type tmxPoints struct {
+	Points string `xml:"points,attr"`
+}
+
+type tmxObject struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float32       `xml:"x,attr"`
+	Y          float32       `xml:"y,attr"`
+	Width      float32       `xml:"width,attr"`
+	Height     float32       `xml:"height,attr"`
+	Rotation   float32       `xml:"rotation,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Visible    *int          `xml:"visible,attr"`
+	Ellipse    *struct{}     `xml:"ellipse"`
+	Point      *struct{}     `xml:"point"`
+	Polygon    *tmxPoints    `xml:"polygon"`
+	Polyline   *tmxPoints    `xml:"polyline"`
+	Properties tmxProperties `xml:"properties"`
+}
+
+// Tile layers, object groups and groups share this element so their order in the file is kept
+type tmxLayer struct {
+	XMLName   xml.Name
+	Name      string   `xml:"name,attr"`
+	Visible   *int     `xml:"visible,attr"`
+	Opacity   *float32 `xml:"opacity,attr"`
+	OffsetX   float32  `xml:"offsetx,attr"`
+	OffsetY   float32  `xml:"offsety,attr"`
+	TintColor string   `xml:"tintcolor,attr"`
+	Data      *struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+	} `xml:"data"`
+	Objects    []tmxObject   `xml:"object"`
+	Properties tmxProperties `xml:"properties"`
+	Layers     []tmxLayer    `xml:",any"`
+}
+
+type tmxTileSet struct {
+	FirstGID   uint32 `xml:"firstgid,attr"`
+	Source     string `xml:"source,attr"`
+	Name       string `xml:"name,attr"`
+	TileWidth  int    `xml:"tilewidth,attr"`
+	TileHeight int    `xml:"tileheight,attr"`
+	Margin     int    `xml:"margin,attr"`
+	Spacing    int    `xml:"spacing,attr"`
+	Columns    int    `xml:"columns,attr"`
+	TileCount  int    `xml:"tilecount,attr"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+	} `xml:"image"`
+	Tiles []struct {
+		ID        int32  `xml:"id,attr"`
+		Type      string `xml:"type,attr"`
+		Class     string `xml:"class,attr"`
+		Animation []struct {
+			TileID   int32 `xml:"tileid,attr"`
+			Duration int   `xml:"duration,attr"`
+		} `xml:"animation>frame"`
+		ObjectGroup *tmxLayer     `xml:"objectgroup"`
+		Properties  tmxProperties `xml:"properties"`
+	} `xml:"tile"`
+	Properties tmxProperties `xml:"properties"`
+}
+
+type tmxMap struct {
+	Width       int           `xml:"width,attr"`
+	Height      int           `xml:"height,attr"`
+	TileWidth   int           `xml:"tilewidth,attr"`
+	TileHeight  int           `xml:"tileheight,attr"`
+	Orientation string        `xml:"orientation,attr"`
+	Infinite    int           `xml:"infinite,attr"`
+	TileSets    []tmxTileSet  `xml:"tileset"`
+	Properties  tmxProperties `xml:"properties"`
+	Layers      []tmxLayer    `xml:",any"`
+}
+
+func convertTmxProperties(_properties *tmxProperties) TiledProperties {
+	properties := make(TiledProperties, len(_properties.List))
+	for _, property := range _properties.List {
+		if property.Value == "" {
+			properties[property.Name] = property.Text
+		} else {
+			properties[property.Name] = property.Value
+		}
+	}
+	return properties
+}
+
+// "x1,y1 x2,y2 ..."
+func parseTmxPoints(_points string) []Vector2f {
+	points := make([]Vector2f, 0)
+	for _, pair := range strings.Fields(_points) {
+		coordinates := strings.Split(pair, ",")
+		if len(coordinates) != 2 {
+			continue
+		}
+		x, _ := strconv.ParseFloat(coordinates[0], 32)
+		y, _ := strconv.ParseFloat(coordinates[1], 32)
+		points = append(points, NewVector2f(float32(x), float32(y)))
+	}
+	return points
+}
+
+func convertTmxObject(_object *tmxObject) TiledObject {
+	object := TiledObject{
+		ID:         _object.ID,
+		Name:       _object.Name,
+		Type:       _object.Type,
+		Position:   NewVector2f(_object.X, _object.Y),
+		Size:       NewVector2f(_object.Width, _object.Height),
+		Rotation:   _object.Rotation,
+		GID:        _object.GID,
+		Visible:    _object.Visible == nil || *_object.Visible != 0,
+		Ellipse:    _object.Ellipse != nil,
+		Point:      _object.Point != nil,
+		Properties: convertTmxProperties(&_object.Properties),
+	}
+	if object.Type == "" {
+		object.Type = _object.Class
+	}
+	if _object.Polygon != nil {
+		object.Polygon = parseTmxPoints(_object.Polygon.Points)
+	}
+	if _object.Polyline != nil {
+		object.Polyline = parseTmxPoints(_object.Polyline.Points)
+	}
+	return object
+}
+
+func convertTmxLayer(_layer *tmxLayer) (TiledLayer, error) {
+	layer := TiledLayer{
+		Name:       _layer.Name,
+		Visible:    _layer.Visible == nil || *_layer.Visible != 0,
+		Opacity:    1.0,
+		Offset:     NewVector2f(_layer.OffsetX, _layer.OffsetY),
+		TintColor:  parseTiledColor(_layer.TintColor),
+		Properties: convertTmxProperties(&_layer.Properties),
+	}
+	if _layer.Opacity != nil {
+		layer.Opacity = *_layer.Opacity
+	}
+	switch _layer.XMLName.Local {
+	case "layer":
+		layer.Type = TILED_LAYER_TILES
+	case "objectgroup":
+		layer.Type = TILED_LAYER_OBJECTS
+	case "group":
+		layer.Type = TILED_LAYER_GROUP
+	case "imagelayer":
+		layer.Type = TILED_LAYER_IMAGE
+	}
+
+	if _layer.Data != nil {
+		if _layer.Data.Encoding == "" {
+			// Plain XML, one <tile gid=""/> per cell
+			for _, tile := range _layer.Data.Tiles {
+				layer.Data = append(layer.Data, tile.GID)
+			}
+		} else {
+			data, err := decodeTiledLayerData(_layer.Data.Text, _layer.Data.Encoding, _layer.Data.Compression)
+			if err != nil {
+				return layer, err
+			}
+			layer.Data = data
+		}
+	}
+	for i := range _layer.Objects {
+		layer.Objects = append(layer.Objects, convertTmxObject(&_layer.Objects[i]))
+	}
+	for i := range _layer.Layers {
+		if !isTmxLayerElement(_layer.Layers[i].XMLName.Local) {
+			continue
+		}
+		child, err := convertTmxLayer(&_layer.Layers[i])
+		if err != nil {
+			return layer, err
+		}
+		layer.Layers = append(layer.Layers, child)
+	}
+	return layer, nil
+}
+
+func isTmxLayerElement(_name string) bool {
+	return _name == "layer" || _name == "objectgroup" || _name == "group" || _name == "imagelayer"
+}
+
+func convertTmxTileSet(_tileSet *tmxTileSet, _filePath string) (TiledTileSet, error) {
+	if _tileSet.Source != "" {
+		tileSet, err := loadExternalTiledTileSet(resolveRelativePath(_filePath, _tileSet.Source))
+		tileSet.FirstGID = _tileSet.FirstGID
+		return tileSet, err
+	}
+
+	tileSet := TiledTileSet{
+		FirstGID:   _tileSet.FirstGID,
+		Name:       _tileSet.Name,
+		Image:      resolveRelativePath(_filePath, _tileSet.Image.Source),
+		TileWidth:  _tileSet.TileWidth,
+		TileHeight: _tileSet.TileHeight,
+		Margin:     _tileSet.Margin,
+		Spacing:    _tileSet.Spacing,
+		Columns:    _tileSet.Columns,
+		TileCount:  _tileSet.TileCount,
+		Properties: convertTmxProperties(&_tileSet.Properties),
+		Tiles:      make(map[int32]*TiledTile),
+	}
+	for i := range _tileSet.Tiles {
+		source := &_tileSet.Tiles[i]
+		tile := &TiledTile{ID: source.ID, Type: source.Type, Properties: convertTmxProperties(&source.Properties)}
+		if tile.Type == "" {
+			tile.Type = source.Class
+		}
+		for _, frame := range source.Animation {
+			tile.Animation = append(tile.Animation, TiledFrame{TileID: frame.TileID, Duration: float32(frame.Duration) / 1000.0})
+		}
+		if source.ObjectGroup != nil {
+			for j := range source.ObjectGroup.Objects {
+				tile.Colliders = append(tile.Colliders, convertTmxObject(&source.ObjectGroup.Objects[j]))
+			}
+		}
+		tileSet.Tiles[tile.ID] = tile
+	}
+	return tileSet, nil
+}
+
+func parseTiledTmx(_data []byte, _filePath string) (*TiledMap, error) {
+	var source tmxMap
+	if err := xml.Unmarshal(_data, &source); err != nil {
+		return nil, err
+	}
+	if source.Infinite != 0 {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+
+	tiledMap := &TiledMap{
+		Width:       source.Width,
+		Height:      source.Height,
+		TileWidth:   source.TileWidth,
+		TileHeight:  source.TileHeight,
+		Orientation: source.Orientation,
+		Properties:  convertTmxProperties(&source.Properties),
+	}
+	for i := range source.TileSets {
+		tileSet, err := convertTmxTileSet(&source.TileSets[i], _filePath)
+		if err != nil {
+			return nil, err
+		}
+		tiledMap.TileSets = append(tiledMap.TileSets, tileSet)
+	}
+	for i := range source.Layers {
+		if !isTmxLayerElement(source.Layers[i].XMLName.Local) {
+			continue
+		}
+		layer, err := convertTmxLayer(&source.Layers[i])
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %v", source.Layers[i].Name, err)
+		}
+		tiledMap.Layers = append(tiledMap.Layers, layer)
+	}
+	return tiledMap, nil
+}
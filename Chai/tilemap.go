@@ -0,0 +1,531 @@
+package chai
+
+import (
+	"math"
+	"syscall/js"
+)
+
+// Tiles per side of a chunk, every chunk of a layer is cached in its own vertex buffer
+const TILEMAP_CHUNK_SIZE = 16
+
+const TILE_EMPTY int32 = -1
+
+type TileFlags uint8
+
+// Same meaning as the flip bits of the Tiled editor, the diagonal flip is applied first
+const (
+	TILE_FLIP_X TileFlags = 1 << iota
+	TILE_FLIP_Y
+	TILE_FLIP_DIAGONAL
+
+	TILE_ROTATE_90  = TILE_FLIP_DIAGONAL | TILE_FLIP_X
+	TILE_ROTATE_180 = TILE_FLIP_X | TILE_FLIP_Y
+	TILE_ROTATE_270 = TILE_FLIP_DIAGONAL | TILE_FLIP_Y
+)
+
+type Tile struct {
+	// Index inside the tile set, TILE_EMPTY for no tile
+	ID    int32
+	Set   uint8
+	Flags TileFlags
+	Tint  RGBA8
+}
+
+func NewTile(_set uint8, _id int32) Tile {
+	return Tile{ID: _id, Set: _set, Tint: WHITE}
+}
+
+func (t Tile) IsEmpty() bool {
+	return t.ID == TILE_EMPTY
+}
+
+// Cycles through Frames, every frame is a tile index of the same tile set
+type TileAnimation struct {
+	Frames        []int32
+	FrameDuration float32
+	// Seconds each frame is shown for, FrameDuration is used for all of them unless there is one per frame
+	FrameDurations []float32
+}
+
+// The tile shown _time seconds into the animation, false when it has no frames or no duration
+func (a *TileAnimation) frameAt(_time float32) (int32, bool) {
+	if len(a.Frames) == 0 {
+		return TILE_EMPTY, false
+	}
+	if len(a.FrameDurations) == len(a.Frames) {
+		var total float32
+		for _, duration := range a.FrameDurations {
+			total += duration
+		}
+		if total > 0.0 {
+			t := float32(math.Mod(float64(_time), float64(total)))
+			for i, duration := range a.FrameDurations {
+				if t < duration {
+					return a.Frames[i], true
+				}
+				t -= duration
+			}
+			return a.Frames[len(a.Frames)-1], true
+		}
+	}
+	if a.FrameDuration <= 0.0 {
+		return TILE_EMPTY, false
+	}
+	return a.Frames[int(_time/a.FrameDuration)%len(a.Frames)], true
+}
+
+type tileAnimationKey struct {
+	set uint8
+	id  int32
+}
+
+type Tilemap struct {
+	// Size of the grid in tiles
+	Width, Height int
+	// Size of one tile in world units
+	TileSize Vector2f
+	// World position of the bottom-left corner, follows the entity when used through TilemapComponent
+	Position   Vector2f
+	TileSets   []TileSet
+	Layers     []*TileLayer
+	animations map[tileAnimationKey]TileAnimation
+	// Context generation the chunk meshes were uploaded in
+	generation uint32
+}
+
+type TileLayer struct {
+	Name    string
+	Visible bool
+	// Multiplied with the tint of every tile
+	tint    RGBA8
+	tiles   []Tile
+	tilemap *Tilemap
+
+	chunks            []tilemapChunk
+	chunksX, chunksY  int
+	animatedMesh      tilemapMesh
+	animatedWorkspace []int
+}
+
+type tilemapChunk struct {
+	mesh  tilemapMesh
+	dirty bool
+	// Cells of the chunk holding animated tiles, they are drawn every frame instead of being cached
+	animatedCells []int
+}
+
+func NewTilemap(_width, _height int, _tileSize Vector2f, _tileSets ...TileSet) *Tilemap {
+	return &Tilemap{
+		Width:      _width,
+		Height:     _height,
+		TileSize:   _tileSize,
+		TileSets:   _tileSets,
+		Layers:     make([]*TileLayer, 0),
+		animations: make(map[tileAnimationKey]TileAnimation),
+	}
+}
+
+// Adds an empty layer on top of the others
+func (tm *Tilemap) AddLayer(_name string) *TileLayer {
+	layer := &TileLayer{
+		Name:    _name,
+		Visible: true,
+		tint:    WHITE,
+		tiles:   make([]Tile, tm.Width*tm.Height),
+		tilemap: tm,
+		chunksX: (tm.Width + TILEMAP_CHUNK_SIZE - 1) / TILEMAP_CHUNK_SIZE,
+		chunksY: (tm.Height + TILEMAP_CHUNK_SIZE - 1) / TILEMAP_CHUNK_SIZE,
+	}
+	for i := range layer.tiles {
+		layer.tiles[i] = Tile{ID: TILE_EMPTY, Tint: WHITE}
+	}
+	layer.chunks = make([]tilemapChunk, layer.chunksX*layer.chunksY)
+	for i := range layer.chunks {
+		layer.chunks[i].dirty = true
+	}
+	tm.Layers = append(tm.Layers, layer)
+	return layer
+}
+
+func (tm *Tilemap) GetLayer(_name string) *TileLayer {
+	for _, layer := range tm.Layers {
+		if layer.Name == _name {
+			return layer
+		}
+	}
+	return nil
+}
+
+func (tm *Tilemap) AddTileAnimation(_set uint8, _id int32, _animation TileAnimation) {
+	tm.animations[tileAnimationKey{_set, _id}] = _animation
+	tm.markAllDirty()
+}
+
+func (tm *Tilemap) markAllDirty() {
+	for _, layer := range tm.Layers {
+		for i := range layer.chunks {
+			layer.chunks[i].dirty = true
+		}
+	}
+}
+
+// Frees the cached vertex buffers
+func (tm *Tilemap) Delete() {
+	for _, layer := range tm.Layers {
+		for i := range layer.chunks {
+			layer.chunks[i].mesh.delete()
+			layer.chunks[i].dirty = true
+		}
+		layer.animatedMesh.delete()
+	}
+}
+
+// The buffers of a lost context are already gone, the meshes are rebuilt on the next draw
+func (tm *Tilemap) dropLostMeshes() {
+	for _, layer := range tm.Layers {
+		for i := range layer.chunks {
+			layer.chunks[i].mesh.created = false
+		}
+		layer.animatedMesh.created = false
+	}
+	tm.markAllDirty()
+	tm.generation = contextGeneration
+}
+
+/* ####### Coordinates ####### */
+
+func (tm *Tilemap) IsInside(_cell Vector2i) bool {
+	return _cell.X >= 0 && _cell.Y >= 0 && _cell.X < tm.Width && _cell.Y < tm.Height
+}
+
+// The cell containing a world position, it may be outside of the map
+func (tm *Tilemap) WorldToGrid(_worldPos Vector2f) Vector2i {
+	local := _worldPos.Subtract(tm.Position)
+	return NewVector2i(int(math.Floor(float64(local.X/tm.TileSize.X))), int(math.Floor(float64(local.Y/tm.TileSize.Y))))
+}
+
+// World position of the centre of a cell
+func (tm *Tilemap) GridToWorld(_cell Vector2i) Vector2f {
+	return tm.Position.AddXY((float32(_cell.X)+0.5)*tm.TileSize.X, (float32(_cell.Y)+0.5)*tm.TileSize.Y)
+}
+
+func (tm *Tilemap) GetCellBounds(_cell Vector2i) Rect {
+	return Rect{Position: tm.Position.AddXY(float32(_cell.X)*tm.TileSize.X, float32(_cell.Y)*tm.TileSize.Y), Size: tm.TileSize}
+}
+
+func (tm *Tilemap) GetBounds() Rect {
+	return Rect{Position: tm.Position, Size: NewVector2f(float32(tm.Width)*tm.TileSize.X, float32(tm.Height)*tm.TileSize.Y)}
+}
+
+/* ####### Layer ####### */
+
+// Cells are counted from the bottom-left of the map
+func (l *TileLayer) GetTile(_x, _y int) (Tile, bool) {
+	if !l.tilemap.IsInside(NewVector2i(_x, _y)) {
+		return Tile{ID: TILE_EMPTY}, false
+	}
+	return l.tiles[_y*l.tilemap.Width+_x], true
+}
+
+func (l *TileLayer) GetTileAtWorld(_worldPos Vector2f) (Tile, bool) {
+	cell := l.tilemap.WorldToGrid(_worldPos)
+	return l.GetTile(cell.X, cell.Y)
+}
+
+func (l *TileLayer) SetTile(_x, _y int, _tile Tile) {
+	if !l.tilemap.IsInside(NewVector2i(_x, _y)) {
+		WarningF("[TILEMAP]: cell (%v, %v) is outside of layer %v", _x, _y, l.Name)
+		return
+	}
+	l.tiles[_y*l.tilemap.Width+_x] = _tile
+	l.chunks[(_y/TILEMAP_CHUNK_SIZE)*l.chunksX+_x/TILEMAP_CHUNK_SIZE].dirty = true
+}
+
+func (l *TileLayer) ClearTile(_x, _y int) {
+	l.SetTile(_x, _y, Tile{ID: TILE_EMPTY, Tint: WHITE})
+}
+
+// Fills the whole layer with the same tile
+func (l *TileLayer) Fill(_tile Tile) {
+	for i := range l.tiles {
+		l.tiles[i] = _tile
+	}
+	for i := range l.chunks {
+		l.chunks[i].dirty = true
+	}
+}
+
+func (l *TileLayer) SetTint(_tint RGBA8) {
+	l.tint = _tint
+	for i := range l.chunks {
+		l.chunks[i].dirty = true
+	}
+}
+
+func (l *TileLayer) GetTint() RGBA8 {
+	return l.tint
+}
+
+/* ####### Meshes ####### */
+
+// Range of indices drawn with the texture of one tile set
+type tilemapMeshRange struct {
+	set           uint8
+	offset, count int
+}
+
+type tilemapMesh struct {
+	vao, vbo, ibo  js.Value
+	created        bool
+	ranges         []tilemapMeshRange
+	vertices       []spriteVertex
+	indices        []int32
+	numberOfQuads  int
+	bufferCapacity int
+}
+
+func (m *tilemapMesh) reset() {
+	m.vertices = m.vertices[:0]
+	m.indices = m.indices[:0]
+	m.ranges = m.ranges[:0]
+}
+
+func (m *tilemapMesh) addQuad(_tm *Tilemap, _x, _y int, _tile Tile, _id int32, _tint RGBA8) {
+	set := &_tm.TileSets[_tile.Set]
+	uv1, uv2 := set.TileUV(int(_id))
+	// Texture coordinates of the bottom-left, top-left, top-right and bottom-right corners
+	corners := [4][2]float32{{0, 1}, {0, 0}, {1, 0}, {1, 1}}
+	positions := [4][2]float32{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+
+	base := int32(len(m.vertices))
+	for i := 0; i < 4; i++ {
+		u, v := corners[i][0], corners[i][1]
+		if _tile.Flags&TILE_FLIP_X != 0 {
+			u = 1.0 - u
+		}
+		if _tile.Flags&TILE_FLIP_Y != 0 {
+			v = 1.0 - v
+		}
+		if _tile.Flags&TILE_FLIP_DIAGONAL != 0 {
+			u, v = v, u
+		}
+		position := NewVector2f((float32(_x)+positions[i][0])*_tm.TileSize.X, (float32(_y)+positions[i][1])*_tm.TileSize.Y)
+		uv := NewVector2f(LerpFloat32(uv1.X, uv2.X, u), LerpFloat32(uv1.Y, uv2.Y, v))
+		m.vertices = append(m.vertices, spriteVertex{Vertex: NewVertex(position, uv, _tint)})
+	}
+	m.indices = append(m.indices, base, base+1, base+2, base, base+2, base+3)
+
+	if len(m.ranges) == 0 || m.ranges[len(m.ranges)-1].set != _tile.Set {
+		m.ranges = append(m.ranges, tilemapMeshRange{set: _tile.Set, offset: len(m.indices) - 6})
+	}
+	m.ranges[len(m.ranges)-1].count += 6
+}
+
+func (m *tilemapMesh) upload(_usage string) {
+	if !m.created {
+		m.vao = canvasContext.Call("createVertexArray")
+		m.vbo = canvasContext.Call("createBuffer")
+		m.ibo = canvasContext.Call("createBuffer")
+		canvasContext.Call("bindVertexArray", m.vao)
+		canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), m.vbo)
+		canvasContext.Call("enableVertexAttribArray", 0)
+		canvasContext.Call("enableVertexAttribArray", 1)
+		canvasContext.Call("enableVertexAttribArray", 2)
+		canvasContext.Call("enableVertexAttribArray", 3)
+		canvasContext.Call("vertexAttribPointer", 0, 2, canvasContext.Get("FLOAT"), false, spriteVertexSize, 0)
+		canvasContext.Call("vertexAttribPointer", 1, 4, canvasContext.Get("UNSIGNED_BYTE"), true, spriteVertexSize, 8)
+		canvasContext.Call("vertexAttribPointer", 2, 2, canvasContext.Get("FLOAT"), false, spriteVertexSize, 12)
+		canvasContext.Call("vertexAttribPointer", 3, 1, canvasContext.Get("FLOAT"), false, spriteVertexSize, 20)
+		canvasContext.Call("bindBuffer", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), m.ibo)
+		m.created = true
+	} else {
+		canvasContext.Call("bindVertexArray", m.vao)
+		canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), m.vbo)
+	}
+
+	m.numberOfQuads = len(m.vertices) / 4
+	if m.numberOfQuads > 0 {
+		bytes := spriteVertexSliceAsBytes(m.vertices)
+		jsVertices := js.Global().Get("Uint8Array").New(len(bytes))
+		js.CopyBytesToJS(jsVertices, bytes)
+		canvasContext.Call("bufferData", canvasContext.Get("ARRAY_BUFFER"), jsVertices, canvasContext.Get(_usage))
+		canvasContext.Call("bufferData", canvasContext.Get("ELEMENT_ARRAY_BUFFER"), int32BufferToJsInt32Buffer(m.indices), canvasContext.Get(_usage))
+	}
+
+	canvasContext.Call("bindVertexArray", js.Null())
+	canvasContext.Call("bindBuffer", canvasContext.Get("ARRAY_BUFFER"), js.Null())
+	// The CPU copy is only kept as a scratch buffer for the next rebuild
+	m.vertices = m.vertices[:0]
+	m.indices = m.indices[:0]
+}
+
+func (m *tilemapMesh) draw(_tm *Tilemap) {
+	if !m.created || m.numberOfQuads == 0 {
+		return
+	}
+	canvasContext.Call("bindVertexArray", m.vao)
+	for _, r := range m.ranges {
+		canvasContext.Call("bindTexture", canvasContext.Get("TEXTURE_2D"), _tm.TileSets[r.set].texture.glTexture())
+		canvasContext.Call("drawElements", canvasContext.Get("TRIANGLES"), r.count, canvasContext.Get("UNSIGNED_INT"), r.offset*4)
+		renderStats.DrawCalls++
+	}
+	renderStats.Vertices += m.numberOfQuads * 4
+	canvasContext.Call("bindVertexArray", js.Null())
+}
+
+func (m *tilemapMesh) delete() {
+	if !m.created {
+		return
+	}
+	canvasContext.Call("deleteVertexArray", m.vao)
+	canvasContext.Call("deleteBuffer", m.vbo)
+	canvasContext.Call("deleteBuffer", m.ibo)
+	m.created = false
+}
+
+func (l *TileLayer) rebuildChunk(_chunkX, _chunkY int) {
+	tm := l.tilemap
+	chunk := &l.chunks[_chunkY*l.chunksX+_chunkX]
+	chunk.mesh.reset()
+	chunk.animatedCells = chunk.animatedCells[:0]
+
+	startX, startY := _chunkX*TILEMAP_CHUNK_SIZE, _chunkY*TILEMAP_CHUNK_SIZE
+	endX, endY := MinInt(startX+TILEMAP_CHUNK_SIZE, tm.Width), MinInt(startY+TILEMAP_CHUNK_SIZE, tm.Height)
+	// Grouping by tile set keeps one draw call per tile set and chunk
+	for set := range tm.TileSets {
+		for y := startY; y < endY; y++ {
+			for x := startX; x < endX; x++ {
+				tile := l.tiles[y*tm.Width+x]
+				if tile.IsEmpty() || int(tile.Set) != set {
+					continue
+				}
+				if _, animated := tm.animations[tileAnimationKey{tile.Set, tile.ID}]; animated {
+					chunk.animatedCells = append(chunk.animatedCells, y*tm.Width+x)
+					continue
+				}
+				chunk.mesh.addQuad(tm, x, y, tile, tile.ID, multiplyRGBA8(tile.Tint, l.tint))
+			}
+		}
+	}
+	chunk.mesh.upload("STATIC_DRAW")
+	chunk.dirty = false
+}
+
+func multiplyRGBA8(_a, _b RGBA8) RGBA8 {
+	return RGBA8{
+		uint8(uint16(_a.r) * uint16(_b.r) / 255),
+		uint8(uint16(_a.g) * uint16(_b.g) / 255),
+		uint8(uint16(_a.b) * uint16(_b.b) / 255),
+		uint8(uint16(_a.a) * uint16(_b.a) / 255),
+	}
+}
+
+/* ####### Rendering ####### */
+
+// Draws the visible layers of the map with _cam, only the chunks overlapping the camera view are drawn.
+// The draw calls are issued immediately, so the map ends up behind whatever is in the sprite batches
+func (tm *Tilemap) Draw(_cam *Camera2D) {
+	if len(tm.TileSets) == 0 || tm.Width == 0 || tm.Height == 0 {
+		return
+	}
+	if tm.generation != contextGeneration {
+		tm.dropLostMeshes()
+	}
+
+	view := _cam.GetViewBounds()
+	chunkWorldSize := tm.TileSize.Scale(TILEMAP_CHUNK_SIZE)
+	minChunk := NewVector2i(
+		MaxInt(int(math.Floor(float64((view.Position.X-tm.Position.X)/chunkWorldSize.X))), 0),
+		MaxInt(int(math.Floor(float64((view.Position.Y-tm.Position.Y)/chunkWorldSize.Y))), 0),
+	)
+	maxChunk := NewVector2i(
+		int(math.Floor(float64((view.Position.X+view.Size.X-tm.Position.X)/chunkWorldSize.X))),
+		int(math.Floor(float64((view.Position.Y+view.Size.Y-tm.Position.Y)/chunkWorldSize.Y))),
+	)
+
+	shader := &Sprites.shader
+	UseShader(shader)
+	// The meshes are built relative to the map, its position is folded into the view matrix
+	translation := affineMatrix2D(1, 0, 0, 1, tm.Position.X, tm.Position.Y)
+	viewMatrix := _cam.viewMatrix
+	viewMatrix.Multiply(&translation)
+	shader.SetUniformMat4("view_matrix", viewMatrix.Data())
+	if Sprites.textureSlots == 1 {
+		shader.SetUniformSampler("genericSampler", 0)
+	} else {
+		shader.SetUniformSampler("samplers[0]", 0)
+	}
+	canvasContext.Call("activeTexture", canvasContext.Get("TEXTURE0"))
+
+	for _, layer := range tm.Layers {
+		if !layer.Visible {
+			continue
+		}
+		layer.animatedWorkspace = layer.animatedWorkspace[:0]
+		for cy := minChunk.Y; cy <= MinInt(maxChunk.Y, layer.chunksY-1); cy++ {
+			for cx := minChunk.X; cx <= MinInt(maxChunk.X, layer.chunksX-1); cx++ {
+				chunk := &layer.chunks[cy*layer.chunksX+cx]
+				if chunk.dirty {
+					layer.rebuildChunk(cx, cy)
+				}
+				chunk.mesh.draw(tm)
+				layer.animatedWorkspace = append(layer.animatedWorkspace, chunk.animatedCells...)
+			}
+		}
+		layer.drawAnimatedTiles()
+	}
+	UnuseShader()
+}
+
+// The animated tiles of the visible chunks are rebuilt every frame into one dynamic mesh per layer
+func (l *TileLayer) drawAnimatedTiles() {
+	if len(l.animatedWorkspace) == 0 {
+		return
+	}
+	tm := l.tilemap
+	mesh := &l.animatedMesh
+	mesh.reset()
+	for set := range tm.TileSets {
+		for _, cell := range l.animatedWorkspace {
+			tile := l.tiles[cell]
+			if int(tile.Set) != set {
+				continue
+			}
+			animation := tm.animations[tileAnimationKey{tile.Set, tile.ID}]
+			id := tile.ID
+			if frame, ok := animation.frameAt(ElapsedTime); ok {
+				id = frame
+			}
+			mesh.addQuad(tm, cell%tm.Width, cell/tm.Width, tile, id, multiplyRGBA8(tile.Tint, l.tint))
+		}
+	}
+	mesh.upload("DYNAMIC_DRAW")
+	mesh.draw(tm)
+}
+
+type TilemapComponent struct {
+	Component
+	Tilemap *Tilemap
+	// Offset of the bottom-left corner of the map from the entity
+	Offset Vector2f
+}
+
+func (t *TilemapComponent) ComponentSet(val interface{}) { *t = val.(TilemapComponent) }
+
+type TilemapRenderSystem struct {
+	EcsSystemImpl
+}
+
+func (_render *TilemapRenderSystem) Update(dt float32) {
+	EachEntity(TilemapComponent{}, func(entity *EcsEntity, a interface{}) {
+		if !IsRenderLayerVisible(entity.RenderLayer) {
+			return
+		}
+		tilemap := a.(TilemapComponent)
+		if tilemap.Tilemap == nil {
+			return
+		}
+		tilemap.Tilemap.Position = entity.Pos.Add(tilemap.Offset)
+		tilemap.Tilemap.Draw(GetRenderCamera())
+	})
+}
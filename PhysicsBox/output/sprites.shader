@@ -3,12 +3,14 @@
 
 precision mediump float;
 
-in vec2 coordinates;
-in vec4 colors;
-in vec2 uv;
+layout(location = 0) in vec2 coordinates;
+layout(location = 1) in vec4 colors;
+layout(location = 2) in vec2 uv;
+layout(location = 3) in float texture_slot;
 
 out vec4 vertex_FragColor;
 out vec2 vertex_UV;
+out float vertex_TextureSlot;
 
 uniform mat4 projection_matrix;
 uniform mat4 view_matrix;
@@ -23,6 +25,7 @@ void main(void) {
 	
 	vertex_FragColor = colors;
 	vertex_UV = uv;
+	vertex_TextureSlot = texture_slot;
 }
 
 #shader fragment
@@ -32,12 +35,24 @@ precision mediump float;
 
 in vec4 vertex_FragColor;
 in vec2 vertex_UV;
+in float vertex_TextureSlot;
 
-uniform sampler2D genericSampler;
+uniform sampler2D samplers[8];
 
 out vec4 fragColor;
 
+vec4 sampleSlot(int _slot, vec2 _uv) {
+	if (_slot == 0) return texture(samplers[0], _uv);
+	if (_slot == 1) return texture(samplers[1], _uv);
+	if (_slot == 2) return texture(samplers[2], _uv);
+	if (_slot == 3) return texture(samplers[3], _uv);
+	if (_slot == 4) return texture(samplers[4], _uv);
+	if (_slot == 5) return texture(samplers[5], _uv);
+	if (_slot == 6) return texture(samplers[6], _uv);
+	return texture(samplers[7], _uv);
+}
+
 void main(void) {
-	vec4 thisColor = vertex_FragColor * texture(genericSampler, vertex_UV);
+	vec4 thisColor = vertex_FragColor * sampleSlot(int(vertex_TextureSlot + 0.5), vertex_UV);
 	fragColor = thisColor;
 }
\ No newline at end of file